@@ -13,3 +13,13 @@ type (
 	JSONFormatter = logrus.JSONFormatter
 	TextFormatter = logrus.TextFormatter
 )
+
+// Default keys of formatted entries, can be renamed with FieldMap.
+const (
+	FieldKeyMsg         = logrus.FieldKeyMsg
+	FieldKeyLevel       = logrus.FieldKeyLevel
+	FieldKeyTime        = logrus.FieldKeyTime
+	FieldKeyLogrusError = logrus.FieldKeyLogrusError
+	FieldKeyFunc        = logrus.FieldKeyFunc
+	FieldKeyFile        = logrus.FieldKeyFile
+)
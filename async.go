@@ -0,0 +1,356 @@
+package suplog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OverflowPolicy defines what happens to an entry when the async queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the logging call until there is space in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the entry being logged.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest entry in the queue to make space.
+	OverflowDropOldest
+	// OverflowDropBelowLevel drops the entry being logged if it is less severe
+	// than AsyncOptions.DropLevel, otherwise blocks.
+	OverflowDropBelowLevel
+)
+
+// AsyncOptions allows to set additional options of async output.
+type AsyncOptions struct {
+	// QueueSize is the capacity of the entry queue, defaults to 4096.
+	QueueSize int
+	// Overflow sets the policy applied when the queue is full.
+	Overflow OverflowPolicy
+	// DropLevel is used by OverflowDropBelowLevel policy, entries with
+	// this level or more severe are never dropped. Defaults to WarnLevel,
+	// since dropping everything but panics makes little sense.
+	DropLevel Level
+	// ExitTimeout limits the time spent draining the queue before exiting on Fatal.
+	ExitTimeout time.Duration
+}
+
+const (
+	defaultAsyncQueueSize   = 4096
+	defaultAsyncExitTimeout = 5 * time.Second
+)
+
+func checkAsyncOptions(opt *AsyncOptions) *AsyncOptions {
+	if opt == nil {
+		opt = &AsyncOptions{}
+	}
+
+	if opt.DropLevel == PanicLevel {
+		opt.DropLevel = WarnLevel
+	}
+
+	if opt.QueueSize <= 0 {
+		opt.QueueSize = defaultAsyncQueueSize
+	}
+
+	if opt.ExitTimeout <= 0 {
+		opt.ExitTimeout = defaultAsyncExitTimeout
+	}
+
+	return opt
+}
+
+// AsyncStats reports counters of async output.
+type AsyncStats struct {
+	// Queued is the number of entries currently waiting in the queue.
+	Queued int
+	// Written is the number of entries written so far.
+	Written uint64
+	// Dropped is the number of entries dropped due to queue overflow or closed output.
+	Dropped uint64
+	// Errors is the number of entries failed to be formatted or written.
+	Errors uint64
+}
+
+// AsyncLogger is a Logger with async output, its entries are formatted and
+// written by a background goroutine.
+type AsyncLogger interface {
+	Logger
+
+	// Flush blocks until all entries queued before the call are written,
+	// or the context is done.
+	Flush(ctx context.Context) error
+	// Close drains the queue and closes the underlying writer,
+	// if it implements io.WriteCloser.
+	Close() error
+	// AsyncStats returns counters of async output.
+	AsyncStats() AsyncStats
+}
+
+// ErrAsyncClosed is returned when closing async output twice.
+var ErrAsyncClosed = errors.New("async output is closed")
+
+// AsyncOutput enables async output of NewLogger, the logger implements AsyncLogger then.
+// Formatting and writing are done by a background goroutine, while hooks are still fired
+// synchronously by the logging call, so slow hooks still block it. Entries at Fatal and Panic
+// levels wait until the queue is drained.
+func AsyncOutput(opt *AsyncOptions) *LoggerOption {
+	opt = checkAsyncOptions(opt)
+
+	return &LoggerOption{apply: func(log *suplogger) {
+		log.async = newAsyncOutput(log.logger.Out, log.logger.Formatter, opt)
+
+		log.logger.Formatter = log.async
+		log.logger.Out = io.Discard
+		log.logger.ExitFunc = func(code int) {
+			ctx, cancel := context.WithTimeout(context.Background(), log.async.opt.ExitTimeout)
+			_ = log.async.Flush(ctx)
+			cancel()
+
+			os.Exit(code)
+		}
+	}}
+}
+
+// asyncOutput queues entries in a ring buffer, implements logrus.Formatter
+// so it can see entries after all hooks have been fired.
+type asyncOutput struct {
+	opt *AsyncOptions
+
+	mux      sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	progress *sync.Cond
+	ring     []*logrus.Entry
+	head     int
+	size     int
+	closed   bool
+
+	// pushed and done count entries, so Flush knows when
+	// everything queued before the call has been handled.
+	pushed uint64
+	done   uint64
+
+	// shadow logger is passed to formatters, instead of the discarding one.
+	outMux sync.Mutex
+	shadow *logrus.Logger
+
+	written uint64
+	dropped uint64
+	errors  uint64
+
+	stoppedC chan struct{}
+}
+
+func newAsyncOutput(wr io.Writer, formatter Formatter, opt *AsyncOptions) *asyncOutput {
+	o := &asyncOutput{
+		opt:  opt,
+		ring: make([]*logrus.Entry, opt.QueueSize),
+		shadow: &logrus.Logger{
+			Out:       wr,
+			Formatter: formatter,
+			Hooks:     make(LevelHooks),
+		},
+		stoppedC: make(chan struct{}),
+	}
+
+	o.notEmpty = sync.NewCond(&o.mux)
+	o.notFull = sync.NewCond(&o.mux)
+	o.progress = sync.NewCond(&o.mux)
+
+	go o.run()
+
+	return o
+}
+
+// Format queues a copy of the entry, the actual formatting is done later.
+func (o *asyncOutput) Format(e *logrus.Entry) ([]byte, error) {
+	entry := &logrus.Entry{
+		Data:    make(Fields, len(e.Data)),
+		Time:    e.Time,
+		Level:   e.Level,
+		Caller:  e.Caller,
+		Message: e.Message,
+		Context: e.Context,
+	}
+
+	for k, v := range e.Data {
+		entry.Data[k] = v
+	}
+
+	if o.push(entry) && entry.Level <= FatalLevel {
+		// make sure that entry is out before panic or exit
+		_ = o.Flush(context.Background())
+	}
+
+	return nil, nil
+}
+
+func (o *asyncOutput) push(e *logrus.Entry) bool {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	for !o.closed && o.size == len(o.ring) {
+		switch o.opt.Overflow {
+		case OverflowDropNewest:
+			atomic.AddUint64(&o.dropped, 1)
+			return false
+		case OverflowDropOldest:
+			o.ring[o.head] = nil
+			o.head = (o.head + 1) % len(o.ring)
+			o.size--
+			o.done++
+			atomic.AddUint64(&o.dropped, 1)
+			o.progress.Broadcast()
+		case OverflowDropBelowLevel:
+			if e.Level > o.opt.DropLevel {
+				atomic.AddUint64(&o.dropped, 1)
+				return false
+			}
+
+			o.notFull.Wait()
+		default:
+			o.notFull.Wait()
+		}
+	}
+
+	if o.closed {
+		atomic.AddUint64(&o.dropped, 1)
+		return false
+	}
+
+	o.ring[(o.head+o.size)%len(o.ring)] = e
+	o.size++
+	o.pushed++
+	o.notEmpty.Signal()
+
+	return true
+}
+
+func (o *asyncOutput) run() {
+	defer close(o.stoppedC)
+
+	for {
+		o.mux.Lock()
+		for o.size == 0 && !o.closed {
+			o.notEmpty.Wait()
+		}
+
+		if o.size == 0 && o.closed {
+			o.mux.Unlock()
+			return
+		}
+
+		e := o.ring[o.head]
+		o.ring[o.head] = nil
+		o.head = (o.head + 1) % len(o.ring)
+		o.size--
+		o.notFull.Signal()
+		o.mux.Unlock()
+
+		o.write(e)
+
+		o.mux.Lock()
+		o.done++
+		o.progress.Broadcast()
+		o.mux.Unlock()
+	}
+}
+
+func (o *asyncOutput) write(e *logrus.Entry) {
+	o.outMux.Lock()
+	defer o.outMux.Unlock()
+
+	e.Logger = o.shadow
+
+	serialized, err := o.shadow.Formatter.Format(e)
+	if err != nil {
+		atomic.AddUint64(&o.errors, 1)
+		fmt.Fprintf(os.Stderr, "Failed to obtain reader, %v\n", err)
+		return
+	}
+
+	if _, err := o.shadow.Out.Write(serialized); err != nil {
+		atomic.AddUint64(&o.errors, 1)
+		fmt.Fprintf(os.Stderr, "Failed to write to log, %v\n", err)
+		return
+	}
+
+	atomic.AddUint64(&o.written, 1)
+}
+
+// Flush blocks until all entries queued before the call are handled.
+func (o *asyncOutput) Flush(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		o.mux.Lock()
+		o.progress.Broadcast()
+		o.mux.Unlock()
+	})
+	defer stop()
+
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	target := o.pushed
+	for o.done < target {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		o.progress.Wait()
+	}
+
+	return nil
+}
+
+// Close stops accepting new entries, and waits until the queue is drained.
+func (o *asyncOutput) Close() error {
+	o.mux.Lock()
+	if o.closed {
+		o.mux.Unlock()
+		return ErrAsyncClosed
+	}
+
+	o.closed = true
+	o.notEmpty.Broadcast()
+	o.notFull.Broadcast()
+	o.mux.Unlock()
+
+	<-o.stoppedC
+
+	return nil
+}
+
+func (o *asyncOutput) setFormatter(formatter Formatter) {
+	o.outMux.Lock()
+	defer o.outMux.Unlock()
+
+	o.shadow.Formatter = formatter
+}
+
+func (o *asyncOutput) setOutput(wr io.Writer) {
+	o.outMux.Lock()
+	defer o.outMux.Unlock()
+
+	o.shadow.Out = wr
+}
+
+func (o *asyncOutput) stats() AsyncStats {
+	o.mux.Lock()
+	queued := o.size
+	o.mux.Unlock()
+
+	return AsyncStats{
+		Queued:  queued,
+		Written: atomic.LoadUint64(&o.written),
+		Dropped: atomic.LoadUint64(&o.dropped),
+		Errors:  atomic.LoadUint64(&o.errors),
+	}
+}
@@ -0,0 +1,203 @@
+package suplog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWriter blocks all writes until released.
+type blockingWriter struct {
+	mux      sync.Mutex
+	out      strings.Builder
+	releaseC chan struct{}
+	closed   bool
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{
+		releaseC: make(chan struct{}),
+	}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.releaseC
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	return w.out.Write(p)
+}
+
+func (w *blockingWriter) Close() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	w.closed = true
+	return nil
+}
+
+func (w *blockingWriter) String() string {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	return w.out.String()
+}
+
+func TestAsyncLogger(t *testing.T) {
+	t.Run("writes in background", func(t *testing.T) {
+		wr := newBlockingWriter()
+		l := NewLogger(wr, new(TextFormatter), AsyncOutput(nil)).(AsyncLogger)
+
+		var err error
+		func() {
+			defer l.DeferError(&err).ErrLevel(ErrorLevel).Debugf("deferred error")
+			err = errors.New("fail")
+		}()
+		l.WithField("module", "accounts").Info("not blocked")
+		require.Empty(t, wr.String())
+
+		close(wr.releaseC)
+		require.NoError(t, l.Flush(context.Background()))
+
+		out := wr.String()
+		require.Contains(t, out, "error=fail")
+		require.Contains(t, out, "level=error")
+		require.Contains(t, out, "module=accounts")
+		require.Equal(t, uint64(2), l.AsyncStats().Written)
+	})
+
+	t.Run("flush respects context", func(t *testing.T) {
+		wr := newBlockingWriter()
+		l := NewLogger(wr, new(TextFormatter), AsyncOutput(nil)).(AsyncLogger)
+		defer close(wr.releaseC)
+
+		l.Info("stuck")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, l.Flush(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		wr := newBlockingWriter()
+		l := NewLogger(wr, new(TextFormatter), AsyncOutput(&AsyncOptions{
+			QueueSize: 2,
+			Overflow:  OverflowDropNewest,
+		})).(AsyncLogger)
+
+		for i := 0; i < 10; i++ {
+			l.Infof("entry %d", i)
+		}
+
+		close(wr.releaseC)
+		require.NoError(t, l.Close())
+
+		out := wr.String()
+		require.Contains(t, out, "entry 0")
+		require.NotContains(t, out, "entry 9")
+
+		stats := l.AsyncStats()
+		require.NotZero(t, stats.Dropped)
+		require.Equal(t, uint64(10), stats.Written+stats.Dropped)
+		require.True(t, wr.closed)
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		wr := newBlockingWriter()
+		l := NewLogger(wr, new(TextFormatter), AsyncOutput(&AsyncOptions{
+			QueueSize: 2,
+			Overflow:  OverflowDropOldest,
+		})).(AsyncLogger)
+
+		for i := 0; i < 10; i++ {
+			l.Infof("entry %d", i)
+		}
+
+		close(wr.releaseC)
+		require.NoError(t, l.Close())
+
+		out := wr.String()
+		require.Contains(t, out, "entry 8")
+		require.Contains(t, out, "entry 9")
+
+		stats := l.AsyncStats()
+		require.NotZero(t, stats.Dropped)
+		require.Equal(t, uint64(10), stats.Written+stats.Dropped)
+	})
+
+	t.Run("drop below level", func(t *testing.T) {
+		wr := newBlockingWriter()
+		l := NewLogger(wr, new(TextFormatter), AsyncOutput(&AsyncOptions{
+			QueueSize: 2,
+			Overflow:  OverflowDropBelowLevel,
+			DropLevel: WarnLevel,
+		})).(AsyncLogger)
+
+		// worker is stuck on the first entry, fill the queue until debug entries are dropped
+		require.Eventually(t, func() bool {
+			l.Debug("debug entry")
+			stats := l.AsyncStats()
+			return stats.Queued == 2 && stats.Dropped > 0
+		}, time.Second, time.Millisecond)
+
+		errLogged := make(chan struct{})
+		go func() {
+			defer close(errLogged)
+			l.Error("important error")
+		}()
+
+		select {
+		case <-errLogged:
+			t.Fatal("error entry must block instead of being dropped")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(wr.releaseC)
+		<-errLogged
+		require.NoError(t, l.Close())
+
+		require.Contains(t, wr.String(), "important error")
+		require.NotZero(t, l.AsyncStats().Dropped)
+	})
+
+	t.Run("close drains the queue", func(t *testing.T) {
+		wr := newBlockingWriter()
+		l := NewLogger(wr, new(TextFormatter), AsyncOutput(nil)).(AsyncLogger)
+
+		for i := 0; i < 100; i++ {
+			l.Infof("entry %d", i)
+		}
+
+		time.AfterFunc(10*time.Millisecond, func() {
+			close(wr.releaseC)
+		})
+		require.NoError(t, l.Close())
+
+		require.Contains(t, wr.String(), "entry 99")
+		require.Equal(t, uint64(100), l.AsyncStats().Written)
+
+		l.Info("after close")
+		require.NotContains(t, wr.String(), "after close")
+		require.Equal(t, uint64(1), l.AsyncStats().Dropped)
+	})
+
+	t.Run("set formatter and output", func(t *testing.T) {
+		var first, second strings.Builder
+		l := NewLogger(&first, new(TextFormatter), AsyncOutput(nil)).(AsyncLogger)
+		cfg := l.(LoggerConfigurator)
+
+		cfg.SetOutput(&second)
+		cfg.SetFormatter(new(JSONFormatter))
+		l.Info("json entry")
+		require.NoError(t, l.Flush(context.Background()))
+
+		require.Empty(t, first.String())
+		require.Contains(t, second.String(), `"msg":"json entry"`)
+	})
+}
@@ -0,0 +1,305 @@
+// Package audit implements a tamper-evident audit log, where each record carries
+// a sequence number, the hash of the previous record and a signature.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+// Record is an audit record, its hash covers the exact JSON bytes as written.
+type Record struct {
+	Seq    uint64                 `json:"seq"`
+	Time   time.Time              `json:"time"`
+	Level  string                 `json:"level"`
+	Msg    string                 `json:"msg"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	// Prev is the hash of the previous record, empty for the first one.
+	Prev string `json:"prev"`
+}
+
+// signedRecord is a line of the audit log.
+type signedRecord struct {
+	Record json.RawMessage `json:"record"`
+	Hash   string          `json:"hash"`
+	Alg    string          `json:"alg"`
+	Sig    string          `json:"sig"`
+}
+
+// Options allows to set additional Chain options.
+type Options struct {
+	// Signer of records, defaults to SignerFromEnv.
+	Signer Signer
+	// FileMode of the audit log created by OpenFile, defaults to 0600.
+	FileMode os.FileMode
+}
+
+func checkOptions(opt *Options) (*Options, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+
+	if opt.Signer == nil {
+		signer, err := SignerFromEnv()
+		if err != nil {
+			return nil, err
+		}
+
+		opt.Signer = signer
+	}
+
+	if opt.FileMode == 0 {
+		opt.FileMode = 0o600
+	}
+
+	return opt, nil
+}
+
+// ErrClosed is returned when appending to a closed chain.
+var ErrClosed = errors.New("audit: chain is closed")
+
+// Chain appends signed records to the audit log. It implements Formatter and io.WriteCloser,
+// so it could be used as the output of a dedicated audit logger, see NewLogger.
+type Chain struct {
+	opt *Options
+
+	mux    sync.Mutex
+	wr     io.Writer
+	seq    uint64
+	prev   string
+	closed bool
+}
+
+// NewChain starts a new chain, written into wr.
+func NewChain(wr io.Writer, opt *Options) (*Chain, error) {
+	opt, err := checkOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chain{
+		opt: opt,
+		wr:  wr,
+	}, nil
+}
+
+// OpenFile opens or creates the audit log, continuing the chain from its last record.
+func OpenFile(path string, opt *Options) (*Chain, error) {
+	opt, err := checkOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, opt.FileMode)
+	if err != nil {
+		return nil, fmt.Errorf("audit: failed to open file: %w", err)
+	}
+
+	c := &Chain{
+		opt: opt,
+		wr:  f,
+	}
+
+	if c.seq, c.prev, err = lastRecord(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("audit: failed to resume chain: %w", err)
+	}
+
+	return c, nil
+}
+
+// lastRecord reads the last record of the log. A final line without newline is a torn write
+// of a record that has never been appended, so it's truncated.
+func lastRecord(f *os.File) (seq uint64, hash string, err error) {
+	var (
+		last   []byte
+		offset int64
+	)
+
+	r := bufio.NewReaderSize(f, 64*1024)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				if err := f.Truncate(offset); err != nil {
+					return 0, "", err
+				}
+			}
+
+			break
+		} else if err != nil {
+			return 0, "", err
+		} else if len(line) > maxLineSize {
+			return 0, "", bufio.ErrTooLong
+		}
+
+		offset += int64(len(line))
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+
+	if len(last) == 0 {
+		return 0, "", nil
+	}
+
+	var signed signedRecord
+	if err := json.Unmarshal(last, &signed); err != nil {
+		return 0, "", err
+	}
+
+	var record Record
+	if err := json.Unmarshal(signed.Record, &record); err != nil {
+		return 0, "", err
+	}
+
+	return record.Seq, hashRecord(signed.Record), nil
+}
+
+// maxLineSize limits the size of records read back.
+const maxLineSize = 16 * 1024 * 1024
+
+func hashRecord(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Append adds the entry to the chain.
+func (c *Chain) Append(e *logrus.Entry) error {
+	return c.append(e, "")
+}
+
+func (c *Chain) append(e *logrus.Entry, skipField string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+
+	record := Record{
+		Seq:   c.seq + 1,
+		Time:  e.Time.UTC(),
+		Level: e.Level.String(),
+		Msg:   e.Message,
+		Prev:  c.prev,
+	}
+
+	if len(e.Data) > 0 {
+		record.Fields = make(map[string]interface{}, len(e.Data))
+		for k, v := range e.Data {
+			if k == skipField {
+				continue
+			}
+
+			record.Fields[k] = jsonValue(v)
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("audit: failed to encode record: %w", err)
+	}
+
+	hash := hashRecord(data)
+	digest, _ := hex.DecodeString(hash)
+
+	sig, err := c.opt.Signer.Sign(digest)
+	if err != nil {
+		return fmt.Errorf("audit: failed to sign record: %w", err)
+	}
+
+	line, err := json.Marshal(signedRecord{
+		Record: data,
+		Hash:   hash,
+		Alg:    c.opt.Signer.Algorithm(),
+		Sig:    base64.StdEncoding.EncodeToString(sig),
+	})
+	if err != nil {
+		return fmt.Errorf("audit: failed to encode record: %w", err)
+	}
+
+	if _, err := c.wr.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("audit: failed to write record: %w", err)
+	}
+
+	c.seq = record.Seq
+	c.prev = hash
+
+	return nil
+}
+
+// jsonValue makes sure that the value could be encoded, like JSONFormatter does for errors.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	default:
+		if _, err := json.Marshal(v); err != nil {
+			return fmt.Sprint(v)
+		}
+
+		return v
+	}
+}
+
+// Last returns the sequence number and hash of the last record.
+func (c *Chain) Last() (seq uint64, hash string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.seq, c.prev
+}
+
+// Anchor returns the last record of the chain, to be kept elsewhere and passed to Verify later.
+func (c *Chain) Anchor() *Anchor {
+	seq, hash := c.Last()
+	return &Anchor{Seq: seq, Hash: hash}
+}
+
+// Format appends the entry to the chain, the result is always empty.
+func (c *Chain) Format(e *logrus.Entry) ([]byte, error) {
+	return nil, c.Append(e)
+}
+
+// Write discards p, since entries are appended to the chain by Format.
+func (c *Chain) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Close closes the underlying writer, if it implements io.WriteCloser.
+func (c *Chain) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+
+	c.closed = true
+
+	if closer, ok := c.wr.(io.WriteCloser); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// NewLogger constructs a dedicated audit logger, every entry of which is appended to the chain.
+func NewLogger(c *Chain, hooks ...suplog.Hook) suplog.Logger {
+	return suplog.NewLogger(c, c, hooks...)
+}
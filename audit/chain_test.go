@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestChain(t *testing.T) {
+	t.Run("dedicated logger", func(t *testing.T) {
+		var out bytes.Buffer
+		chain, err := NewChain(&out, &Options{Signer: NewHMACSigner(testKey)})
+		require.NoError(t, err)
+
+		log := NewLogger(chain)
+		log.WithField("user", "alice").Info("login")
+		log.WithError(errors.New("denied")).Warning("transfer rejected")
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2)
+		require.Contains(t, lines[0], `"user":"alice"`)
+		require.Contains(t, lines[1], `"error":"denied"`)
+
+		seq, hash := chain.Last()
+		require.Equal(t, uint64(2), seq)
+		require.Contains(t, lines[1], `"hash":"`+hash+`"`)
+
+		report, err := Verify(&out, NewHMACSigner(testKey), nil)
+		require.NoError(t, err)
+		require.True(t, report.OK(), report.Issues)
+		require.Equal(t, 2, report.Records)
+	})
+
+	t.Run("hook appends tagged entries", func(t *testing.T) {
+		var out, auditOut bytes.Buffer
+		signer := NewEd25519Signer(ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize)))
+		chain, err := NewChain(&auditOut, &Options{Signer: signer})
+		require.NoError(t, err)
+
+		log := suplog.NewLogger(&out, new(suplog.JSONFormatter), NewHook(chain, nil))
+		log.Info("regular entry")
+		log.WithField("audit", true).WithField("role", "admin").Info("role granted")
+		log.WithField("audit", "false").Info("not audited")
+
+		require.Contains(t, out.String(), "regular entry")
+		require.Contains(t, out.String(), "role granted")
+		require.NotContains(t, auditOut.String(), "regular entry")
+		require.NotContains(t, auditOut.String(), "not audited")
+		require.NotContains(t, auditOut.String(), `"audit"`)
+		require.Contains(t, auditOut.String(), "role granted")
+
+		report, err := Verify(&auditOut, signer.Verifier(), nil)
+		require.NoError(t, err)
+		require.True(t, report.OK(), report.Issues)
+		require.Equal(t, 1, report.Records)
+	})
+
+	t.Run("file resumes the chain", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		opt := &Options{Signer: NewHMACSigner(testKey)}
+
+		chain, err := OpenFile(path, opt)
+		require.NoError(t, err)
+		NewLogger(chain).Info("first")
+		require.NoError(t, chain.Close())
+
+		chain, err = OpenFile(path, opt)
+		require.NoError(t, err)
+		seq, _ := chain.Last()
+		require.Equal(t, uint64(1), seq)
+
+		NewLogger(chain).Info("second")
+		require.NoError(t, chain.Close())
+		require.ErrorIs(t, chain.Append(new(suplog.Entry)), ErrClosed)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		report, err := VerifyFile(path, NewHMACSigner(testKey), nil)
+		require.NoError(t, err)
+		require.True(t, report.OK(), report.Issues)
+		require.Equal(t, uint64(1), report.FirstSeq)
+		require.Equal(t, uint64(2), report.LastSeq)
+	})
+
+	t.Run("file with torn last line", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		opt := &Options{Signer: NewHMACSigner(testKey)}
+
+		chain, err := OpenFile(path, opt)
+		require.NoError(t, err)
+		NewLogger(chain).Info("first")
+		require.NoError(t, chain.Close())
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"record":{"seq":2,`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		chain, err = OpenFile(path, opt)
+		require.NoError(t, err)
+		seq, _ := chain.Last()
+		require.Equal(t, uint64(1), seq)
+
+		NewLogger(chain).Info("second")
+		require.NoError(t, chain.Close())
+
+		report, err := VerifyFile(path, NewHMACSigner(testKey), chain.Anchor())
+		require.NoError(t, err)
+		require.True(t, report.OK(), report.Issues)
+		require.Equal(t, 2, report.Records)
+	})
+
+	t.Run("signer from env", func(t *testing.T) {
+		_, err := NewChain(&bytes.Buffer{}, nil)
+		require.ErrorIs(t, err, ErrNoSigner)
+
+		seed := bytes.Repeat([]byte{2}, ed25519.SeedSize)
+		t.Setenv("LOG_AUDIT_ED25519_KEY", base64.StdEncoding.EncodeToString(seed))
+
+		signer, err := SignerFromEnv()
+		require.NoError(t, err)
+		require.Equal(t, AlgEd25519, signer.Algorithm())
+
+		t.Setenv("LOG_AUDIT_HMAC_KEY", base64.StdEncoding.EncodeToString(testKey))
+		signer, err = SignerFromEnv()
+		require.NoError(t, err)
+		require.Equal(t, AlgHMACSHA256, signer.Algorithm())
+	})
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HookOptions allows to set additional Hook options.
+type HookOptions struct {
+	// Levels enables this hook for all listed levels, defaults to all levels.
+	Levels []logrus.Level
+	// Field tags audit entries, defaults to "audit", or LOG_AUDIT_FIELD.
+	Field string
+}
+
+func checkHookOptions(opt *HookOptions) *HookOptions {
+	if opt == nil {
+		opt = &HookOptions{}
+	}
+
+	if len(opt.Levels) == 0 {
+		opt.Levels = logrus.AllLevels
+	}
+
+	if len(opt.Field) == 0 {
+		opt.Field = os.Getenv("LOG_AUDIT_FIELD")
+		if len(opt.Field) == 0 {
+			opt.Field = "audit"
+		}
+	}
+
+	return opt
+}
+
+// NewHook initializes a new logrus.Hook that appends entries tagged with the audit field,
+// e.g. log.WithField("audit", true), to the chain. The tag itself is not recorded.
+func NewHook(c *Chain, opt *HookOptions) logrus.Hook {
+	return &hook{
+		opt:   checkHookOptions(opt),
+		chain: c,
+	}
+}
+
+type hook struct {
+	opt   *HookOptions
+	chain *Chain
+}
+
+func (h *hook) Levels() []logrus.Level {
+	return h.opt.Levels
+}
+
+func (h *hook) Fire(e *logrus.Entry) error {
+	if !isTagged(e.Data[h.opt.Field]) {
+		return nil
+	}
+
+	return h.chain.append(e, h.opt.Field)
+}
+
+func isTagged(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		switch strings.ToLower(v) {
+		case "", "false", "0", "f", "no":
+			return false
+		default:
+			return true
+		}
+	default:
+		return v != nil
+	}
+}
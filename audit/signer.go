@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Signature algorithms, stored in records.
+const (
+	AlgHMACSHA256 = "hmac-sha256"
+	AlgEd25519    = "ed25519"
+)
+
+// Signer signs record digests.
+type Signer interface {
+	Algorithm() string
+	Sign(digest []byte) ([]byte, error)
+}
+
+// Verifier checks signatures made by the matching Signer.
+type Verifier interface {
+	Algorithm() string
+	Verify(digest, sig []byte) bool
+}
+
+// NewHMACSigner returns a Signer and Verifier using HMAC-SHA256 with the shared key.
+func NewHMACSigner(key []byte) *HMACSigner {
+	return &HMACSigner{key: key}
+}
+
+// HMACSigner signs digests with HMAC-SHA256, it verifies them as well.
+type HMACSigner struct {
+	key []byte
+}
+
+func (s *HMACSigner) Algorithm() string {
+	return AlgHMACSHA256
+}
+
+func (s *HMACSigner) Sign(digest []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(digest)
+	return mac.Sum(nil), nil
+}
+
+func (s *HMACSigner) Verify(digest, sig []byte) bool {
+	expected, _ := s.Sign(digest)
+	return hmac.Equal(expected, sig)
+}
+
+// NewEd25519Signer returns a Signer using the Ed25519 private key.
+func NewEd25519Signer(key ed25519.PrivateKey) *Ed25519Signer {
+	return &Ed25519Signer{key: key}
+}
+
+// Ed25519Signer signs digests with an Ed25519 private key.
+type Ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func (s *Ed25519Signer) Algorithm() string {
+	return AlgEd25519
+}
+
+func (s *Ed25519Signer) Sign(digest []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, digest), nil
+}
+
+// Verifier returns the matching Verifier, e.g. to be used by auditors.
+func (s *Ed25519Signer) Verifier() *Ed25519Verifier {
+	return NewEd25519Verifier(s.key.Public().(ed25519.PublicKey))
+}
+
+// NewEd25519Verifier returns a Verifier using the Ed25519 public key.
+func NewEd25519Verifier(key ed25519.PublicKey) *Ed25519Verifier {
+	return &Ed25519Verifier{key: key}
+}
+
+// Ed25519Verifier verifies signatures with an Ed25519 public key.
+type Ed25519Verifier struct {
+	key ed25519.PublicKey
+}
+
+func (v *Ed25519Verifier) Algorithm() string {
+	return AlgEd25519
+}
+
+func (v *Ed25519Verifier) Verify(digest, sig []byte) bool {
+	return ed25519.Verify(v.key, digest, sig)
+}
+
+// ErrNoSigner is returned when neither signer nor signing key is configured.
+var ErrNoSigner = errors.New("audit: no signer, set LOG_AUDIT_HMAC_KEY or LOG_AUDIT_ED25519_KEY")
+
+// SignerFromEnv returns a signer using base64-encoded keys from env: either
+// LOG_AUDIT_HMAC_KEY, or LOG_AUDIT_ED25519_KEY holding a 32-byte seed or 64-byte private key.
+func SignerFromEnv() (Signer, error) {
+	if v := os.Getenv("LOG_AUDIT_HMAC_KEY"); len(v) > 0 {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("audit: failed to decode LOG_AUDIT_HMAC_KEY: %w", err)
+		}
+
+		return NewHMACSigner(key), nil
+	}
+
+	if v := os.Getenv("LOG_AUDIT_ED25519_KEY"); len(v) > 0 {
+		key, err := ParseEd25519PrivateKey(v)
+		if err != nil {
+			return nil, fmt.Errorf("audit: failed to decode LOG_AUDIT_ED25519_KEY: %w", err)
+		}
+
+		return NewEd25519Signer(key), nil
+	}
+
+	return nil, ErrNoSigner
+}
+
+// ParseEd25519PrivateKey decodes a base64-encoded 32-byte seed or 64-byte private key.
+func ParseEd25519PrivateKey(s string) (ed25519.PrivateKey, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(data) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(data), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(data), nil
+	default:
+		return nil, fmt.Errorf("invalid key size %d", len(data))
+	}
+}
+
+// ParseEd25519PublicKey decodes a base64-encoded 32-byte public key.
+func ParseEd25519PublicKey(s string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid key size %d", len(data))
+	}
+
+	return ed25519.PublicKey(data), nil
+}
@@ -0,0 +1,208 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IssueKind is a kind of problem found by Verify.
+type IssueKind string
+
+const (
+	// IssueMalformed is a line that couldn't be parsed.
+	IssueMalformed IssueKind = "malformed"
+	// IssueModified is a record whose hash doesn't match its contents.
+	IssueModified IssueKind = "modified"
+	// IssueBadSignature is a record whose signature is invalid.
+	IssueBadSignature IssueKind = "bad_signature"
+	// IssueBrokenChain is a record that doesn't reference the hash of the previous record.
+	IssueBrokenChain IssueKind = "broken_chain"
+	// IssueGap is a record whose sequence number skips some records.
+	IssueGap IssueKind = "gap"
+	// IssueReordered is a record whose sequence number is not greater than the previous one.
+	IssueReordered IssueKind = "reordered"
+	// IssueTruncated is a log that ends before the anchor record.
+	IssueTruncated IssueKind = "truncated"
+)
+
+// Anchor is a record known to be in the log, e.g. from Chain.Anchor kept elsewhere.
+// Without it, records removed from the tail of the log can't be detected.
+type Anchor struct {
+	Seq uint64
+	// Hash of the record, it's not checked if empty.
+	Hash string
+}
+
+// ParseAnchor parses the anchor in "<seq>:<hash>" or "<seq>" format, see Anchor.String.
+func ParseAnchor(s string) (*Anchor, error) {
+	seqStr, hash, _ := strings.Cut(s, ":")
+
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("audit: invalid anchor seq: %w", err)
+	} else if _, err := hex.DecodeString(hash); err != nil {
+		return nil, fmt.Errorf("audit: invalid anchor hash: %w", err)
+	}
+
+	return &Anchor{Seq: seq, Hash: hash}, nil
+}
+
+func (a *Anchor) String() string {
+	if len(a.Hash) == 0 {
+		return strconv.FormatUint(a.Seq, 10)
+	}
+
+	return fmt.Sprintf("%d:%s", a.Seq, a.Hash)
+}
+
+// Issue is a problem found in the audit log.
+type Issue struct {
+	Kind IssueKind
+	// Line is the line number in the audit log, starting from 1.
+	Line int
+	// Seq is the sequence number of the record, if it could be parsed.
+	Seq     uint64
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("line %d: seq %d: %s: %s", i.Line, i.Seq, i.Kind, i.Message)
+}
+
+// Report is the result of Verify.
+type Report struct {
+	// Records is the number of records read.
+	Records int
+	// FirstSeq and LastSeq are sequence numbers of the first and last records.
+	FirstSeq uint64
+	LastSeq  uint64
+	Issues   []Issue
+}
+
+// OK reports whether no issues have been found.
+func (r *Report) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Verify walks the audit log, checking hashes, signatures, sequence numbers
+// and the hash chain. If the anchor is set, the log must contain it. Problems are
+// reported as issues, while the returned error is only about reading the log.
+func Verify(r io.Reader, verifier Verifier, anchor *Anchor) (*Report, error) {
+	report := &Report{}
+
+	var (
+		prevSeq  uint64
+		prevHash string
+		lineNum  int
+		started  bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		lineNum++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		issue := func(kind IssueKind, seq uint64, format string, args ...interface{}) {
+			report.Issues = append(report.Issues, Issue{
+				Kind:    kind,
+				Line:    lineNum,
+				Seq:     seq,
+				Message: fmt.Sprintf(format, args...),
+			})
+		}
+
+		var signed signedRecord
+		var record Record
+		if err := json.Unmarshal(line, &signed); err != nil {
+			issue(IssueMalformed, 0, "%v", err)
+			continue
+		} else if err := json.Unmarshal(signed.Record, &record); err != nil {
+			issue(IssueMalformed, 0, "%v", err)
+			continue
+		}
+
+		report.Records++
+		if !started {
+			report.FirstSeq = record.Seq
+		}
+		report.LastSeq = record.Seq
+
+		hash := hashRecord(signed.Record)
+		if hash != signed.Hash {
+			issue(IssueModified, record.Seq, "hash mismatch, expected %s", signed.Hash)
+		}
+
+		// the signature covers the stored hash, so modified contents are reported separately
+		digest, err := hex.DecodeString(signed.Hash)
+		sig, sigErr := base64.StdEncoding.DecodeString(signed.Sig)
+		if err != nil || sigErr != nil || signed.Alg != verifier.Algorithm() || !verifier.Verify(digest, sig) {
+			issue(IssueBadSignature, record.Seq, "signature is invalid (%s)", signed.Alg)
+		}
+
+		if started {
+			switch {
+			case record.Seq <= prevSeq:
+				issue(IssueReordered, record.Seq, "follows seq %d", prevSeq)
+			case record.Seq > prevSeq+1:
+				issue(IssueGap, record.Seq, "%d records missing after seq %d", record.Seq-prevSeq-1, prevSeq)
+			}
+
+			if record.Prev != prevHash {
+				issue(IssueBrokenChain, record.Seq, "previous record hash mismatch")
+			}
+		} else if record.Seq > 1 {
+			// chains start from seq 1, so the head of the log has been removed
+			issue(IssueGap, record.Seq, "%d records missing before seq %d", record.Seq-1, record.Seq)
+		} else if len(record.Prev) > 0 {
+			issue(IssueBrokenChain, record.Seq, "first record references a previous one")
+		}
+
+		if anchor != nil && record.Seq == anchor.Seq && len(anchor.Hash) > 0 && hash != anchor.Hash {
+			issue(IssueModified, record.Seq, "hash mismatch, expected anchor %s", anchor.Hash)
+		}
+
+		started = true
+		prevSeq = record.Seq
+		prevHash = hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return report, err
+	}
+
+	if anchor != nil && report.LastSeq < anchor.Seq {
+		report.Issues = append(report.Issues, Issue{
+			Kind:    IssueTruncated,
+			Line:    lineNum,
+			Seq:     report.LastSeq,
+			Message: fmt.Sprintf("%d records missing before anchor seq %d", anchor.Seq-report.LastSeq, anchor.Seq),
+		})
+	}
+
+	return report, nil
+}
+
+// VerifyFile verifies the audit log file, see Verify.
+func VerifyFile(path string, verifier Verifier, anchor *Anchor) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Verify(f, verifier, anchor)
+}
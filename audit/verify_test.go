@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestLog(t *testing.T, n int) []string {
+	var out bytes.Buffer
+	chain, err := NewChain(&out, &Options{Signer: NewHMACSigner(testKey)})
+	require.NoError(t, err)
+
+	log := NewLogger(chain)
+	for i := 0; i < n; i++ {
+		log.WithField("i", i).Info("transfer")
+	}
+
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func verifyLines(t *testing.T, lines []string) *Report {
+	report, err := Verify(strings.NewReader(strings.Join(lines, "\n")), NewHMACSigner(testKey), nil)
+	require.NoError(t, err)
+
+	return report
+}
+
+func issueKinds(report *Report) []IssueKind {
+	kinds := make([]IssueKind, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		kinds = append(kinds, issue.Kind)
+	}
+
+	return kinds
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("valid log", func(t *testing.T) {
+		report := verifyLines(t, writeTestLog(t, 3))
+		require.True(t, report.OK())
+		require.Equal(t, 3, report.Records)
+	})
+
+	t.Run("modified record", func(t *testing.T) {
+		lines := writeTestLog(t, 3)
+		lines[1] = strings.Replace(lines[1], `"i":1`, `"i":100`, 1)
+
+		report := verifyLines(t, lines)
+		require.Equal(t, []IssueKind{IssueModified, IssueBrokenChain}, issueKinds(report))
+		require.Equal(t, 2, report.Issues[0].Line)
+		require.Equal(t, uint64(2), report.Issues[0].Seq)
+	})
+
+	t.Run("modified record with recomputed hash", func(t *testing.T) {
+		lines := writeTestLog(t, 2)
+
+		var rewritten bytes.Buffer
+		chain, err := NewChain(&rewritten, &Options{Signer: NewHMACSigner([]byte("forged"))})
+		require.NoError(t, err)
+		NewLogger(chain).WithField("i", 0).Info("forged transfer")
+		lines[0] = strings.TrimSpace(rewritten.String())
+
+		report := verifyLines(t, lines)
+		require.Equal(t, []IssueKind{IssueBadSignature, IssueBrokenChain}, issueKinds(report))
+	})
+
+	t.Run("gap", func(t *testing.T) {
+		lines := writeTestLog(t, 4)
+		lines = append(lines[:1], lines[3:]...)
+
+		report := verifyLines(t, lines)
+		require.Equal(t, []IssueKind{IssueGap, IssueBrokenChain}, issueKinds(report))
+		require.Contains(t, report.Issues[0].Message, "2 records missing")
+	})
+
+	t.Run("removed first records", func(t *testing.T) {
+		lines := writeTestLog(t, 4)
+
+		report := verifyLines(t, lines[2:])
+		require.Equal(t, []IssueKind{IssueGap}, issueKinds(report))
+		require.Equal(t, uint64(3), report.FirstSeq)
+		require.Equal(t, 1, report.Issues[0].Line)
+		require.Contains(t, report.Issues[0].Message, "2 records missing before seq 3")
+	})
+
+	t.Run("removed last records", func(t *testing.T) {
+		lines := writeTestLog(t, 4)
+
+		var signed signedRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[3]), &signed))
+		anchor, err := ParseAnchor((&Anchor{Seq: 4, Hash: signed.Hash}).String())
+		require.NoError(t, err)
+
+		report, err := Verify(strings.NewReader(strings.Join(lines, "\n")), NewHMACSigner(testKey), anchor)
+		require.NoError(t, err)
+		require.True(t, report.OK(), report.Issues)
+
+		report, err = Verify(strings.NewReader(strings.Join(lines[:2], "\n")), NewHMACSigner(testKey), anchor)
+		require.NoError(t, err)
+		require.Equal(t, []IssueKind{IssueTruncated}, issueKinds(report))
+		require.Contains(t, report.Issues[0].Message, "2 records missing before anchor seq 4")
+
+		anchor.Hash = strings.Repeat("0", len(signed.Hash))
+		report, err = Verify(strings.NewReader(strings.Join(lines, "\n")), NewHMACSigner(testKey), anchor)
+		require.NoError(t, err)
+		require.Equal(t, []IssueKind{IssueModified}, issueKinds(report))
+	})
+
+	t.Run("reordered", func(t *testing.T) {
+		lines := writeTestLog(t, 3)
+		lines[1], lines[2] = lines[2], lines[1]
+
+		report := verifyLines(t, lines)
+		require.Equal(t, []IssueKind{
+			IssueGap, IssueBrokenChain,
+			IssueReordered, IssueBrokenChain,
+		}, issueKinds(report))
+	})
+
+	t.Run("malformed line", func(t *testing.T) {
+		lines := writeTestLog(t, 2)
+		lines = append(lines, `{"record":`)
+
+		report := verifyLines(t, lines)
+		require.Equal(t, []IssueKind{IssueMalformed}, issueKinds(report))
+		require.Equal(t, 2, report.Records)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		report, err := Verify(strings.NewReader(strings.Join(writeTestLog(t, 1), "\n")), NewHMACSigner([]byte("other")), nil)
+		require.NoError(t, err)
+		require.Equal(t, []IssueKind{IssueBadSignature}, issueKinds(report))
+	})
+}
@@ -0,0 +1,45 @@
+package suplog
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+// callerHook moves the original caller frame from entry fields into the
+// entry context, so the following hooks could report it instead of the stack.
+type callerHook struct{}
+
+func (h *callerHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *callerHook) Fire(e *logrus.Entry) error {
+	if e == nil {
+		return nil
+	}
+
+	v, ok := e.Data[stackcache.CallerFieldKey]
+	if !ok {
+		return nil
+	}
+
+	delete(e.Data, stackcache.CallerFieldKey)
+
+	frame, ok := v.(runtime.Frame)
+	if !ok {
+		return nil
+	}
+
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	e.Context = stackcache.WithCaller(ctx, frame)
+
+	return nil
+}
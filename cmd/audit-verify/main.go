@@ -0,0 +1,107 @@
+// Command audit-verify walks an audit log file written by the audit package,
+// and reports gaps, reorderings or modified records.
+//
+//	LOG_AUDIT_HMAC_KEY=... audit-verify audit.log
+//	audit-verify -ed25519-public-key <base64> audit.log
+//	audit-verify -anchor <seq>:<hash> audit.log
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/InjectiveLabs/suplog/audit"
+)
+
+func main() {
+	hmacKeyFile := flag.String("hmac-key-file", "", "file with the base64-encoded HMAC key, defaults to LOG_AUDIT_HMAC_KEY")
+	publicKey := flag.String("ed25519-public-key", "", "base64-encoded Ed25519 public key")
+	anchorStr := flag.String("anchor", "", "expected record of the log as <seq>:<hash>, e.g. the last one kept elsewhere, to detect truncation")
+	quiet := flag.Bool("q", false, "print issues only")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <audit log>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	verifier, err := newVerifier(*hmacKeyFile, *publicKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var anchor *audit.Anchor
+	if len(*anchorStr) > 0 {
+		if anchor, err = audit.ParseAnchor(*anchorStr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		report, err := audit.VerifyFile(path, verifier, anchor)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed = true
+			continue
+		}
+
+		for _, issue := range report.Issues {
+			fmt.Printf("%s: %s\n", path, issue)
+		}
+
+		if !report.OK() {
+			failed = true
+		}
+
+		if !*quiet {
+			fmt.Printf("%s: %d records, seq %d..%d, %d issues\n",
+				path, report.Records, report.FirstSeq, report.LastSeq, len(report.Issues))
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func newVerifier(hmacKeyFile, publicKey string) (audit.Verifier, error) {
+	if len(publicKey) > 0 {
+		key, err := audit.ParseEd25519PublicKey(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode Ed25519 public key: %w", err)
+		}
+
+		return audit.NewEd25519Verifier(key), nil
+	}
+
+	encoded := os.Getenv("LOG_AUDIT_HMAC_KEY")
+	if len(hmacKeyFile) > 0 {
+		data, err := os.ReadFile(hmacKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded = strings.TrimSpace(string(data))
+	}
+
+	if len(encoded) == 0 {
+		return nil, fmt.Errorf("no key, use -hmac-key-file, LOG_AUDIT_HMAC_KEY or -ed25519-public-key")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode HMAC key: %w", err)
+	}
+
+	return audit.NewHMACSigner(key), nil
+}
@@ -0,0 +1,120 @@
+// Command blob-decrypt downloads a blob uploaded by the blob hook, decrypts and decompresses it,
+// and verifies its checksum. The blob is either the reference from a log entry, or a key within
+// the store. Master keys are read from LOG_BLOB_MASTER_KEY or LOG_BLOB_MASTER_KEY_FILE, the store
+// is configured by the same ENV variables as the blob hook.
+//
+//	blob-decrypt -key-file master.keys s3://logs-bucket/prod/01H2X3Y4Z5 > body.json
+//	LOG_BLOB_STORE_URL=file:///var/lib/blobs blob-decrypt -meta prod/01H2X3Y4Z5
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	blobHook "github.com/InjectiveLabs/suplog/hooks/blob"
+)
+
+func main() {
+	storeURL := flag.String("url", os.Getenv("LOG_BLOB_STORE_URL"), "blob store URL, defaults to LOG_BLOB_STORE_URL")
+	keyFile := flag.String("key-file", "", "file with base64-encoded master keys, one per line, defaults to LOG_BLOB_MASTER_KEY_FILE")
+	output := flag.String("o", "", "write the blob into the file instead of stdout")
+	printMeta := flag.Bool("meta", false, "print blob metadata to stderr")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <blob reference or key>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if len(*keyFile) > 0 {
+		os.Setenv("LOG_BLOB_MASTER_KEY_FILE", *keyFile)
+	}
+
+	if len(*storeURL) == 0 && len(os.Getenv("LOG_BLOB_STORE_BUCKET")) > 0 {
+		*storeURL = "s3://" + os.Getenv("LOG_BLOB_STORE_BUCKET")
+	}
+
+	blobURL, key := splitRef(*storeURL, flag.Arg(0))
+	if !blobHook.IsBlobStoreURL(blobURL) {
+		fmt.Fprintf(os.Stderr, "unsupported blob store URL: %q\n", blobURL)
+		os.Exit(2)
+	}
+
+	store, err := blobHook.NewBlobStore(blobURL, &blobHook.StoreCredentials{
+		Account:  os.Getenv("LOG_BLOB_STORE_ACCOUNT"),
+		Key:      os.Getenv("LOG_BLOB_STORE_KEY"),
+		Endpoint: os.Getenv("LOG_BLOB_STORE_ENDPOINT"),
+		Region:   os.Getenv("LOG_BLOB_STORE_REGION"),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// plain blobs are decoded without keys
+	var kms blobHook.KMS
+	if localKMS, err := blobHook.LocalKMSFromEnv(); err == nil {
+		kms = localKMS
+	} else if err != blobHook.ErrNoMasterKey {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	data, spec, err := blobHook.ReadBlob(store, key, kms)
+	if spec != nil && *printMeta {
+		names := make([]string, 0, len(spec.Meta))
+		for name := range spec.Meta {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, spec.Meta[name])
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", key, err)
+		os.Exit(1)
+	}
+
+	if len(*output) > 0 {
+		err = os.WriteFile(*output, data, 0o600)
+	} else {
+		_, err = os.Stdout.Write(data)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// splitRef returns the store URL and the key of the blob reference, which is
+// either a key within the store, or <store URL>/<env>/<blob ID> as put into log entries.
+func splitRef(storeURL, ref string) (string, string) {
+	if !strings.Contains(ref, "://") {
+		return storeURL, ref
+	}
+
+	// the store URL could have query params, stripped in references
+	base, _, _ := strings.Cut(storeURL, "?")
+	if len(base) > 0 && strings.HasPrefix(ref, strings.TrimSuffix(base, "/")+"/") {
+		return storeURL, strings.TrimPrefix(ref, strings.TrimSuffix(base, "/")+"/")
+	}
+
+	// path.Dir can't be used, since it cleans the scheme separator
+	i := strings.LastIndex(ref, "/")
+	if i := strings.LastIndex(ref[:i], "/"); i > 0 {
+		return ref[:i], ref[i+1:]
+	}
+
+	return storeURL, ref
+}
@@ -0,0 +1,97 @@
+// Command blob-janitor deletes blobs past retention, for blob stores without
+// lifecycle rules, like filesystem or MinIO without ILM. The store is configured
+// by the same ENV variables as the blob hook.
+//
+//	LOG_BLOB_STORE_URL=file:///var/lib/blobs blob-janitor -prefix prod -ttl 720h
+//	blob-janitor -url s3://logs-bucket -prefix prod -install-lifecycle
+//
+// An empty prefix, e.g. with APP_ENV unset, is refused, pass -all to select every blob in the store.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	blobHook "github.com/InjectiveLabs/suplog/hooks/blob"
+)
+
+func main() {
+	storeURL := flag.String("url", os.Getenv("LOG_BLOB_STORE_URL"), "blob store URL, defaults to LOG_BLOB_STORE_URL")
+	prefix := flag.String("prefix", os.Getenv("APP_ENV"), "prefix of blobs, usually the env, defaults to APP_ENV")
+	ttl := flag.Duration("ttl", blobHook.DefaultRenentionTTL, "retention of blobs without expiration metadata")
+	dryRun := flag.Bool("dry-run", false, "only report expired blobs")
+	installLifecycle := flag.Bool("install-lifecycle", false, "install lifecycle rule instead, for stores supporting it")
+	all := flag.Bool("all", false, "select every blob in the store instead of the prefix")
+	flag.Parse()
+
+	prefixSet := false
+	flag.Visit(func(f *flag.Flag) {
+		prefixSet = prefixSet || f.Name == "prefix"
+	})
+
+	switch {
+	case *all && prefixSet:
+		fmt.Fprintln(os.Stderr, "-all and -prefix are mutually exclusive")
+		os.Exit(2)
+	case *all:
+		*prefix = blobHook.AllBlobs
+	case len(*prefix) == 0:
+		fmt.Fprintln(os.Stderr, "empty prefix, set -prefix or APP_ENV, or pass -all to select every blob in the store")
+		os.Exit(2)
+	}
+
+	if len(*storeURL) == 0 && len(os.Getenv("LOG_BLOB_STORE_BUCKET")) > 0 {
+		*storeURL = "s3://" + os.Getenv("LOG_BLOB_STORE_BUCKET")
+	}
+
+	if !blobHook.IsBlobStoreURL(*storeURL) {
+		fmt.Fprintf(os.Stderr, "unsupported blob store URL: %q\n", *storeURL)
+		os.Exit(2)
+	}
+
+	store, err := blobHook.NewBlobStore(*storeURL, &blobHook.StoreCredentials{
+		Account:  os.Getenv("LOG_BLOB_STORE_ACCOUNT"),
+		Key:      os.Getenv("LOG_BLOB_STORE_KEY"),
+		Endpoint: os.Getenv("LOG_BLOB_STORE_ENDPOINT"),
+		Region:   os.Getenv("LOG_BLOB_STORE_REGION"),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *installLifecycle {
+		lifecycle, ok := store.(blobHook.LifecycleStore)
+		if !ok {
+			fmt.Fprintln(os.Stderr, blobHook.ErrNotSupported)
+			os.Exit(1)
+		}
+
+		if err := lifecycle.EnsureLifecycle(*prefix, *ttl, true); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to install lifecycle rule: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("blobs under %q expire in %s\n", *prefix, *ttl)
+		return
+	}
+
+	start := time.Now()
+	stats, err := blobHook.DeleteExpired(store, *prefix, *ttl, *dryRun)
+	if stats != nil {
+		verb := "deleted"
+		if *dryRun {
+			verb = "would delete"
+		}
+
+		fmt.Printf("scanned %d blobs, %s %d blobs, %d bytes in %s\n",
+			stats.Scanned, verb, stats.Deleted, stats.Bytes, time.Since(start).Round(time.Millisecond))
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -191,4 +191,38 @@ func TestDefer(t *testing.T) {
 		require.NotContains(t, out, "error=")
 		require.Contains(t, out, "debug")
 	})
+
+	t.Run("deferred values are redacted", func(t *testing.T) {
+		t.Setenv("LOG_REDACT_ENABLED", "true")
+
+		var recorder strings.Builder
+		l := &suplogger{writer: &recorder}
+
+		done := make(chan struct{})
+		var (
+			password string
+			contact  string
+			err      error
+		)
+
+		go func() {
+			defer close(done)
+			defer l.Defer("password", &password).
+				Defer("contact", &contact).
+				DeferError(&err).
+				ErrLevel(WarnLevel).
+				Infof("deferred secrets")
+
+			password = "hunter2-supersecret"
+			contact = "alice@example.com"
+			err = errors.New("failed to notify alice@example.com")
+		}()
+		<-done
+
+		out := recorder.String()
+		require.NotContains(t, out, "hunter2-supersecret")
+		require.NotContains(t, out, "alice@example.com")
+		require.Contains(t, out, `"password":"[REDACTED]"`)
+		require.Contains(t, out, `"level":"warning"`)
+	})
 }
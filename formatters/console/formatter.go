@@ -0,0 +1,317 @@
+// Package console provides a human-friendly formatter for local development.
+package console
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+// ColorMode controls colored output.
+type ColorMode int
+
+const (
+	// ColorAuto enables colors if the output is a terminal and NO_COLOR is not set.
+	ColorAuto ColorMode = iota
+	// ColorAlways enables colors.
+	ColorAlways
+	// ColorNever disables colors.
+	ColorNever
+)
+
+// FormatterOptions allows to set additional Formatter options.
+type FormatterOptions struct {
+	// Colors controls colored output, detected by default.
+	Colors ColorMode
+	// FullTimestamp prints wall clock time, instead of time relative to the formatter start.
+	FullTimestamp bool
+	// TimestampFormat is used with FullTimestamp, defaults to "15:04:05.000".
+	TimestampFormat string
+	// PathSegmentsLimit allows to trim amount of source code file path segments
+	// in stack frames, same as in the debug hook. Defaults to 3.
+	PathSegmentsLimit int
+	// StackTraceOffset allows to wrap logger into greater stack depth and still
+	// get reports on accurate positions.
+	StackTraceOffset int
+}
+
+func checkFormatterOptions(opt *FormatterOptions) *FormatterOptions {
+	if opt == nil {
+		opt = &FormatterOptions{}
+	}
+
+	if len(opt.TimestampFormat) == 0 {
+		opt.TimestampFormat = "15:04:05.000"
+	}
+
+	if opt.PathSegmentsLimit == 0 {
+		opt.PathSegmentsLimit = 3
+	}
+
+	return opt
+}
+
+const defaultStackSearchOffset = 3
+
+// NewFormatter returns a formatter that prints entries with aligned level badges, fields
+// as indented key/values, and errors with their errors.Unwrap chain and stack frames,
+// recorded by pkg/errors or captured at the log call.
+func NewFormatter(opt *FormatterOptions) logrus.Formatter {
+	opt = checkFormatterOptions(opt)
+
+	return &formatter{
+		opt:   opt,
+		start: time.Now(),
+		stack: stackcache.New(defaultStackSearchOffset, opt.StackTraceOffset, "github.com/InjectiveLabs/suplog"),
+	}
+}
+
+type formatter struct {
+	opt   *FormatterOptions
+	start time.Time
+	stack stackcache.StackCache
+
+	colorsOnce sync.Once
+	colors     bool
+}
+
+const (
+	colorRed    = 31
+	colorYellow = 33
+	colorBlue   = 36
+	colorGray   = 90
+)
+
+func levelColor(level logrus.Level) int {
+	switch level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return colorGray
+	case logrus.InfoLevel:
+		return colorBlue
+	case logrus.WarnLevel:
+		return colorYellow
+	default:
+		return colorRed
+	}
+}
+
+func levelBadge(level logrus.Level) string {
+	switch level {
+	case logrus.WarnLevel:
+		return "WARN"
+	default:
+		return strings.ToUpper(level.String())
+	}
+}
+
+// fieldIndent aligns fields and errors with the message.
+const fieldIndent = "    "
+
+func (f *formatter) Format(e *logrus.Entry) ([]byte, error) {
+	f.colorsOnce.Do(func() {
+		f.colors = f.detectColors(e)
+	})
+
+	b := e.Buffer
+	if b == nil {
+		b = new(bytes.Buffer)
+	}
+
+	color := levelColor(e.Level)
+	f.paint(b, color, fmt.Sprintf("%-5s", levelBadge(e.Level)), true)
+	b.WriteByte(' ')
+
+	if f.opt.FullTimestamp {
+		f.paint(b, colorGray, e.Time.Format(f.opt.TimestampFormat), false)
+	} else {
+		f.paint(b, colorGray, fmt.Sprintf("+%7.3fs", e.Time.Sub(f.start).Seconds()), false)
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(strings.TrimRight(e.Message, "\n"))
+
+	if src, ok := e.Data["src"].(string); ok {
+		b.WriteByte(' ')
+		f.paint(b, colorGray, "("+src+")", false)
+	}
+
+	b.WriteByte('\n')
+
+	keys := make([]string, 0, len(e.Data))
+	width := 0
+	for k := range e.Data {
+		switch k {
+		case "src", "fn", logrus.ErrorKey:
+			continue
+		}
+
+		keys = append(keys, k)
+		if len(k) > width {
+			width = len(k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		b.WriteString(fieldIndent)
+		f.paint(b, color, fmt.Sprintf("%-*s", width, k), false)
+		b.WriteString(" = ")
+		writeIndented(b, fieldValue(e.Data[k]), fieldIndent+strings.Repeat(" ", width+3))
+		b.WriteByte('\n')
+	}
+
+	if v, ok := e.Data[logrus.ErrorKey]; ok {
+		if err, ok := v.(error); ok {
+			f.writeError(b, e, err)
+		} else {
+			b.WriteString(fieldIndent)
+			f.paint(b, colorRed, "error", false)
+			fmt.Fprintf(b, ": %v\n", v)
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeError walks the errors.Unwrap chain, printing each distinct message
+// and the stack frames recorded along the way.
+func (f *formatter) writeError(b *bytes.Buffer, e *logrus.Entry, err error) {
+	label := "error"
+	prevMsg := ""
+	hasStack := false
+
+	for ; err != nil; err = errors.Unwrap(err) {
+		msg := err.Error()
+		if msg != prevMsg {
+			b.WriteString(fieldIndent)
+			f.paint(b, colorRed, label, false)
+			b.WriteString(": ")
+			writeIndented(b, msg, fieldIndent+"  ")
+			b.WriteByte('\n')
+
+			label = "caused by"
+			prevMsg = msg
+		}
+
+		if frames := errorFrames(err); len(frames) > 0 {
+			f.writeFrames(b, frames)
+			hasStack = true
+		}
+	}
+
+	if !hasStack {
+		if caller, ok := stackcache.CallerFromContext(e.Context); ok {
+			f.writeFrames(b, []runtime.Frame{caller})
+		} else if frames, ok := stackcache.StackFromContext(e.Context); ok {
+			f.writeFrames(b, frames)
+		} else {
+			f.writeFrames(b, f.stack.GetStackFrames())
+		}
+	}
+}
+
+type pkgErrorsStackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// errorFrames returns the stack recorded by pkg/errors in this very error.
+func errorFrames(err error) []runtime.Frame {
+	if tracer, ok := err.(pkgErrorsStackTracer); ok {
+		return stackcache.PkgErrorsFrames(tracer.StackTrace())
+	}
+
+	return nil
+}
+
+func (f *formatter) writeFrames(b *bytes.Buffer, frames []runtime.Frame) {
+	for _, frame := range frames {
+		b.WriteString(fieldIndent + "  at ")
+		b.WriteString(shortFunction(frame.Function))
+		b.WriteByte(' ')
+		f.paint(b, colorGray, fmt.Sprintf("%s:%d", stackcache.LimitPath(frame.File, f.opt.PathSegmentsLimit), frame.Line), false)
+		b.WriteByte('\n')
+	}
+}
+
+func (f *formatter) paint(b *bytes.Buffer, color int, s string, bold bool) {
+	if !f.colors {
+		b.WriteString(s)
+		return
+	}
+
+	if bold {
+		fmt.Fprintf(b, "\x1b[1;%dm%s\x1b[0m", color, s)
+		return
+	}
+
+	fmt.Fprintf(b, "\x1b[%dm%s\x1b[0m", color, s)
+}
+
+func (f *formatter) detectColors(e *logrus.Entry) bool {
+	switch f.opt.Colors {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	// see https://no-color.org
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	if os.Getenv("TERM") == "dumb" || e.Logger == nil {
+		return false
+	}
+
+	file, ok := e.Logger.Out.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// shortFunction trims the package path, keeping the package name.
+func shortFunction(fn string) string {
+	if idx := strings.LastIndexByte(fn, '/'); idx >= 0 {
+		return fn[idx+1:]
+	}
+
+	return fn
+}
+
+func fieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprintf("%+v", v)
+	}
+}
+
+// writeIndented writes multi-line values, indenting the lines after the first.
+func writeIndented(b *bytes.Buffer, s, indent string) {
+	s = strings.TrimRight(s, "\n")
+	b.WriteString(strings.ReplaceAll(s, "\n", "\n"+indent))
+}
@@ -0,0 +1,71 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	log "github.com/InjectiveLabs/suplog"
+)
+
+func TestFormatter(t *testing.T) {
+	t.Run("badges and fields", func(t *testing.T) {
+		var recorder strings.Builder
+		log.NewLogger(&recorder, NewFormatter(nil)).WithFields(log.Fields{
+			"user":  "alice",
+			"count": 3,
+			"note":  "line one\nline two",
+		}).Warning("hello")
+
+		lines := strings.Split(strings.TrimSpace(recorder.String()), "\n")
+		require.Len(t, lines, 5)
+		require.True(t, strings.HasPrefix(lines[0], "WARN  +  0."), lines[0])
+		require.True(t, strings.HasSuffix(lines[0], " hello"), lines[0])
+		require.Equal(t, "    count = 3", lines[1])
+		require.Equal(t, "    note  = line one", lines[2])
+		require.Equal(t, "            line two", lines[3])
+		require.Equal(t, "    user  = alice", lines[4])
+		require.NotContains(t, recorder.String(), "\x1b[")
+	})
+
+	t.Run("error chain with pkg/errors stack", func(t *testing.T) {
+		var recorder strings.Builder
+		err := fmt.Errorf("request failed: %w", pkgerrors.Wrap(errors.New("connection refused"), "dial"))
+		log.NewLogger(&recorder, NewFormatter(nil)).WithError(err).Error("failed")
+
+		out := recorder.String()
+		require.Contains(t, out, "    error: request failed: dial: connection refused\n")
+		require.Contains(t, out, "    caused by: dial: connection refused\n")
+		require.Contains(t, out, "    caused by: connection refused\n")
+		require.Contains(t, out, "      at console.TestFormatter.func2 formatters/console/formatter_test.go:")
+	})
+
+	t.Run("error without stack uses log call stack", func(t *testing.T) {
+		var recorder strings.Builder
+		log.NewLogger(&recorder, NewFormatter(nil)).WithError(errors.New("plain")).Error("failed")
+
+		out := recorder.String()
+		require.Contains(t, out, "    error: plain\n")
+		require.Contains(t, out, "console/formatter_test.go:")
+	})
+
+	t.Run("colors", func(t *testing.T) {
+		var recorder strings.Builder
+		log.NewLogger(&recorder, NewFormatter(&FormatterOptions{
+			Colors: ColorAlways,
+		})).Error("colored")
+
+		require.Contains(t, recorder.String(), "\x1b[1;31mERROR\x1b[0m")
+	})
+
+	t.Run("NO_COLOR", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+
+		f := NewFormatter(nil).(*formatter)
+		require.False(t, f.detectColors(&log.Entry{}))
+	})
+}
@@ -0,0 +1,304 @@
+// Package ecs provides a formatter of Elastic Common Schema JSON,
+// see https://www.elastic.co/guide/en/ecs/current/index.html
+package ecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+// Version is the ECS version reported in ecs.version field.
+const Version = "8.11.0"
+
+// LabelsNamespace is the ECS namespace of custom keyword fields.
+const LabelsNamespace = "labels"
+
+// FormatterOptions allows to set additional Formatter options.
+type FormatterOptions struct {
+	// ServiceName is reported as service.name.
+	ServiceName string
+	// ServiceVersion is reported as service.version, the debug hook "ver" field is used otherwise.
+	ServiceVersion string
+	// Environment is reported as service.environment.
+	Environment string
+	// Namespace holds custom fields that are not part of ECS, defaults to "labels".
+	// Values under "labels" are converted to strings, as ECS requires.
+	Namespace string
+	// StackTraceOffset allows to wrap logger into greater stack depth and still
+	// get reports on accurate positions.
+	StackTraceOffset int
+}
+
+func checkFormatterOptions(opt *FormatterOptions) *FormatterOptions {
+	if opt == nil {
+		opt = &FormatterOptions{}
+	}
+
+	if len(opt.ServiceVersion) == 0 {
+		opt.ServiceVersion = os.Getenv("APP_VERSION")
+	}
+
+	if len(opt.Environment) == 0 {
+		opt.Environment = os.Getenv("APP_ENV")
+	}
+
+	if len(opt.Namespace) == 0 {
+		opt.Namespace = os.Getenv("LOG_ECS_NAMESPACE")
+		if len(opt.Namespace) == 0 {
+			opt.Namespace = LabelsNamespace
+		}
+	}
+
+	return opt
+}
+
+const defaultStackSearchOffset = 3
+
+// NewFormatter returns a formatter of ECS JSON with nested objects. Fields of known ECS
+// field sets, e.g. "http.request.method" or "user.id", are kept in place, others, including
+// bare field set names like "service" and fields set by the formatter itself like "log.level",
+// are moved under the namespace. Debug hook fields "src", "fn" and "ver" are mapped into
+// log.origin and service.version.
+func NewFormatter(opt *FormatterOptions) logrus.Formatter {
+	opt = checkFormatterOptions(opt)
+
+	return &formatter{
+		opt:   opt,
+		stack: stackcache.New(defaultStackSearchOffset, opt.StackTraceOffset, "github.com/InjectiveLabs/suplog"),
+	}
+}
+
+type formatter struct {
+	opt   *FormatterOptions
+	stack stackcache.StackCache
+}
+
+// fieldSets lists top-level ECS field sets, fields under them are not custom.
+// Besides these, only "tags" is a top-level ECS field.
+var fieldSets = map[string]bool{
+	"agent": true, "client": true, "cloud": true, "container": true, "data_stream": true,
+	"destination": true, "device": true, "dll": true, "dns": true, "ecs": true, "email": true,
+	"error": true, "event": true, "faas": true, "file": true, "group": true, "host": true,
+	"http": true, "log": true, "network": true, "observer": true, "orchestrator": true,
+	"organization": true, "package": true, "process": true, "registry": true, "related": true,
+	"rule": true, "server": true, "service": true, "source": true, "span": true, "threat": true,
+	"tls": true, "trace": true, "transaction": true, "url": true, "user": true, "user_agent": true,
+	"vulnerability": true, "labels": true,
+}
+
+// corePaths are set by the formatter, entry fields must not replace them.
+var corePaths = []string{
+	"ecs.version", "log.level", "log.origin.file.name", "log.origin.file.line", "log.origin.function",
+	"error.message", "error.type", "error.stack_trace", "service.name", "service.environment",
+	"service.version", "trace.id", "span.id",
+}
+
+// isCore reports whether the field would replace a core field, or an object holding one.
+func isCore(k string) bool {
+	for _, p := range corePaths {
+		if k == p || strings.HasPrefix(p, k+".") || strings.HasPrefix(k, p+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *formatter) Format(e *logrus.Entry) ([]byte, error) {
+	out := make(map[string]interface{}, 8)
+
+	setPath(out, "@timestamp", e.Time.UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	setPath(out, "log.level", e.Level.String())
+	setPath(out, "message", e.Message)
+	setPath(out, "ecs.version", Version)
+
+	if len(f.opt.ServiceName) > 0 {
+		setPath(out, "service.name", f.opt.ServiceName)
+	}
+
+	if len(f.opt.Environment) > 0 {
+		setPath(out, "service.environment", f.opt.Environment)
+	}
+
+	serviceVersion := f.opt.ServiceVersion
+	f.setOrigin(out, e)
+
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := e.Data[k]
+
+		switch k {
+		case "src", "fn":
+			// mapped into log.origin
+			continue
+		case "ver":
+			if len(serviceVersion) == 0 {
+				serviceVersion = fmt.Sprint(v)
+			}
+
+			continue
+		case logrus.ErrorKey:
+			if err, ok := v.(error); ok {
+				f.setError(out, e, err)
+				continue
+			}
+		}
+
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+
+		// bare field set names, e.g. "service", would replace ECS objects and are not custom
+		if set, _, dotted := strings.Cut(k, "."); (dotted && fieldSets[set] && !isCore(k)) || k == "tags" {
+			setPath(out, k, v)
+		} else if f.opt.Namespace == LabelsNamespace {
+			// labels are flat keyword fields
+			setPath(out, LabelsNamespace+"."+strings.ReplaceAll(k, ".", "_"), labelValue(v))
+		} else {
+			setPath(out, f.opt.Namespace+"."+k, v)
+		}
+	}
+
+	if len(serviceVersion) > 0 {
+		setPath(out, "service.version", serviceVersion)
+	}
+
+	if e.Context != nil {
+		if spanCtx := trace.SpanContextFromContext(e.Context); spanCtx.IsValid() {
+			setPath(out, "trace.id", spanCtx.TraceID().String())
+			setPath(out, "span.id", spanCtx.SpanID().String())
+		}
+	}
+
+	b := e.Buffer
+	if b == nil {
+		b = new(bytes.Buffer)
+	}
+
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(out); err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON, %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// setOrigin maps debug hook fields, or the reported caller, into log.origin.
+func (f *formatter) setOrigin(out map[string]interface{}, e *logrus.Entry) {
+	if src, ok := e.Data["src"].(string); ok {
+		file := src
+		if idx := strings.LastIndexByte(src, ':'); idx > 0 {
+			if line, err := strconv.Atoi(src[idx+1:]); err == nil {
+				file = src[:idx]
+				setPath(out, "log.origin.file.line", line)
+			}
+		}
+
+		setPath(out, "log.origin.file.name", file)
+
+		if fn, ok := e.Data["fn"].(string); ok {
+			setPath(out, "log.origin.function", fn)
+		}
+
+		return
+	}
+
+	caller, ok := stackcache.CallerFromContext(e.Context)
+	if !ok && e.HasCaller() {
+		caller, ok = *e.Caller, true
+	}
+
+	if ok {
+		setPath(out, "log.origin.file.name", caller.File)
+		setPath(out, "log.origin.file.line", caller.Line)
+		setPath(out, "log.origin.function", caller.Function)
+	}
+}
+
+// setError maps the error, with the stack recorded by pkg/errors or the stack of the log call.
+func (f *formatter) setError(out map[string]interface{}, e *logrus.Entry, err error) {
+	setPath(out, "error.message", err.Error())
+	setPath(out, "error.type", fmt.Sprintf("%T", err))
+
+	frames, ok := stackcache.ErrorFrames(err)
+	if !ok {
+		if caller, ok := stackcache.CallerFromContext(e.Context); ok {
+			frames = []runtime.Frame{caller}
+		} else if frames, ok = stackcache.StackFromContext(e.Context); !ok {
+			frames = f.stack.GetStackFrames()
+		}
+	}
+
+	if len(frames) > 0 {
+		var b strings.Builder
+		for _, frame := range frames {
+			fmt.Fprintf(&b, "%s()\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+
+		setPath(out, "error.stack_trace", b.String())
+	}
+}
+
+// setPath sets the value in nested objects by dotted path. If a path segment
+// is already taken by a non-object value, the rest of the path is kept dotted.
+func setPath(out map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	m := out
+
+	for i, part := range parts[:len(parts)-1] {
+		next, ok := m[part]
+		if !ok {
+			child := make(map[string]interface{})
+			m[part] = child
+			m = child
+			continue
+		}
+
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			m[strings.Join(parts[i:], ".")] = value
+			return
+		}
+
+		m = child
+	}
+
+	m[parts[len(parts)-1]] = value
+}
+
+func labelValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	case nil:
+		return ""
+	}
+
+	switch v.(type) {
+	case map[string]interface{}, logrus.Fields, []interface{}:
+		if out, err := json.Marshal(v); err == nil {
+			return string(out)
+		}
+	}
+
+	return fmt.Sprint(v)
+}
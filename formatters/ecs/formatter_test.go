@@ -0,0 +1,157 @@
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	log "github.com/InjectiveLabs/suplog"
+	debugHook "github.com/InjectiveLabs/suplog/hooks/debug"
+)
+
+func TestFormatter(t *testing.T) {
+	var recorder strings.Builder
+
+	newLogger := func(opt *FormatterOptions) log.Logger {
+		return log.NewLogger(&recorder, NewFormatter(opt), debugHook.NewHook(log.DefaultLogger, &debugHook.HookOptions{
+			Levels:     logrus.AllLevels,
+			AppVersion: "v1.2.3",
+		}))
+	}
+
+	decode := func() map[string]interface{} {
+		var out map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(recorder.String()), &out))
+		recorder.Reset()
+
+		return out
+	}
+
+	t.Run("maps entry into nested ECS", func(t *testing.T) {
+		newLogger(&FormatterOptions{ServiceName: "api"}).WithFields(log.Fields{
+			"http.request.method": "GET",
+			"user.id":             "42",
+			"module":              "accounts",
+			"retry.count":         3,
+		}).Info("hello")
+
+		out := decode()
+		require.NotEmpty(t, out["@timestamp"])
+		require.Equal(t, "hello", out["message"])
+		require.Equal(t, Version, out["ecs"].(map[string]interface{})["version"])
+		require.Equal(t, map[string]interface{}{"name": "api", "version": "v1.2.3"}, out["service"])
+		require.Equal(t, map[string]interface{}{"request": map[string]interface{}{"method": "GET"}}, out["http"])
+		require.Equal(t, map[string]interface{}{"id": "42"}, out["user"])
+		require.Equal(t, map[string]interface{}{"module": "accounts", "retry_count": "3"}, out["labels"])
+
+		logObj := out["log"].(map[string]interface{})
+		require.Equal(t, "info", logObj["level"])
+
+		origin := logObj["origin"].(map[string]interface{})
+		file := origin["file"].(map[string]interface{})
+		require.True(t, strings.HasSuffix(file["name"].(string), "formatter_test.go"), file)
+		require.NotZero(t, file["line"])
+		require.NotEmpty(t, origin["function"])
+
+		require.NotContains(t, out, "src")
+		require.NotContains(t, out, "ver")
+	})
+
+	t.Run("bare field set names are custom", func(t *testing.T) {
+		newLogger(&FormatterOptions{ServiceName: "api", Environment: "prod"}).WithFields(log.Fields{
+			"service": "billing",
+			"log":     "audit",
+			"user":    "alice",
+			"error":   "not an error",
+			"tags":    []string{"retry"},
+		}).Warning("hello")
+
+		out := decode()
+		require.Equal(t, map[string]interface{}{"name": "api", "environment": "prod", "version": "v1.2.3"}, out["service"])
+		require.Equal(t, "warning", out["log"].(map[string]interface{})["level"])
+		require.Contains(t, out["log"], "origin")
+		require.NotContains(t, out, "user")
+		require.NotContains(t, out, "error")
+		require.Equal(t, []interface{}{"retry"}, out["tags"])
+		require.Equal(t, map[string]interface{}{
+			"service": "billing",
+			"log":     "audit",
+			"user":    "alice",
+			"error":   "not an error",
+		}, out["labels"])
+	})
+
+	t.Run("core fields are not replaced", func(t *testing.T) {
+		newLogger(nil).WithFields(log.Fields{
+			"log.level":     "debug",
+			"ecs.version":   "1.0.0",
+			"error.message": "fake",
+			"error.code":    "E42",
+		}).WithError(errors.New("real")).Error("failed")
+
+		out := decode()
+		require.Equal(t, "error", out["log"].(map[string]interface{})["level"])
+		require.Equal(t, Version, out["ecs"].(map[string]interface{})["version"])
+
+		errObj := out["error"].(map[string]interface{})
+		require.Equal(t, "real", errObj["message"])
+		require.Equal(t, "E42", errObj["code"])
+		require.Equal(t, map[string]interface{}{
+			"log_level":     "debug",
+			"ecs_version":   "1.0.0",
+			"error_message": "fake",
+		}, out["labels"])
+	})
+
+	t.Run("custom namespace", func(t *testing.T) {
+		newLogger(&FormatterOptions{Namespace: "app"}).WithField("retry.count", 3).Info("hello")
+
+		out := decode()
+		require.Equal(t, map[string]interface{}{"retry": map[string]interface{}{"count": float64(3)}}, out["app"])
+		require.NotContains(t, out, "labels")
+	})
+
+	t.Run("error with pkg/errors stack", func(t *testing.T) {
+		err := pkgerrors.Wrap(pkgerrors.New("boom"), "wrapped")
+		newLogger(nil).WithError(err).Error("failed")
+
+		errObj := decode()["error"].(map[string]interface{})
+		require.Equal(t, "wrapped: boom", errObj["message"])
+		require.Equal(t, "*errors.withStack", errObj["type"])
+		require.Contains(t, errObj["stack_trace"], "formatter_test.go")
+	})
+
+	t.Run("error with logger stack", func(t *testing.T) {
+		newLogger(nil).WithError(errors.New("plain")).Error("failed")
+
+		errObj := decode()["error"].(map[string]interface{})
+		require.Equal(t, "plain", errObj["message"])
+		require.Contains(t, errObj["stack_trace"], "formatter_test.go")
+	})
+
+	t.Run("error with logger stack of async output", func(t *testing.T) {
+		l := log.NewLogger(&recorder, NewFormatter(nil), log.AsyncOutput(nil)).(log.AsyncLogger)
+		l.WithError(errors.New("plain")).Error("failed")
+		require.NoError(t, l.Close())
+
+		errObj := decode()["error"].(map[string]interface{})
+		require.Contains(t, errObj["stack_trace"], "formatter_test.go")
+	})
+
+	t.Run("trace from context", func(t *testing.T) {
+		ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "op")
+		newLogger(nil).WithContext(ctx).Info("in span")
+		span.End()
+
+		out := decode()
+		require.Equal(t, span.SpanContext().TraceID().String(), out["trace"].(map[string]interface{})["id"])
+		require.Equal(t, span.SpanContext().SpanID().String(), out["span"].(map[string]interface{})["id"])
+	})
+}
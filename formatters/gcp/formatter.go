@@ -0,0 +1,309 @@
+// Package gcp provides a formatter of Google Cloud Logging structured JSON,
+// see https://cloud.google.com/logging/docs/structured-logging
+package gcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+// FormatterOptions allows to set additional Formatter options.
+type FormatterOptions struct {
+	// ProjectID is used to build the trace resource name, trace is omitted without it.
+	ProjectID string
+	// Service is reported in serviceContext for Error Reporting.
+	Service string
+	// Version is reported in serviceContext for Error Reporting.
+	Version string
+	// Labels are added to each entry.
+	Labels map[string]string
+	// LabelFields lists entry fields moved into labels.
+	LabelFields []string
+	// StackTraceOffset allows to wrap logger into greater stack depth and still
+	// get reports on accurate positions.
+	StackTraceOffset int
+}
+
+func checkFormatterOptions(opt *FormatterOptions) *FormatterOptions {
+	if opt == nil {
+		opt = &FormatterOptions{}
+	}
+
+	if len(opt.ProjectID) == 0 {
+		opt.ProjectID = os.Getenv("LOG_GCP_PROJECT_ID")
+		if len(opt.ProjectID) == 0 {
+			opt.ProjectID = os.Getenv("GOOGLE_CLOUD_PROJECT")
+		}
+	}
+
+	if len(opt.Service) == 0 {
+		// set by Cloud Run and Knative
+		opt.Service = os.Getenv("K_SERVICE")
+	}
+
+	if len(opt.Version) == 0 {
+		opt.Version = os.Getenv("APP_VERSION")
+	}
+
+	return opt
+}
+
+// Special keys of Cloud Logging structured JSON.
+const (
+	KeySeverity       = "severity"
+	KeyMessage        = "message"
+	KeyTimestamp      = "timestamp"
+	KeySourceLocation = "logging.googleapis.com/sourceLocation"
+	KeyTrace          = "logging.googleapis.com/trace"
+	KeySpanID         = "logging.googleapis.com/spanId"
+	KeyTraceSampled   = "logging.googleapis.com/trace_sampled"
+	KeyLabels         = "logging.googleapis.com/labels"
+	KeyStackTrace     = "stack_trace"
+	KeyServiceContext = "serviceContext"
+	KeyType           = "@type"
+)
+
+// reportedErrorEventType makes Error Reporting pick up the entry.
+const reportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+const defaultStackSearchOffset = 3
+
+// NewFormatter returns a formatter of Cloud Logging structured JSON. Source location
+// is taken from the debug hook fields "src" and "fn", or the caller reported by logger.
+// Trace and span IDs are taken from the span in the entry context, see Logger.WithContext.
+func NewFormatter(opt *FormatterOptions) logrus.Formatter {
+	opt = checkFormatterOptions(opt)
+
+	return &formatter{
+		opt:   opt,
+		stack: stackcache.New(defaultStackSearchOffset, opt.StackTraceOffset, "github.com/InjectiveLabs/suplog"),
+	}
+}
+
+type formatter struct {
+	opt   *FormatterOptions
+	stack stackcache.StackCache
+}
+
+// Severity maps logrus levels to Cloud Logging severities.
+func Severity(level logrus.Level) string {
+	switch level {
+	case logrus.PanicLevel:
+		return "ALERT"
+	case logrus.FatalLevel:
+		return "CRITICAL"
+	case logrus.ErrorLevel:
+		return "ERROR"
+	case logrus.WarnLevel:
+		return "WARNING"
+	case logrus.InfoLevel:
+		return "INFO"
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return "DEBUG"
+	default:
+		return "DEFAULT"
+	}
+}
+
+type sourceLocation struct {
+	File     string `json:"file,omitempty"`
+	Line     string `json:"line,omitempty"`
+	Function string `json:"function,omitempty"`
+}
+
+type serviceContext struct {
+	Service string `json:"service,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+func (f *formatter) Format(e *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(e.Data)+8)
+	for k, v := range e.Data {
+		data[k] = v
+	}
+
+	out := make(map[string]interface{}, len(data)+8)
+
+	if loc, ok := f.sourceLocation(e, data); ok {
+		out[KeySourceLocation] = loc
+	}
+
+	version := f.opt.Version
+	if ver, ok := data["ver"].(string); ok {
+		if len(version) == 0 {
+			version = ver
+		}
+
+		delete(data, "ver")
+	}
+
+	labels := make(map[string]string, len(f.opt.Labels)+len(f.opt.LabelFields))
+	for k, v := range f.opt.Labels {
+		labels[k] = v
+	}
+
+	for _, k := range f.opt.LabelFields {
+		if v, ok := data[k]; ok {
+			labels[k] = fmt.Sprint(v)
+			delete(data, k)
+		}
+	}
+
+	var err error
+	if v, ok := data[logrus.ErrorKey].(error); ok {
+		err = v
+		data[logrus.ErrorKey] = v.Error()
+	}
+
+	for k, v := range data {
+		switch k {
+		case KeySeverity, KeyMessage, KeyTimestamp, KeySourceLocation, KeyTrace, KeySpanID,
+			KeyTraceSampled, KeyLabels, KeyStackTrace, KeyServiceContext, KeyType:
+			k = "fields." + k
+		}
+
+		if v, ok := v.(error); ok {
+			// otherwise errors are marshalled as empty objects
+			out[k] = v.Error()
+			continue
+		}
+
+		out[k] = v
+	}
+
+	out[KeySeverity] = Severity(e.Level)
+	out[KeyMessage] = e.Message
+	out[KeyTimestamp] = e.Time.UTC().Format("2006-01-02T15:04:05.000000000Z")
+
+	if len(labels) > 0 {
+		out[KeyLabels] = labels
+	}
+
+	if e.Context != nil {
+		if spanCtx := trace.SpanContextFromContext(e.Context); spanCtx.IsValid() {
+			if len(f.opt.ProjectID) > 0 {
+				out[KeyTrace] = "projects/" + f.opt.ProjectID + "/traces/" + spanCtx.TraceID().String()
+			}
+
+			out[KeySpanID] = spanCtx.SpanID().String()
+			out[KeyTraceSampled] = spanCtx.IsSampled()
+		}
+	}
+
+	if err != nil {
+		out[KeyStackTrace] = formatStackTrace(err, f.errorFrames(e, err))
+		out[KeyServiceContext] = serviceContext{
+			Service: f.service(),
+			Version: version,
+		}
+
+		if e.Level <= logrus.ErrorLevel {
+			out[KeyType] = reportedErrorEventType
+		}
+	}
+
+	b := e.Buffer
+	if b == nil {
+		b = new(bytes.Buffer)
+	}
+
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(out); err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON, %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+func (f *formatter) service() string {
+	if len(f.opt.Service) > 0 {
+		return f.opt.Service
+	}
+
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+}
+
+// sourceLocation uses the fields set by the debug hook, then the reported caller.
+func (f *formatter) sourceLocation(e *logrus.Entry, data logrus.Fields) (*sourceLocation, bool) {
+	src, hasSrc := data["src"].(string)
+	fn, _ := data["fn"].(string)
+
+	if hasSrc {
+		delete(data, "src")
+		delete(data, "fn")
+
+		loc := &sourceLocation{
+			File:     src,
+			Function: fn,
+		}
+
+		if idx := strings.LastIndexByte(src, ':'); idx > 0 {
+			if _, err := strconv.Atoi(src[idx+1:]); err == nil {
+				loc.File = src[:idx]
+				loc.Line = src[idx+1:]
+			}
+		}
+
+		return loc, true
+	}
+
+	if e.HasCaller() {
+		return &sourceLocation{
+			File:     e.Caller.File,
+			Line:     strconv.Itoa(e.Caller.Line),
+			Function: e.Caller.Function,
+		}, true
+	}
+
+	if caller, ok := stackcache.CallerFromContext(e.Context); ok {
+		return &sourceLocation{
+			File:     caller.File,
+			Line:     strconv.Itoa(caller.Line),
+			Function: caller.Function,
+		}, true
+	}
+
+	return nil, false
+}
+
+// errorFrames prefers the stack recorded by pkg/errors, then the stack of the log call.
+func (f *formatter) errorFrames(e *logrus.Entry, err error) []runtime.Frame {
+	if frames, ok := stackcache.ErrorFrames(err); ok {
+		return frames
+	}
+
+	if caller, ok := stackcache.CallerFromContext(e.Context); ok {
+		return []runtime.Frame{caller}
+	} else if frames, ok := stackcache.StackFromContext(e.Context); ok {
+		return frames
+	}
+
+	return f.stack.GetStackFrames()
+}
+
+// formatStackTrace renders the error in the format of Go panics,
+// that is recognized by Error Reporting.
+func formatStackTrace(err error, frames []runtime.Frame) string {
+	var b strings.Builder
+
+	b.WriteString(err.Error())
+	b.WriteString("\n\ngoroutine 1 [running]:\n")
+
+	for _, frame := range frames {
+		fmt.Fprintf(&b, "%s()\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+
+	return b.String()
+}
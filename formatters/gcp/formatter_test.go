@@ -0,0 +1,82 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	log "github.com/InjectiveLabs/suplog"
+	debugHook "github.com/InjectiveLabs/suplog/hooks/debug"
+)
+
+func TestFormatter(t *testing.T) {
+	var recorder strings.Builder
+
+	logger := log.NewLogger(&recorder, NewFormatter(&FormatterOptions{
+		ProjectID:   "my-project",
+		Service:     "api",
+		Labels:      map[string]string{"team": "core"},
+		LabelFields: []string{"module"},
+	}), debugHook.NewHook(log.DefaultLogger, &debugHook.HookOptions{
+		Levels:     logrus.AllLevels,
+		AppVersion: "v1.2.3",
+	}))
+
+	decode := func() map[string]interface{} {
+		var out map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(recorder.String()), &out))
+		recorder.Reset()
+
+		return out
+	}
+
+	t.Run("severity, source location and labels", func(t *testing.T) {
+		logger.WithField("module", "accounts").WithField("user", "alice").Warning("hello")
+
+		out := decode()
+		require.Equal(t, "WARNING", out[KeySeverity])
+		require.Equal(t, "hello", out[KeyMessage])
+		require.NotEmpty(t, out[KeyTimestamp])
+		require.Equal(t, "alice", out["user"])
+		require.Equal(t, map[string]interface{}{"team": "core", "module": "accounts"}, out[KeyLabels])
+
+		loc := out[KeySourceLocation].(map[string]interface{})
+		require.True(t, strings.HasSuffix(loc["file"].(string), "formatter_test.go"), loc)
+		require.NotEmpty(t, loc["line"])
+		require.NotEmpty(t, loc["function"])
+		require.NotContains(t, out, "src")
+		require.NotContains(t, out, KeyStackTrace)
+	})
+
+	t.Run("trace from context", func(t *testing.T) {
+		ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "op")
+		logger.WithContext(ctx).Info("in span")
+		span.End()
+
+		out := decode()
+		require.Equal(t, "INFO", out[KeySeverity])
+		require.Equal(t, "projects/my-project/traces/"+span.SpanContext().TraceID().String(), out[KeyTrace])
+		require.Equal(t, span.SpanContext().SpanID().String(), out[KeySpanID])
+		require.Equal(t, true, out[KeyTraceSampled])
+	})
+
+	t.Run("error with stack", func(t *testing.T) {
+		logger.WithError(pkgerrors.New("boom")).Error("failed")
+
+		out := decode()
+		require.Equal(t, "ERROR", out[KeySeverity])
+		require.Equal(t, "boom", out["error"])
+		require.Equal(t, reportedErrorEventType, out[KeyType])
+		require.Equal(t, map[string]interface{}{"service": "api", "version": "v1.2.3"}, out[KeyServiceContext])
+
+		stack := out[KeyStackTrace].(string)
+		require.True(t, strings.HasPrefix(stack, "boom\n\ngoroutine 1 [running]:\n"), stack)
+		require.Contains(t, stack, "formatter_test.go")
+	})
+}
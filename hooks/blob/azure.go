@@ -0,0 +1,337 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const azureAPIVersion = "2021-08-06"
+
+// NewAzureStore returns a BlobStore for Azure Blob Storage container, using its REST API.
+// The key is either the base64-encoded account key, used with Shared Key auth, or a SAS token.
+// The endpoint defaults to https://<account>.blob.core.windows.net, set it to use Azurite,
+// e.g. http://127.0.0.1:10000/devstoreaccount1
+func NewAzureStore(account, key, endpoint, container string) (BlobStore, error) {
+	if len(container) == 0 {
+		return nil, fmt.Errorf("Azure container is not set")
+	}
+
+	s := &azureStore{
+		account:   account,
+		container: container,
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		cli:       &http.Client{Timeout: defaultHTTPTimeout},
+	}
+
+	if len(s.endpoint) == 0 {
+		if len(account) == 0 {
+			return nil, fmt.Errorf("Azure storage account is not set")
+		}
+
+		s.endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", account)
+	}
+
+	if sas := strings.TrimPrefix(key, "?"); strings.Contains(sas, "sig=") {
+		s.sas = sas
+	} else if len(key) > 0 {
+		accountKey, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode Azure account key: %w", err)
+		}
+
+		s.sharedKey = accountKey
+	}
+
+	return s, nil
+}
+
+type azureStore struct {
+	account   string
+	container string
+	endpoint  string
+	sharedKey []byte
+	sas       string
+	cli       *http.Client
+}
+
+func (s *azureStore) blobURL(key string) string {
+	escaped := make([]string, 0, strings.Count(key, "/")+1)
+	for _, segment := range strings.Split(key, "/") {
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+
+	u := s.endpoint + "/" + url.PathEscape(s.container) + "/" + strings.Join(escaped, "/")
+	if len(s.sas) > 0 {
+		u += "?" + s.sas
+	}
+
+	return u
+}
+
+func (s *azureStore) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+
+	if len(s.sharedKey) > 0 {
+		req.Header.Set("Authorization", "SharedKey "+s.account+":"+s.sign(req))
+	}
+
+	resp, err := s.cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	} else if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("Azure request failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return resp, nil
+}
+
+// sign computes Shared Key signature of the request,
+// see https://learn.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (s *azureStore) sign(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var msHeaders []string
+	for name := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name)
+		}
+	}
+	sort.Strings(msHeaders)
+
+	var b strings.Builder
+	for _, v := range []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	} {
+		b.WriteString(v)
+		b.WriteByte('\n')
+	}
+
+	for _, name := range msHeaders {
+		b.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	b.WriteString("/" + s.account + req.URL.EscapedPath())
+
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		b.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+
+	mac := hmac.New(sha256.New, s.sharedKey)
+	mac.Write([]byte(b.String()))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// azureMetaName converts the metadata key into C# identifier, as required by Azure.
+func azureMetaName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func metaNameFromAzure(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+func (s *azureStore) CheckAccess(prefix string) error {
+	_, err := s.PutObject(path.Join(prefix, "_touch"), touchBody(), nil)
+	return err
+}
+
+func (s *azureStore) PutObject(key string, r io.Reader, meta map[string]string) (*BlobSpec, error) {
+	body, size, err := readSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, s.blobURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	for k, v := range meta {
+		req.Header.Set("x-ms-meta-"+azureMetaName(k), v)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return &BlobSpec{
+		Key:     key,
+		ETag:    resp.Header.Get("ETag"),
+		Version: resp.Header.Get("x-ms-version-id"),
+		Meta:    meta,
+		Size:    size,
+	}, nil
+}
+
+func (s *azureStore) GetObject(key string) (*BlobSpec, error) {
+	req, err := http.NewRequest(http.MethodGet, s.blobURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &BlobSpec{
+		Key:     key,
+		Body:    resp.Body,
+		ETag:    resp.Header.Get("ETag"),
+		Version: resp.Header.Get("x-ms-version-id"),
+		Size:    resp.ContentLength,
+		Meta:    make(map[string]string),
+	}
+
+	spec.UpdatedAt, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	for name, values := range resp.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-meta-") && len(values) > 0 {
+			spec.Meta[metaNameFromAzure(strings.TrimPrefix(name, "x-ms-meta-"))] = values[0]
+		}
+	}
+
+	return spec, nil
+}
+
+// azureBlobList is the response of List Blobs operation.
+type azureBlobList struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ContentLength int64  `xml:"Content-Length"`
+		} `xml:"Properties"`
+		Metadata struct {
+			Items []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"Metadata"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (s *azureStore) ListObjects(prefix string, fn func(info *BlobInfo) error) error {
+	query := url.Values{
+		"restype": {"container"},
+		"comp":    {"list"},
+		"include": {"metadata"},
+		"prefix":  {listPrefix(prefix)},
+	}
+
+	for {
+		listURL := s.endpoint + "/" + url.PathEscape(s.container) + "?" + query.Encode()
+		if len(s.sas) > 0 {
+			listURL += "&" + s.sas
+		}
+
+		req, err := http.NewRequest(http.MethodGet, listURL, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := s.do(req)
+		if err != nil {
+			return err
+		}
+
+		var page azureBlobList
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode Azure response: %w", err)
+		}
+
+		for _, blob := range page.Blobs {
+			info := &BlobInfo{
+				Key:  blob.Name,
+				Size: blob.Properties.ContentLength,
+			}
+
+			info.UpdatedAt, _ = http.ParseTime(blob.Properties.LastModified)
+
+			for _, item := range blob.Metadata.Items {
+				if metaNameFromAzure(item.XMLName.Local) == MetaExpiresAt {
+					info.ExpiresAt = parseExpiresAt(map[string]string{MetaExpiresAt: item.Value})
+				}
+			}
+
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+
+		if len(page.NextMarker) == 0 {
+			return nil
+		}
+
+		query.Set("marker", page.NextMarker)
+	}
+}
+
+func (s *azureStore) DeleteObject(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, s.blobURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,307 @@
+package blob
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Metadata keys of encrypted blobs.
+const (
+	MetaEncryption = "encryption"
+	MetaKeyID      = "key-id"
+	MetaWrappedKey = "wrapped-key"
+	// MetaSealed holds metadata revealing the payload, encrypted with the data key, see sealedMetaKeys.
+	MetaSealed = "sealed-meta"
+)
+
+// sealedMetaKeys are metadata keys moved into MetaSealed of encrypted blobs, since the checksum
+// allows to confirm a guessed payload, and the message could be sensitive too.
+var sealedMetaKeys = []string{MetaSHA256, MetaOriginalSize, MetaMessage}
+
+// sealedMetaAD is the additional data of sealed metadata, so it can't be swapped with the body.
+var sealedMetaAD = []byte(MetaSealed)
+
+// EncryptionAES256GCM is the only supported blob encryption.
+const EncryptionAES256GCM = "aes-256-gcm"
+
+// KMS wraps data keys with a master key, as key management services do. Blobs are encrypted
+// with a data key each, which is stored in blob metadata wrapped, along with the master key ID.
+type KMS interface {
+	// WrapKey encrypts the data key with the current master key, returning its ID.
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts the data key, wrapped by the master key with the ID.
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+var (
+	// ErrNoMasterKey is returned by LocalKMSFromEnv if no master key is configured.
+	ErrNoMasterKey = errors.New("no blob master key, set LOG_BLOB_MASTER_KEY or LOG_BLOB_MASTER_KEY_FILE")
+	// ErrUnknownKeyID is returned when unwrapping a data key with a master key, which is not known.
+	ErrUnknownKeyID = errors.New("unknown master key ID")
+	// ErrEncrypted is returned by ReadBlob for encrypted blobs, if no KMS is given.
+	ErrEncrypted = errors.New("blob is encrypted")
+)
+
+// LocalKMS wraps data keys with AES-256-GCM master keys held in memory. The first key is used
+// for wrapping, the rest are only used for unwrapping keys of blobs uploaded before rotation.
+type LocalKMS struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewLocalKMS returns a LocalKMS with 32-byte master keys, the first one is the current one.
+// Key IDs are derived from the keys, so these don't have to be managed.
+func NewLocalKMS(keys ...[]byte) (*LocalKMS, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoMasterKey
+	}
+
+	kms := &LocalKMS{
+		keys: make(map[string]cipher.AEAD, len(keys)),
+	}
+
+	for i, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid master key size %d, must be 32 bytes", len(key))
+		}
+
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+
+		id := MasterKeyID(key)
+		if i == 0 {
+			kms.primary = id
+		}
+
+		kms.keys[id] = aead
+	}
+
+	return kms, nil
+}
+
+// MasterKeyID returns the ID of the local master key, its fingerprint.
+func MasterKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "local:" + hex.EncodeToString(sum[:8])
+}
+
+// LocalKMSFromEnv returns a LocalKMS with base64-encoded master keys, either from LOG_BLOB_MASTER_KEY,
+// or from the file at LOG_BLOB_MASTER_KEY_FILE, see ParseMasterKeys.
+func LocalKMSFromEnv() (*LocalKMS, error) {
+	data := os.Getenv("LOG_BLOB_MASTER_KEY")
+	if file := os.Getenv("LOG_BLOB_MASTER_KEY_FILE"); len(file) > 0 {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blob master key file: %w", err)
+		}
+
+		data = string(content)
+	}
+
+	if len(strings.TrimSpace(data)) == 0 {
+		return nil, ErrNoMasterKey
+	}
+
+	keys, err := ParseMasterKeys(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLocalKMS(keys...)
+}
+
+// ParseMasterKeys decodes base64-encoded master keys, one per line, the current one first.
+// Empty lines and lines starting with # are skipped.
+func ParseMasterKeys(data string) ([][]byte, error) {
+	var keys [][]byte
+
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode blob master key at line %d: %w", i+1, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoMasterKey
+	}
+
+	return keys, nil
+}
+
+func (k *LocalKMS) WrapKey(dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return k.primary, wrapped, nil
+}
+
+func (k *LocalKMS) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, keyID)
+	}
+
+	return open(aead, wrapped, []byte(keyID))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext, prepending a random nonce.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// encryptBlob encrypts the body with a new data key, adding the wrapped key to metadata.
+// Metadata revealing the payload is encrypted with the data key as well.
+func encryptBlob(body []byte, meta map[string]string, kms KMS) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	keyID, wrapped, err := kms.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(aead, body, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sealedMeta := make(map[string]string, len(sealedMetaKeys))
+	for _, k := range sealedMetaKeys {
+		if v, ok := meta[k]; ok {
+			sealedMeta[k] = v
+		}
+	}
+
+	if len(sealedMeta) > 0 {
+		data, err := json.Marshal(sealedMeta)
+		if err != nil {
+			return nil, err
+		}
+
+		sealed, err := seal(aead, data, sealedMetaAD)
+		if err != nil {
+			return nil, err
+		}
+
+		for k := range sealedMeta {
+			delete(meta, k)
+		}
+
+		meta[MetaSealed] = base64.StdEncoding.EncodeToString(sealed)
+	}
+
+	meta[MetaEncryption] = EncryptionAES256GCM
+	meta[MetaKeyID] = keyID
+	meta[MetaWrappedKey] = base64.StdEncoding.EncodeToString(wrapped)
+
+	return ciphertext, nil
+}
+
+// decryptBlob decrypts the blob encrypted by encryptBlob, using the key from metadata.
+// The returned metadata is a copy, with sealed metadata decrypted back in place.
+func decryptBlob(body []byte, meta map[string]string, kms KMS) ([]byte, map[string]string, error) {
+	if alg := meta[MetaEncryption]; alg != EncryptionAES256GCM {
+		return nil, nil, fmt.Errorf("unsupported blob encryption: %q", alg)
+	} else if kms == nil {
+		return nil, nil, ErrEncrypted
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(meta[MetaWrappedKey])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode wrapped data key: %w", err)
+	}
+
+	dataKey, err := kms.UnwrapKey(meta[MetaKeyID], wrapped)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := open(aead, body, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt blob: %w", err)
+	}
+
+	decrypted := make(map[string]string, len(meta)+len(sealedMetaKeys))
+	for k, v := range meta {
+		decrypted[k] = v
+	}
+
+	if sealed, ok := meta[MetaSealed]; ok {
+		data, err := base64.StdEncoding.DecodeString(sealed)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode sealed metadata: %w", err)
+		}
+
+		if data, err = open(aead, data, sealedMetaAD); err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt sealed metadata: %w", err)
+		}
+
+		var sealedMeta map[string]string
+		if err := json.Unmarshal(data, &sealedMeta); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode sealed metadata: %w", err)
+		}
+
+		delete(decrypted, MetaSealed)
+		for k, v := range sealedMeta {
+			decrypted[k] = v
+		}
+	}
+
+	return plaintext, decrypted, nil
+}
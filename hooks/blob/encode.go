@@ -0,0 +1,254 @@
+package blob
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Metadata keys of uploaded blobs, besides MetaExpiresAt.
+const (
+	MetaContentType     = "content-type"
+	MetaContentEncoding = "content-encoding"
+	MetaSHA256          = "sha256"
+	MetaOriginalSize    = "original-size"
+	MetaEnv             = "env"
+	MetaLevel           = "level"
+	MetaMessage         = "message"
+)
+
+// Detected content types of blobs.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeText     = "text/plain; charset=utf-8"
+	ContentTypeBinary   = "application/octet-stream"
+)
+
+// Compression algorithms of HookOptions.BlobCompression, also used as content encoding.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// ErrChecksumMismatch is returned by ReadBlob if the blob doesn't match its SHA-256 checksum.
+var ErrChecksumMismatch = errors.New("blob checksum mismatch")
+
+// maxMetaMessage limits the size of the message put into metadata, since metadata size is limited by stores.
+const maxMetaMessage = 256
+
+// DetectContentType guesses whether the blob is JSON, text, protobuf or just binary data.
+func DetectContentType(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+
+	switch {
+	case len(trimmed) == 0:
+		return ContentTypeBinary
+	case (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed):
+		return ContentTypeJSON
+	case isText(data):
+		return ContentTypeText
+	case isProtobuf(data):
+		return ContentTypeProtobuf
+	default:
+		return ContentTypeBinary
+	}
+}
+
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+
+	for _, c := range data {
+		if (c < 0x20 && c != '\t' && c != '\n' && c != '\r') || c == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isProtobuf reports whether the data is a sequence of valid protobuf fields.
+func isProtobuf(data []byte) bool {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 || typ == protowire.StartGroupType || typ == protowire.EndGroupType {
+			return false
+		}
+		data = data[n:]
+
+		if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+			return false
+		}
+		data = data[n:]
+	}
+
+	return true
+}
+
+// blobMeta returns metadata describing the original payload, used to validate it once downloaded.
+func blobMeta(payload []byte) map[string]string {
+	sum := sha256.Sum256(payload)
+
+	return map[string]string{
+		MetaSHA256:       hex.EncodeToString(sum[:]),
+		MetaOriginalSize: strconv.Itoa(len(payload)),
+	}
+}
+
+// metaMessage truncates the message and encodes it as RFC 2047 word, if it's not ASCII,
+// since metadata is sent in HTTP headers.
+func metaMessage(msg string) string {
+	if len(msg) > maxMetaMessage {
+		msg = msg[:maxMetaMessage]
+		for !utf8.ValidString(msg) {
+			msg = msg[:len(msg)-1]
+		}
+	}
+
+	return mime.QEncoding.Encode("utf-8", msg)
+}
+
+// encodeBlob detects content type of the payload, and compresses it if it's worth it.
+// Metadata is updated with content type and encoding.
+func encodeBlob(payload []byte, meta map[string]string, compression string, minSize int) ([]byte, error) {
+	meta[MetaContentType] = DetectContentType(payload)
+
+	if compression == CompressionNone || len(compression) == 0 || len(payload) < minSize {
+		return payload, nil
+	}
+
+	compressed, err := compress(compression, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// already compressed data, like images, could get bigger
+	if len(compressed) >= len(payload) {
+		return payload, nil
+	}
+
+	meta[MetaContentEncoding] = compression
+	return compressed, nil
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func initZstd() {
+	zstdOnce.Do(func() {
+		// both are safe for concurrent EncodeAll and DecodeAll calls
+		zstdEncoder, _ = zstd.NewWriter(nil)
+		zstdDecoder, _ = zstd.NewReader(nil)
+	})
+}
+
+func compress(compression string, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionGzip:
+		buf := new(bytes.Buffer)
+		zw := gzip.NewWriter(buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		} else if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		initZstd()
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported blob compression: %q", compression)
+	}
+}
+
+func decompress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case "":
+		return data, nil
+	case CompressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		return io.ReadAll(zr)
+	case CompressionZstd:
+		initZstd()
+		return zstdDecoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unsupported blob content encoding: %q", encoding)
+	}
+}
+
+// ReadBlob downloads the blob and decodes it, as uploaded by the hook. Encrypted blobs are decrypted
+// with the KMS, which could be nil otherwise, and their sealed metadata is decrypted into spec.Meta.
+// The payload is verified against SHA-256 checksum from the metadata, if any, returning
+// ErrChecksumMismatch if it doesn't match.
+func ReadBlob(store BlobStore, key string, kms KMS) ([]byte, *BlobSpec, error) {
+	spec, err := store.GetObject(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := io.ReadAll(spec.Body)
+	spec.Body.Close()
+	if err != nil {
+		return nil, spec, err
+	}
+
+	data, meta, err := decodeBlob(data, spec.Meta, kms)
+	if meta != nil {
+		spec.Meta = meta
+	}
+
+	return data, spec, err
+}
+
+// DecodeBlob decrypts and decompresses the blob downloaded by other means, according to its metadata,
+// and verifies its checksum, see ReadBlob.
+func DecodeBlob(data []byte, meta map[string]string, kms KMS) ([]byte, error) {
+	data, _, err := decodeBlob(data, meta, kms)
+	return data, err
+}
+
+// decodeBlob returns the payload, and the metadata with sealed metadata decrypted.
+func decodeBlob(data []byte, meta map[string]string, kms KMS) ([]byte, map[string]string, error) {
+	var err error
+	if _, ok := meta[MetaEncryption]; ok {
+		if data, meta, err = decryptBlob(data, meta, kms); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if data, err = decompress(meta[MetaContentEncoding], data); err != nil {
+		return nil, meta, err
+	}
+
+	if checksum, ok := meta[MetaSHA256]; ok {
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != checksum {
+			return nil, meta, ErrChecksumMismatch
+		}
+	}
+
+	return data, meta, nil
+}
@@ -0,0 +1,228 @@
+package blob
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// metaDir keeps metadata of blobs, since files don't have any.
+const metaDir = ".meta"
+
+// NewFileStore returns a BlobStore keeping blobs as files under the root directory.
+func NewFileStore(root string) (BlobStore, error) {
+	if len(root) == 0 {
+		return nil, fmt.Errorf("file blob store root is not set")
+	}
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{
+		root: root,
+	}, nil
+}
+
+type fileStore struct {
+	root string
+}
+
+func (s *fileStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.HasPrefix(clean, "/"+metaDir+"/") {
+		return "", fmt.Errorf("invalid blob key: %q", key)
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+func (s *fileStore) metaPath(key string) string {
+	return filepath.Join(s.root, metaDir, filepath.FromSlash(path.Clean("/"+key))+".json")
+}
+
+func (s *fileStore) CheckAccess(prefix string) error {
+	_, err := s.PutObject(path.Join(prefix, "_touch"), touchBody(), nil)
+	return err
+}
+
+func (s *fileStore) PutObject(key string, r io.Reader, meta map[string]string) (*BlobSpec, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := writeFileAtomic(filePath, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metaPath := s.metaPath(key)
+	if len(meta) > 0 {
+		if _, err := writeFileAtomic(metaPath, func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(meta)
+		}); err != nil {
+			return nil, err
+		}
+	} else if err := os.Remove(metaPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return &BlobSpec{
+		Path: filePath,
+		Key:  key,
+		Meta: meta,
+		Size: size,
+	}, nil
+}
+
+func (s *fileStore) GetObject(key string) (*BlobSpec, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	spec := &BlobSpec{
+		Path:      filePath,
+		Key:       key,
+		Body:      f,
+		UpdatedAt: info.ModTime(),
+		Size:      info.Size(),
+	}
+
+	if data, err := os.ReadFile(s.metaPath(key)); err == nil {
+		if err := json.Unmarshal(data, &spec.Meta); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read blob metadata: %w", err)
+		}
+	}
+
+	return spec, nil
+}
+
+// writeFileAtomic writes into a temp file, renaming it once done,
+// so readers never see partially written blobs.
+func writeFileAtomic(filePath string, write func(w io.Writer) error) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp*")
+	if err != nil {
+		return 0, err
+	}
+
+	err = write(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(tmp.Name()); err == nil {
+			size = info.Size()
+			err = os.Rename(tmp.Name(), filePath)
+		}
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// ListObjects walks files under the prefix, skipping dot-files and metadata.
+func (s *fileStore) ListObjects(prefix string, fn func(info *BlobInfo) error) error {
+	dir := filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+prefix)))
+
+	err := filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(d.Name(), ".") && filePath != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		} else if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			// removed since listed
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		blobInfo := &BlobInfo{
+			Key:       key,
+			Size:      info.Size(),
+			UpdatedAt: info.ModTime(),
+		}
+
+		if data, err := os.ReadFile(s.metaPath(key)); err == nil {
+			var meta map[string]string
+			if json.Unmarshal(data, &meta) == nil {
+				blobInfo.ExpiresAt = parseExpiresAt(meta)
+			}
+		}
+
+		return fn(blobInfo)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *fileStore) DeleteObject(key string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Remove(s.metaPath(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
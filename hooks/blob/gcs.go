@@ -0,0 +1,341 @@
+package blob
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGCSEndpoint = "https://storage.googleapis.com"
+	defaultGCEMetadata = "metadata.google.internal"
+)
+
+// NewGCSStore returns a BlobStore for Google Cloud Storage bucket, using its JSON API.
+// If the token is empty, it's obtained from GCE metadata server, e.g. with GKE Workload Identity.
+// Set the endpoint, or STORAGE_EMULATOR_HOST, to use an emulator without auth.
+func NewGCSStore(bucket, token, endpoint string) (BlobStore, error) {
+	if len(bucket) == 0 {
+		return nil, fmt.Errorf("GCS bucket is not set")
+	}
+
+	s := &gcsStore{
+		bucket:   bucket,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		cli:      &http.Client{Timeout: defaultHTTPTimeout},
+	}
+
+	if len(s.endpoint) == 0 {
+		if host := os.Getenv("STORAGE_EMULATOR_HOST"); len(host) > 0 {
+			s.endpoint = strings.TrimSuffix(host, "/")
+			if !strings.Contains(s.endpoint, "://") {
+				s.endpoint = "http://" + s.endpoint
+			}
+		}
+	}
+
+	switch {
+	case len(token) > 0:
+		s.token = staticToken(token)
+	case len(s.endpoint) == 0:
+		s.token = &gceMetadataToken{cli: s.cli}
+	default:
+		// emulators don't need auth
+	}
+
+	if len(s.endpoint) == 0 {
+		s.endpoint = defaultGCSEndpoint
+	}
+
+	return s, nil
+}
+
+const defaultHTTPTimeout = 30 * time.Second
+
+type tokenSource interface {
+	Token() (string, error)
+}
+
+type staticToken string
+
+func (t staticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+// gceMetadataToken obtains access tokens of the default service account from GCE metadata server.
+type gceMetadataToken struct {
+	cli *http.Client
+
+	mux       sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func (t *gceMetadataToken) Token() (string, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if len(t.token) > 0 && time.Now().Before(t.expiresAt) {
+		return t.token, nil
+	}
+
+	host := os.Getenv("GCE_METADATA_HOST")
+	if len(host) == 0 {
+		host = defaultGCEMetadata
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := t.cli.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token from GCE metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get token from GCE metadata server: %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	t.token = token.AccessToken
+	// refresh a minute before expiration
+	t.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+
+	return t.token, nil
+}
+
+type gcsStore struct {
+	bucket   string
+	endpoint string
+	token    tokenSource
+	cli      *http.Client
+}
+
+// gcsObject is the object resource of GCS JSON API.
+type gcsObject struct {
+	Name       string            `json:"name"`
+	Size       string            `json:"size,omitempty"`
+	ETag       string            `json:"etag,omitempty"`
+	Generation string            `json:"generation,omitempty"`
+	Updated    time.Time         `json:"updated"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+func (o *gcsObject) spec() *BlobSpec {
+	size, _ := strconv.ParseInt(o.Size, 10, 64)
+
+	return &BlobSpec{
+		Key:       o.Name,
+		ETag:      o.ETag,
+		Version:   o.Generation,
+		UpdatedAt: o.Updated,
+		Meta:      o.Metadata,
+		Size:      size,
+	}
+}
+
+func (s *gcsStore) do(req *http.Request) (*http.Response, error) {
+	if s.token != nil {
+		token, err := s.token.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	} else if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("GCS request failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return resp, nil
+}
+
+func (s *gcsStore) objectURL(key string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(key))
+}
+
+func (s *gcsStore) CheckAccess(prefix string) error {
+	_, err := s.PutObject(path.Join(prefix, "_touch"), touchBody(), nil)
+	return err
+}
+
+func (s *gcsStore) PutObject(key string, r io.Reader, meta map[string]string) (*BlobSpec, error) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return nil, err
+	}
+
+	upload := struct {
+		Name     string            `json:"name"`
+		Metadata map[string]string `json:"metadata,omitempty"`
+	}{
+		Name:     key,
+		Metadata: meta,
+	}
+
+	if err := json.NewEncoder(part).Encode(upload); err != nil {
+		return nil, err
+	}
+
+	if part, err = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}}); err != nil {
+		return nil, err
+	} else if _, err := io.Copy(part, r); err != nil {
+		return nil, err
+	} else if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	uploadURL := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=multipart", s.endpoint, url.PathEscape(s.bucket))
+	req, err := http.NewRequest(http.MethodPost, uploadURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var obj gcsObject
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("failed to decode GCS response: %w", err)
+	}
+
+	return obj.spec(), nil
+}
+
+func (s *gcsStore) GetObject(key string) (*BlobSpec, error) {
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj gcsObject
+	err = json.NewDecoder(resp.Body).Decode(&obj)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GCS response: %w", err)
+	}
+
+	if req, err = http.NewRequest(http.MethodGet, s.objectURL(key)+"?alt=media", nil); err != nil {
+		return nil, err
+	}
+
+	if resp, err = s.do(req); err != nil {
+		return nil, err
+	}
+
+	spec := obj.spec()
+	spec.Body = resp.Body
+
+	return spec, nil
+}
+
+func (s *gcsStore) ListObjects(prefix string, fn func(info *BlobInfo) error) error {
+	query := url.Values{
+		"prefix": {listPrefix(prefix)},
+	}
+
+	for {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s/o?%s",
+			s.endpoint, url.PathEscape(s.bucket), query.Encode()), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := s.do(req)
+		if err != nil {
+			return err
+		}
+
+		var page struct {
+			Items         []*gcsObject `json:"items"`
+			NextPageToken string       `json:"nextPageToken"`
+		}
+
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode GCS response: %w", err)
+		}
+
+		for _, obj := range page.Items {
+			spec := obj.spec()
+			if err := fn(&BlobInfo{
+				Key:       spec.Key,
+				Size:      spec.Size,
+				UpdatedAt: spec.UpdatedAt,
+				ExpiresAt: parseExpiresAt(spec.Meta),
+			}); err != nil {
+				return err
+			}
+		}
+
+		if len(page.NextPageToken) == 0 {
+			return nil
+		}
+
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+func (s *gcsStore) DeleteObject(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
@@ -1,10 +1,13 @@
 package blob
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -19,13 +22,61 @@ type HookOptions struct {
 	BlobStoreEndpoint string
 	BlobStoreRegion   string
 	BlobStoreBucket   string
-	BlobRetentionTTL  time.Duration
-	BlobEnabledEnv    map[string]bool
+	// BlobRetentionTTL is set as expiration metadata of blobs, and enforced by lifecycle rules
+	// or the janitor, see BlobLifecycle and JanitorInterval. Defaults to DefaultRenentionTTL.
+	BlobRetentionTTL time.Duration
+	BlobEnabledEnv   map[string]bool
+	// BlobLifecycle controls the lifecycle rule expiring blobs under the env prefix, for stores
+	// supporting it, like S3. Set to "verify" (default) to warn if it's missing, "install" to put
+	// a missing or different rule into the bucket, "off" to skip it. Defaults to LOG_BLOB_LIFECYCLE.
+	BlobLifecycle string
+	// JanitorInterval enables periodic removal of expired blobs by the hook, for stores without
+	// lifecycle rules, e.g. filesystem. Defaults to LOG_BLOB_JANITOR_INTERVAL, disabled if empty.
+	JanitorInterval time.Duration
+	// BlobStore overrides the store selected by BlobStoreURL.
+	BlobStore BlobStore
+	// UploadWorkers limits concurrent uploads, defaults to 4.
+	UploadWorkers int
+	// UploadQueueSize limits queued uploads, defaults to 1024. Uploads over the limit fail.
+	// Uploads resumed from the spool are not limited.
+	UploadQueueSize int
+	// UploadRetries is the max amount of retries of a failed upload, defaults to 5.
+	// Negative value disables retries.
+	UploadRetries int
+	// UploadRetryBackoff is the interval before the first retry, doubled with every
+	// next one up to UploadMaxBackoff. Defaults to 1s and 1m.
+	UploadRetryBackoff time.Duration
+	UploadMaxBackoff   time.Duration
+	// BlobCompression is "none" (default), "gzip" or "zstd". Blobs are compressed if they're at least
+	// BlobCompressMinSize bytes, 4KiB by default, and get smaller. Defaults to LOG_BLOB_COMPRESSION
+	// and LOG_BLOB_COMPRESS_MIN_SIZE.
+	BlobCompression     string
+	BlobCompressMinSize int
+	// BlobKMS enables AES-256-GCM envelope encryption of blobs, with a data key per blob wrapped by
+	// the KMS. Defaults to LocalKMSFromEnv, if LOG_BLOB_MASTER_KEY or LOG_BLOB_MASTER_KEY_FILE is set.
+	BlobKMS KMS
+	// SpoolDir keeps payloads of pending and failed uploads on disk, so they survive restarts.
+	// With BlobKMS, payloads are encrypted before spooling, ones failing encryption are kept in memory.
+	// Defaults to LOG_BLOB_SPOOL_DIR, uploads are kept in memory if empty.
+	SpoolDir string
+	// MaxFailedUploads limits failed uploads kept in SpoolDir or in memory, the oldest are removed first.
+	// Spooled ones are removed after BlobRetentionTTL as well. Defaults to 100.
+	MaxFailedUploads int
 }
 
 // DefaultRetentionTTL is currently set to be 1 month.
 const DefaultRenentionTTL = 30 * 24 * time.Hour
 
+const (
+	defaultUploadWorkers      = 4
+	defaultUploadQueueSize    = 1024
+	defaultUploadRetries      = 5
+	defaultUploadRetryBackoff = time.Second
+	defaultUploadMaxBackoff   = time.Minute
+	defaultCompressMinSize    = 4096
+	defaultMaxFailedUploads   = 100
+)
+
 func checkHookOptions(opt *HookOptions) *HookOptions {
 	if opt == nil {
 		opt = &HookOptions{}
@@ -66,6 +117,59 @@ func checkHookOptions(opt *HookOptions) *HookOptions {
 		opt.BlobRetentionTTL = DefaultRenentionTTL
 	}
 
+	if len(opt.BlobLifecycle) == 0 {
+		opt.BlobLifecycle = os.Getenv("LOG_BLOB_LIFECYCLE")
+		if len(opt.BlobLifecycle) == 0 {
+			opt.BlobLifecycle = LifecycleVerify
+		}
+	}
+
+	if opt.JanitorInterval == 0 {
+		opt.JanitorInterval, _ = time.ParseDuration(os.Getenv("LOG_BLOB_JANITOR_INTERVAL"))
+	}
+
+	if len(opt.BlobCompression) == 0 {
+		opt.BlobCompression = os.Getenv("LOG_BLOB_COMPRESSION")
+		if len(opt.BlobCompression) == 0 {
+			opt.BlobCompression = CompressionNone
+		}
+	}
+
+	if opt.BlobCompressMinSize <= 0 {
+		opt.BlobCompressMinSize, _ = strconv.Atoi(os.Getenv("LOG_BLOB_COMPRESS_MIN_SIZE"))
+		if opt.BlobCompressMinSize <= 0 {
+			opt.BlobCompressMinSize = defaultCompressMinSize
+		}
+	}
+
+	if opt.UploadWorkers <= 0 {
+		opt.UploadWorkers = defaultUploadWorkers
+	}
+
+	if opt.UploadQueueSize <= 0 {
+		opt.UploadQueueSize = defaultUploadQueueSize
+	}
+
+	if opt.UploadRetries == 0 {
+		opt.UploadRetries = defaultUploadRetries
+	}
+
+	if opt.UploadRetryBackoff <= 0 {
+		opt.UploadRetryBackoff = defaultUploadRetryBackoff
+	}
+
+	if opt.UploadMaxBackoff <= 0 {
+		opt.UploadMaxBackoff = defaultUploadMaxBackoff
+	}
+
+	if len(opt.SpoolDir) == 0 {
+		opt.SpoolDir = os.Getenv("LOG_BLOB_SPOOL_DIR")
+	}
+
+	if opt.MaxFailedUploads <= 0 {
+		opt.MaxFailedUploads = defaultMaxFailedUploads
+	}
+
 	if len(opt.BlobEnabledEnv) == 0 {
 		opt.BlobEnabledEnv = map[string]bool{
 			"prod":    true,
@@ -85,39 +189,139 @@ type RootLogger interface {
 }
 
 // NewHook initializes a new suplog.Hook using provided params and options.
-// Provide a root logger to print any errors occuring during the plugin init.
-func NewHook(logger RootLogger, opt *HookOptions) logrus.Hook {
-	h := &hook{
+// The blob store is selected by BlobStoreURL scheme, see NewBlobStore, falling back
+// to S3 configured by BlobStore* options. Blobs are uploaded in background, use Flush
+// and Close on shutdown. Provide a root logger to print any errors occuring during the plugin init.
+func NewHook(logger RootLogger, opt *HookOptions) *Hook {
+	h := &Hook{
 		logger: logger,
 		opt:    checkHookOptions(opt),
 	}
 
-	if s3Remote, err := NewS3Remote(
+	switch h.opt.BlobCompression {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		logger.Warningf("unsupported blob compression %q, blobs are uploaded uncompressed", h.opt.BlobCompression)
+		h.opt.BlobCompression = CompressionNone
+	}
+
+	if h.opt.BlobKMS == nil {
+		kms, err := LocalKMSFromEnv()
+		if err == nil {
+			h.opt.BlobKMS = kms
+		} else if !errors.Is(err, ErrNoMasterKey) {
+			// blobs are not uploaded unencrypted, when encryption is intended
+			logger.Errorf("failed to init blob encryption, blob store is disabled: %+v", err)
+			return h
+		}
+	}
+
+	store, err := h.newStore()
+	if err != nil {
+		logger.Errorf("failed to init blob store: %+v", err)
+		return h
+	}
+
+	if err := store.CheckAccess(h.opt.Env); err != nil {
+		// the store could be temporarily unavailable, uploads are still attempted
+		logger.Warningf("failed to verify blob store access: %+v", err)
+	}
+
+	h.store = store
+	h.uploader = newUploader(h.opt, store, logger)
+	h.ensureLifecycle()
+
+	if h.opt.JanitorInterval > 0 {
+		h.janitorStopC = make(chan struct{})
+		h.janitorDoneC = make(chan struct{})
+		go h.runJanitor()
+	}
+
+	return h
+}
+
+// ensureLifecycle verifies or installs the lifecycle rule expiring blobs under the env prefix.
+func (h *Hook) ensureLifecycle() {
+	if h.opt.BlobLifecycle == LifecycleOff {
+		return
+	}
+
+	err := ErrNotSupported
+	if lifecycle, ok := h.store.(LifecycleStore); ok {
+		err = lifecycle.EnsureLifecycle(h.opt.Env, h.opt.BlobRetentionTTL, h.opt.BlobLifecycle == LifecycleInstall)
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNotSupported):
+		if h.opt.JanitorInterval <= 0 {
+			h.logger.Debugf("blob store doesn't support lifecycle rules, enable the janitor to enforce retention")
+		}
+	case errors.Is(err, ErrNoLifecycleRule):
+		h.logger.Warningf("no lifecycle rule expires blobs under %s, blobs are kept forever", h.opt.Env)
+	default:
+		h.logger.Warningf("failed to ensure blob lifecycle rule: %+v", err)
+	}
+}
+
+// runJanitor removes expired blobs every JanitorInterval, until the hook is closed.
+func (h *Hook) runJanitor() {
+	defer close(h.janitorDoneC)
+
+	ticker := time.NewTicker(h.opt.JanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := DeleteExpired(h.store, h.opt.Env, h.opt.BlobRetentionTTL, false)
+		if err != nil {
+			h.logger.Warningf("failed to delete expired blobs: %+v", err)
+		} else if stats.Deleted > 0 {
+			h.logger.Debugf("deleted %d expired blobs, %d bytes", stats.Deleted, stats.Bytes)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-h.janitorStopC:
+			return
+		}
+	}
+}
+
+func (h *Hook) newStore() (BlobStore, error) {
+	if h.opt.BlobStore != nil {
+		return h.opt.BlobStore, nil
+	}
+
+	if IsBlobStoreURL(h.opt.BlobStoreURL) {
+		return NewBlobStore(h.opt.BlobStoreURL, &StoreCredentials{
+			Account:  h.opt.BlobStoreAccount,
+			Key:      h.opt.BlobStoreKey,
+			Endpoint: h.opt.BlobStoreEndpoint,
+			Region:   h.opt.BlobStoreRegion,
+		})
+	}
+
+	return newS3Remote(
 		h.opt.BlobStoreAccount,
 		h.opt.BlobStoreKey,
 		h.opt.BlobStoreEndpoint,
 		h.opt.BlobStoreRegion,
 		h.opt.BlobStoreBucket,
-	); err != nil {
-		logger.Errorf("failed to init S3 session: %+v", err)
-		return h
-	} else if err = s3Remote.CheckAccess(h.opt.Env); err != nil {
-		logger.Errorf("failed to verify S3 remote access: %+v", err)
-		return h
-	} else {
-		h.s3Remote = s3Remote
-	}
-
-	return h
+	)
 }
 
-type hook struct {
+// Hook uploads blobs found in entries, replacing them with references.
+type Hook struct {
 	opt      *HookOptions
 	logger   RootLogger
-	s3Remote S3Remote
+	store    BlobStore
+	uploader *uploader
+
+	janitorStopC chan struct{}
+	janitorDoneC chan struct{}
 }
 
-func (h *hook) Levels() []logrus.Level {
+func (h *Hook) Levels() []logrus.Level {
 	return []logrus.Level{
 		logrus.PanicLevel,
 		logrus.FatalLevel,
@@ -129,14 +333,14 @@ func (h *hook) Levels() []logrus.Level {
 	}
 }
 
-func (h *hook) Fire(e *logrus.Entry) error {
+func (h *Hook) Fire(e *logrus.Entry) error {
 	blob, hasBlob := e.Data["blob"]
 	if !hasBlob {
 		return nil
 	}
 
-	if h.s3Remote == nil {
-		h.logger.Warningf("blob provided but S3 remote is disabled")
+	if h.store == nil {
+		h.logger.Warningf("blob provided but blob store is disabled")
 		delete(e.Data, "blob")
 
 		return nil
@@ -158,29 +362,90 @@ func (h *hook) Fire(e *logrus.Entry) error {
 		return nil
 	}
 
-	blobID := NewBlobID()
+	meta := blobMeta(blobPayload)
+	meta[MetaEnv] = h.opt.Env
+	meta[MetaLevel] = e.Level.String()
+	meta[MetaMessage] = metaMessage(e.Message)
+	meta[MetaExpiresAt] = expiresAt(h.opt.BlobRetentionTTL)
 
-	if len(h.opt.BlobStoreURL) > 0 {
-		e.Data["blob"] = fmt.Sprintf("%s/%s", h.opt.BlobStoreURL, blobID)
-	} else {
-		e.Data["blob"] = fmt.Sprintf("%s/%s", h.opt.Env, blobID)
+	objectKey := path.Join(h.opt.Env, NewBlobID())
+	e.Data["blob"] = h.blobRef(objectKey)
+	e.Data["blob_size"] = len(blobPayload)
+	if h.opt.BlobKMS == nil {
+		// the checksum would confirm a guessed payload of an encrypted blob
+		e.Data["blob_sha256"] = meta[MetaSHA256]
 	}
 
-	h.blobUpload(blobID, blobPayload)
+	h.uploader.enqueue(objectKey, blobPayload, meta)
 
 	return nil
 }
 
-func (h *hook) blobUpload(blobID string, payload []byte) {
-	objectKey := filepath.Join(h.opt.Env, blobID)
-	_, err := h.s3Remote.PutObject(objectKey, bytes.NewReader(payload), nil)
+// blobRef returns the reference of the blob put into the entry.
+func (h *Hook) blobRef(objectKey string) string {
+	if IsBlobStoreURL(h.opt.BlobStoreURL) {
+		storeURL, _, _ := strings.Cut(h.opt.BlobStoreURL, "?")
+		return strings.TrimSuffix(storeURL, "/") + "/" + objectKey
+	} else if len(h.opt.BlobStoreURL) > 0 {
+		return fmt.Sprintf("%s/%s", h.opt.BlobStoreURL, path.Base(objectKey))
+	}
 
-	if err != nil {
-		h.logger.Errorf(
-			"failed to upload blob to S3 remote server: key %s in %s: %+v",
-			objectKey,
-			h.opt.BlobStoreBucket,
-			err,
-		)
+	return objectKey
+}
+
+// Flush blocks until there are no pending uploads, or the context is done.
+func (h *Hook) Flush(ctx context.Context) error {
+	if h.uploader == nil {
+		return nil
 	}
+
+	return h.uploader.Flush(ctx)
+}
+
+// Close stops accepting uploads, and waits until queued uploads are done. Each of them
+// gets one more attempt, failed ones are left in the spool for the next run.
+func (h *Hook) Close() error {
+	if h.uploader == nil {
+		return nil
+	}
+
+	if err := h.uploader.Close(); err != nil {
+		return err
+	}
+
+	if h.janitorStopC != nil {
+		close(h.janitorStopC)
+		<-h.janitorDoneC
+	}
+
+	return nil
+}
+
+// Pending returns uploads that are queued or being retried.
+func (h *Hook) Pending() []Upload {
+	if h.uploader == nil {
+		return nil
+	}
+
+	return h.uploader.Pending()
+}
+
+// Failed returns uploads that failed after all retries, or couldn't be queued.
+// These are kept in SpoolDir, if set, otherwise in memory, see MaxFailedUploads.
+func (h *Hook) Failed() []Upload {
+	if h.uploader == nil {
+		return nil
+	}
+
+	return h.uploader.Failed()
+}
+
+// RetryFailed queues failed uploads kept in SpoolDir again, returning how many of them.
+// Without SpoolDir, payloads of failed uploads are not kept, so there is nothing to retry.
+func (h *Hook) RetryFailed() (int, error) {
+	if h.uploader == nil {
+		return 0, nil
+	}
+
+	return h.uploader.RetryFailed()
 }
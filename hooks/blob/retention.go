@@ -0,0 +1,205 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// MetaExpiresAt is the metadata key with RFC 3339 time, after which the blob could be deleted.
+const MetaExpiresAt = "expires-at"
+
+// BlobInfo describes a listed blob.
+type BlobInfo struct {
+	Key       string
+	Size      int64
+	UpdatedAt time.Time
+	// ExpiresAt is set if the store lists blob metadata, see MetaExpiresAt.
+	ExpiresAt time.Time
+}
+
+// BlobLister is implemented by stores able to list and delete blobs, used by DeleteExpired.
+type BlobLister interface {
+	// ListObjects calls fn for every blob under the prefix, stopping at the first error.
+	ListObjects(prefix string, fn func(info *BlobInfo) error) error
+	// DeleteObject removes the blob, missing blobs are not an error.
+	DeleteObject(key string) error
+}
+
+// LifecycleStore is implemented by stores able to expire blobs server-side, like S3.
+type LifecycleStore interface {
+	// EnsureLifecycle verifies that blobs under the prefix expire in ttl,
+	// when install is true, a missing or different rule is put into the bucket.
+	// An empty prefix is refused with ErrEmptyPrefix, see AllBlobs.
+	EnsureLifecycle(prefix string, ttl time.Duration, install bool) error
+}
+
+// AllBlobs is the prefix selecting every blob in the store, for DeleteExpired and EnsureLifecycle.
+// An empty prefix is refused by these, since it's usually an unset env, and would expire
+// objects not uploaded by the hook.
+const AllBlobs = "/"
+
+var (
+	// ErrNotSupported is returned when the store doesn't support an operation.
+	ErrNotSupported = errors.New("not supported by the blob store")
+	// ErrNoLifecycleRule is returned by EnsureLifecycle if there is no matching rule to verify.
+	ErrNoLifecycleRule = errors.New("no matching lifecycle rule")
+	// ErrEmptyPrefix is returned by DeleteExpired and EnsureLifecycle for an empty prefix.
+	ErrEmptyPrefix = errors.New("empty blob prefix, use AllBlobs to select every blob in the store")
+)
+
+// Lifecycle modes of HookOptions.BlobLifecycle.
+const (
+	LifecycleVerify  = "verify"
+	LifecycleInstall = "install"
+	LifecycleOff     = "off"
+)
+
+// expiresAt returns the expiration time of a blob uploaded now, formatted for metadata.
+func expiresAt(ttl time.Duration) string {
+	return time.Now().Add(ttl).UTC().Format(time.RFC3339)
+}
+
+// parseExpiresAt reads the expiration time from the metadata, zero time if not set.
+func parseExpiresAt(meta map[string]string) time.Time {
+	t, _ := time.Parse(time.RFC3339, meta[MetaExpiresAt])
+	return t
+}
+
+// listPrefix makes the prefix match whole path segments only.
+func listPrefix(prefix string) string {
+	if prefix = strings.Trim(prefix, "/"); len(prefix) > 0 {
+		prefix += "/"
+	}
+
+	return prefix
+}
+
+// lifecycleDays rounds ttl up to whole days, since lifecycle rules have days granularity.
+func lifecycleDays(ttl time.Duration) int {
+	days := int((ttl + 24*time.Hour - 1) / (24 * time.Hour))
+	if days < 1 {
+		days = 1
+	}
+
+	return days
+}
+
+// lifecycleRuleID is the ID of the rule installed for the prefix.
+func lifecycleRuleID(prefix string) string {
+	if prefix = strings.Trim(prefix, "/"); len(prefix) == 0 {
+		return "suplog-retention"
+	}
+
+	return "suplog-retention-" + strings.ReplaceAll(prefix, "/", "-")
+}
+
+// checkLifecycleRule reports whether an enabled rule with the prefix filter and expiration days
+// covers blobs under the prefix. Rules with other filters, e.g. tags or sizes, are not considered,
+// since these don't apply to every blob.
+func checkLifecycleRule(prefix string, days int, rulePrefix string, ruleDays int, enabled, otherFilters bool) error {
+	if !enabled || otherFilters || ruleDays <= 0 || !strings.HasPrefix(listPrefix(prefix), rulePrefix) {
+		return ErrNoLifecycleRule
+	} else if ruleDays > days {
+		return fmt.Errorf("lifecycle rule expires blobs in %d days instead of %d", ruleDays, days)
+	}
+
+	return nil
+}
+
+// SweepStats are counters of DeleteExpired.
+type SweepStats struct {
+	Scanned int
+	Deleted int
+	Bytes   int64
+}
+
+// DeleteExpired removes blobs under the prefix, which are past retention. The retention is set by
+// the expiration metadata, if the store lists it, otherwise blobs expire in ttl after the last update.
+// It's meant for stores without lifecycle rules, like filesystem or MinIO without ILM.
+// An empty prefix is refused with ErrEmptyPrefix, see AllBlobs.
+func DeleteExpired(store BlobStore, prefix string, ttl time.Duration, dryRun bool) (*SweepStats, error) {
+	if len(prefix) == 0 {
+		return nil, ErrEmptyPrefix
+	}
+
+	lister, ok := store.(BlobLister)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	var (
+		stats = &SweepStats{}
+		now   = time.Now()
+	)
+
+	// deleting while listing is allowed by all backends, but could skip objects
+	var expired []*BlobInfo
+
+	err := lister.ListObjects(prefix, func(info *BlobInfo) error {
+		stats.Scanned++
+
+		expiresAt := info.ExpiresAt
+		if expiresAt.IsZero() {
+			expiresAt = info.UpdatedAt.Add(ttl)
+		}
+
+		if now.After(expiresAt) {
+			expired = append(expired, info)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	for _, info := range expired {
+		if !dryRun {
+			if err := lister.DeleteObject(info.Key); err != nil {
+				return stats, fmt.Errorf("failed to delete blob %s: %w", info.Key, err)
+			}
+		}
+
+		stats.Deleted++
+		stats.Bytes += info.Size
+	}
+
+	return stats, nil
+}
+
+func (s *prefixedStore) ListObjects(prefix string, fn func(info *BlobInfo) error) error {
+	lister, ok := s.BlobStore.(BlobLister)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return lister.ListObjects(path.Join(s.prefix, prefix), func(info *BlobInfo) error {
+		info.Key = strings.TrimPrefix(info.Key, s.prefix+"/")
+		return fn(info)
+	})
+}
+
+func (s *prefixedStore) DeleteObject(key string) error {
+	lister, ok := s.BlobStore.(BlobLister)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return lister.DeleteObject(path.Join(s.prefix, key))
+}
+
+func (s *prefixedStore) EnsureLifecycle(prefix string, ttl time.Duration, install bool) error {
+	if len(prefix) == 0 {
+		return ErrEmptyPrefix
+	}
+
+	lifecycle, ok := s.BlobStore.(LifecycleStore)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	return lifecycle.EnsureLifecycle(path.Join(s.prefix, prefix), ttl, install)
+}
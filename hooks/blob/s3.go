@@ -1,12 +1,13 @@
 package blob
 
 import (
-	"bytes"
 	"io"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -18,33 +19,42 @@ type S3Remote interface {
 	PutObject(key string, r io.Reader, meta map[string]string) (*S3Spec, error)
 }
 
+// NewS3Remote returns S3 compatible bucket access using aws-sdk-go v1.
+// Deprecated: use NewBlobStore with s3:// URL.
 func NewS3Remote(accoutID, secretKey, endpoint, region, bucket string) (s3Client S3Remote, err error) {
-	sess, err := session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(accoutID, secretKey, ""),
-		Endpoint:    aws.String(endpoint),
-		Region:      aws.String(region),
-	})
+	return newS3Remote(accoutID, secretKey, endpoint, region, bucket)
+}
+
+const defaultS3Region = "us-east-1"
+
+func newS3Remote(accoutID, secretKey, endpoint, region, bucket string) (*s3Remote, error) {
+	if len(region) == 0 {
+		region = defaultS3Region
+	}
+
+	cfg := &aws.Config{
+		Region: aws.String(region),
+	}
+
+	if len(accoutID) > 0 || len(secretKey) > 0 {
+		cfg.Credentials = credentials.NewStaticCredentials(accoutID, secretKey, "")
+	}
+
+	if len(endpoint) > 0 {
+		// custom endpoints are usually MinIO and alike
+		cfg.Endpoint = aws.String(endpoint)
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	sess, err := session.NewSession(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	s3Client = &s3Remote{
+	return &s3Remote{
 		bucket: bucket,
 		cli:    s3.New(sess),
-	}
-
-	return s3Client, nil
-}
-
-type S3Spec struct {
-	Path      string
-	Key       string
-	Body      io.ReadCloser
-	ETag      string
-	Version   string
-	UpdatedAt time.Time
-	Meta      map[string]string
-	Size      int64
+	}, nil
 }
 
 type s3Remote struct {
@@ -53,9 +63,8 @@ type s3Remote struct {
 }
 
 func (s *s3Remote) CheckAccess(prefix string) error {
-	body := []byte(time.Now().UTC().String())
 	_, err := s.cli.PutObject(&s3.PutObjectInput{
-		Body:        aws.ReadSeekCloser(bytes.NewReader(body)),
+		Body:        aws.ReadSeekCloser(touchBody()),
 		Bucket:      aws.String(s.bucket),
 		ContentType: aws.String("text/plain"),
 		Key:         aws.String(path.Join(prefix, "_touch")),
@@ -65,8 +74,13 @@ func (s *s3Remote) CheckAccess(prefix string) error {
 }
 
 func (s *s3Remote) PutObject(key string, r io.Reader, meta map[string]string) (*S3Spec, error) {
+	body, size, err := readSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+
 	obj, err := s.cli.PutObject(&s3.PutObjectInput{
-		Body:     aws.ReadSeekCloser(r),
+		Body:     body,
 		Bucket:   aws.String(s.bucket),
 		Key:      aws.String(key),
 		Metadata: aws.StringMap(meta),
@@ -80,7 +94,160 @@ func (s *s3Remote) PutObject(key string, r io.Reader, meta map[string]string) (*
 		ETag:    aws.StringValue(obj.ETag),
 		Version: aws.StringValue(obj.VersionId),
 		Meta:    meta,
+		Size:    size,
 	}
 
 	return spec, err
 }
+
+func (s *s3Remote) GetObject(key string) (*BlobSpec, error) {
+	obj, err := s.cli.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &BlobSpec{
+		Key:       key,
+		Body:      obj.Body,
+		ETag:      aws.StringValue(obj.ETag),
+		Version:   aws.StringValue(obj.VersionId),
+		UpdatedAt: aws.TimeValue(obj.LastModified),
+		Meta:      lowerKeys(aws.StringValueMap(obj.Metadata)),
+		Size:      aws.Int64Value(obj.ContentLength),
+	}, nil
+}
+
+// lowerKeys normalizes metadata keys, canonicalized as HTTP headers by aws-sdk-go v1.
+func lowerKeys(meta map[string]string) map[string]string {
+	result := make(map[string]string, len(meta))
+	for k, v := range meta {
+		result[strings.ToLower(k)] = v
+	}
+
+	return result
+}
+
+func (s *s3Remote) ListObjects(prefix string, fn func(info *BlobInfo) error) error {
+	var fnErr error
+
+	err := s.cli.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(listPrefix(prefix)),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			if fnErr = fn(&BlobInfo{
+				Key:       aws.StringValue(obj.Key),
+				Size:      aws.Int64Value(obj.Size),
+				UpdatedAt: aws.TimeValue(obj.LastModified),
+			}); fnErr != nil {
+				return false
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	return fnErr
+}
+
+func (s *s3Remote) DeleteObject(key string) error {
+	_, err := s.cli.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
+func (s *s3Remote) EnsureLifecycle(prefix string, ttl time.Duration, install bool) error {
+	if len(prefix) == 0 {
+		return ErrEmptyPrefix
+	}
+
+	days := lifecycleDays(ttl)
+
+	var rules []*s3.LifecycleRule
+	out, err := s.cli.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.bucket),
+	})
+
+	switch aerr, _ := err.(awserr.Error); {
+	case err == nil:
+		rules = out.Rules
+	case aerr != nil && aerr.Code() == "NoSuchLifecycleConfiguration":
+	case aerr != nil && aerr.Code() == "NotImplemented":
+		return ErrNotSupported
+	default:
+		return err
+	}
+
+	var (
+		ruleID   = lifecycleRuleID(prefix)
+		checkErr = ErrNoLifecycleRule
+		keep     = make([]*s3.LifecycleRule, 0, len(rules)+1)
+	)
+
+	for _, rule := range rules {
+		// size filters are not known to aws-sdk-go v1, so these can't be told apart from no filter
+		var (
+			rulePrefix   = aws.StringValue(rule.Prefix)
+			otherFilters bool
+		)
+		if rule.Filter != nil {
+			rulePrefix = aws.StringValue(rule.Filter.Prefix)
+			otherFilters = rule.Filter.Tag != nil || rule.Filter.And != nil
+		}
+
+		var ruleDays int
+		if rule.Expiration != nil {
+			ruleDays = int(aws.Int64Value(rule.Expiration.Days))
+		}
+
+		enabled := aws.StringValue(rule.Status) == s3.ExpirationStatusEnabled
+		if err := checkLifecycleRule(prefix, days, rulePrefix, ruleDays, enabled, otherFilters); err == nil {
+			return nil
+		} else if err != ErrNoLifecycleRule {
+			checkErr = err
+		}
+
+		// the previously installed rule is replaced
+		if aws.StringValue(rule.ID) != ruleID {
+			keep = append(keep, rule)
+		}
+	}
+
+	if !install {
+		return checkErr
+	}
+
+	keep = append(keep, &s3.LifecycleRule{
+		ID:     aws.String(ruleID),
+		Status: aws.String(s3.ExpirationStatusEnabled),
+		Filter: &s3.LifecycleRuleFilter{
+			Prefix: aws.String(listPrefix(prefix)),
+		},
+		Expiration: &s3.LifecycleExpiration{
+			Days: aws.Int64(int64(days)),
+		},
+	})
+
+	_, err = s.cli.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+			Rules: keep,
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotImplemented" {
+		return ErrNotSupported
+	}
+
+	return err
+}
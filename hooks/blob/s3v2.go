@@ -0,0 +1,234 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// NewS3RemoteV2 returns a BlobStore for S3 compatible bucket using aws-sdk-go-v2.
+// Default credentials chain is used, when access keys are empty.
+func NewS3RemoteV2(accessKeyID, secretKey, endpoint, region, bucket string) (BlobStore, error) {
+	if len(region) == 0 {
+		region = defaultS3Region
+	}
+
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		// checksums are optional for S3, but not supported by every S3 compatible storage
+		config.WithRequestChecksumCalculation(aws.RequestChecksumCalculationWhenRequired),
+		config.WithResponseChecksumValidation(aws.ResponseChecksumValidationWhenRequired),
+	}
+
+	if len(accessKeyID) > 0 || len(secretKey) > 0 {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(accessKeyID, secretKey, ""),
+		))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cli := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if len(endpoint) > 0 {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &s3RemoteV2{
+		bucket: bucket,
+		cli:    cli,
+	}, nil
+}
+
+type s3RemoteV2 struct {
+	bucket string
+	cli    *s3.Client
+}
+
+func (s *s3RemoteV2) CheckAccess(prefix string) error {
+	_, err := s.PutObject(path.Join(prefix, "_touch"), touchBody(), nil)
+	return err
+}
+
+func (s *s3RemoteV2) PutObject(key string, r io.Reader, meta map[string]string) (*BlobSpec, error) {
+	body, size, err := readSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := s.cli.PutObject(context.Background(), &s3.PutObjectInput{
+		Body:          body,
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentLength: aws.Int64(size),
+		Metadata:      meta,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlobSpec{
+		Key:     key,
+		ETag:    aws.ToString(obj.ETag),
+		Version: aws.ToString(obj.VersionId),
+		Meta:    meta,
+		Size:    size,
+	}, nil
+}
+
+func (s *s3RemoteV2) GetObject(key string) (*BlobSpec, error) {
+	obj, err := s.cli.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &BlobSpec{
+		Key:       key,
+		Body:      obj.Body,
+		ETag:      aws.ToString(obj.ETag),
+		Version:   aws.ToString(obj.VersionId),
+		UpdatedAt: aws.ToTime(obj.LastModified),
+		Meta:      obj.Metadata,
+		Size:      aws.ToInt64(obj.ContentLength),
+	}, nil
+}
+
+func (s *s3RemoteV2) ListObjects(prefix string, fn func(info *BlobInfo) error) error {
+	pages := s3.NewListObjectsV2Paginator(s.cli, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(listPrefix(prefix)),
+	})
+
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(context.Background())
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Contents {
+			if err := fn(&BlobInfo{
+				Key:       aws.ToString(obj.Key),
+				Size:      aws.ToInt64(obj.Size),
+				UpdatedAt: aws.ToTime(obj.LastModified),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *s3RemoteV2) DeleteObject(key string) error {
+	_, err := s.cli.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
+func (s *s3RemoteV2) EnsureLifecycle(prefix string, ttl time.Duration, install bool) error {
+	if len(prefix) == 0 {
+		return ErrEmptyPrefix
+	}
+
+	days := lifecycleDays(ttl)
+
+	var rules []types.LifecycleRule
+	out, err := s.cli.GetBucketLifecycleConfiguration(context.Background(), &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.bucket),
+	})
+
+	var apiErr smithy.APIError
+	switch errors.As(err, &apiErr); {
+	case err == nil:
+		rules = out.Rules
+	case apiErr != nil && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration":
+	case apiErr != nil && apiErr.ErrorCode() == "NotImplemented":
+		return ErrNotSupported
+	default:
+		return err
+	}
+
+	var (
+		ruleID   = lifecycleRuleID(prefix)
+		checkErr = ErrNoLifecycleRule
+		keep     = make([]types.LifecycleRule, 0, len(rules)+1)
+	)
+
+	for _, rule := range rules {
+		var (
+			rulePrefix   = aws.ToString(rule.Prefix)
+			otherFilters bool
+		)
+		if f := rule.Filter; f != nil {
+			rulePrefix = aws.ToString(f.Prefix)
+			otherFilters = f.Tag != nil || f.And != nil || f.ObjectSizeGreaterThan != nil || f.ObjectSizeLessThan != nil
+		}
+
+		var ruleDays int
+		if rule.Expiration != nil {
+			ruleDays = int(aws.ToInt32(rule.Expiration.Days))
+		}
+
+		enabled := rule.Status == types.ExpirationStatusEnabled
+		if err := checkLifecycleRule(prefix, days, rulePrefix, ruleDays, enabled, otherFilters); err == nil {
+			return nil
+		} else if err != ErrNoLifecycleRule {
+			checkErr = err
+		}
+
+		// the previously installed rule is replaced
+		if aws.ToString(rule.ID) != ruleID {
+			keep = append(keep, rule)
+		}
+	}
+
+	if !install {
+		return checkErr
+	}
+
+	keep = append(keep, types.LifecycleRule{
+		ID:     aws.String(ruleID),
+		Status: types.ExpirationStatusEnabled,
+		Filter: &types.LifecycleRuleFilter{
+			Prefix: aws.String(listPrefix(prefix)),
+		},
+		Expiration: &types.LifecycleExpiration{
+			Days: aws.Int32(int32(days)),
+		},
+	})
+
+	_, err = s.cli.PutBucketLifecycleConfiguration(context.Background(), &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{
+			Rules: keep,
+		},
+	})
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented" {
+		return ErrNotSupported
+	}
+
+	return err
+}
@@ -0,0 +1,130 @@
+package blob
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// spool keeps payloads and state of uploads on disk:
+// <dir>/pending/<key>.blob and <dir>/pending/<key>.json, same for failed ones.
+type spool struct {
+	dir    string
+	logger RootLogger
+}
+
+const (
+	spoolPending = "pending"
+	spoolFailed  = "failed"
+)
+
+func newSpool(dir string, logger RootLogger) (*spool, error) {
+	for _, sub := range []string{spoolPending, spoolFailed} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+
+	return &spool{dir: dir, logger: logger}, nil
+}
+
+func (s *spool) path(state, key, ext string) string {
+	return filepath.Join(s.dir, state, url.PathEscape(key)+ext)
+}
+
+// save writes the upload state, and its payload too if requested.
+func (s *spool) save(state string, up *upload, withPayload bool) error {
+	if withPayload {
+		if _, err := writeFileAtomic(s.path(state, up.Key, ".blob"), func(w io.Writer) error {
+			_, err := w.Write(up.payload)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	_, err := writeFileAtomic(s.path(state, up.Key, ".json"), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(up.Upload)
+	})
+
+	return err
+}
+
+func (s *spool) remove(state string, up *upload) {
+	_ = os.Remove(s.path(state, up.Key, ".json"))
+	_ = os.Remove(s.path(state, up.Key, ".blob"))
+}
+
+// list loads uploads, and their payloads too if requested. Unreadable entries are skipped,
+// so a single corrupt file doesn't hold back the rest.
+func (s *spool) list(state string, withPayload bool) ([]*upload, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, state))
+	if err != nil {
+		return nil, err
+	}
+
+	var uploads []*upload
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		up, err := s.load(state, name, withPayload)
+		if err != nil {
+			s.logger.Errorf("skipping spooled blob upload %s/%s: %+v", state, name, err)
+			continue
+		}
+
+		uploads = append(uploads, up)
+	}
+
+	return uploads, nil
+}
+
+func (s *spool) load(state, name string, withPayload bool) (*upload, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, state, name))
+	if err != nil {
+		return nil, err
+	}
+
+	up := new(upload)
+	if err := json.Unmarshal(data, &up.Upload); err != nil {
+		return nil, err
+	} else if len(up.Key) == 0 {
+		return nil, errors.New("no blob key")
+	}
+
+	if withPayload {
+		if up.payload, err = os.ReadFile(s.path(state, up.Key, ".blob")); err != nil {
+			return nil, err
+		}
+	}
+
+	return up, nil
+}
+
+// prune removes the oldest uploads over max, and the ones queued before the deadline.
+func (s *spool) prune(state string, max int, deadline time.Time) {
+	uploads, err := s.list(state, false)
+	if err != nil {
+		s.logger.Errorf("failed to list spooled blob uploads: %+v", err)
+		return
+	}
+
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].QueuedAt.Before(uploads[j].QueuedAt)
+	})
+
+	for i, up := range uploads {
+		if len(uploads)-i > max || up.QueuedAt.Before(deadline) {
+			s.remove(state, up)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package blob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// BlobStore provides access to a blob storage backend.
+type BlobStore interface {
+	// CheckAccess verifies that blobs could be written under the prefix.
+	CheckAccess(prefix string) error
+	// PutObject uploads the blob with optional metadata.
+	PutObject(key string, r io.Reader, meta map[string]string) (*BlobSpec, error)
+	// GetObject downloads the blob, the caller must close its Body.
+	// Returns ErrNotFound if there is no such blob.
+	GetObject(key string) (*BlobSpec, error)
+}
+
+// BlobSpec describes a stored blob.
+type BlobSpec struct {
+	Path      string
+	Key       string
+	Body      io.ReadCloser
+	ETag      string
+	Version   string
+	UpdatedAt time.Time
+	Meta      map[string]string
+	Size      int64
+}
+
+// S3Spec is kept for compatibility, see BlobSpec.
+type S3Spec = BlobSpec
+
+// ErrNotFound is returned by BlobStore.GetObject if there is no such blob.
+var ErrNotFound = errors.New("blob not found")
+
+// StoreCredentials are used by NewBlobStore, unless overridden in the store URL.
+type StoreCredentials struct {
+	// Account is the access key ID for S3, the storage account name for Azure.
+	Account string
+	// Key is the secret access key for S3, the account key or SAS token for Azure,
+	// and the OAuth2 access token for GCS.
+	Key      string
+	Endpoint string
+	Region   string
+}
+
+// IsBlobStoreURL reports whether the URL selects a backend supported by NewBlobStore.
+func IsBlobStoreURL(storeURL string) bool {
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "file", "s3", "gs", "azblob":
+		return true
+	default:
+		return false
+	}
+}
+
+// NewBlobStore opens a blob store selected by the URL scheme:
+//
+//	file:///var/lib/blobs
+//	s3://bucket/prefix?region=us-east-1&endpoint=http://localhost:9000&sdk=v2
+//	gs://bucket/prefix?endpoint=http://localhost:4443
+//	azblob://container/prefix?account=name&endpoint=http://localhost:10000/devstoreaccount1
+//
+// An optional path after the bucket is used as a prefix of all keys. The query params
+// "account", "endpoint" and "region" override the credentials.
+func NewBlobStore(storeURL string, creds *StoreCredentials) (BlobStore, error) {
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse blob store URL: %w", err)
+	}
+
+	c := StoreCredentials{}
+	if creds != nil {
+		c = *creds
+	}
+
+	query := u.Query()
+	if v := query.Get("account"); len(v) > 0 {
+		c.Account = v
+	}
+
+	if v := query.Get("endpoint"); len(v) > 0 {
+		c.Endpoint = v
+	}
+
+	if v := query.Get("region"); len(v) > 0 {
+		c.Region = v
+	}
+
+	var (
+		store  BlobStore
+		prefix = strings.Trim(u.Path, "/")
+	)
+
+	switch u.Scheme {
+	case "file":
+		return NewFileStore(path.Join(u.Host, u.Path))
+	case "s3":
+		if query.Get("sdk") == "v2" {
+			store, err = NewS3RemoteV2(c.Account, c.Key, c.Endpoint, c.Region, u.Host)
+		} else {
+			store, err = newS3Remote(c.Account, c.Key, c.Endpoint, c.Region, u.Host)
+		}
+	case "gs":
+		store, err = NewGCSStore(u.Host, c.Key, c.Endpoint)
+	case "azblob":
+		store, err = NewAzureStore(c.Account, c.Key, c.Endpoint, u.Host)
+	default:
+		return nil, fmt.Errorf("unsupported blob store URL scheme: %q", u.Scheme)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prefix) > 0 {
+		store = &prefixedStore{
+			BlobStore: store,
+			prefix:    prefix,
+		}
+	}
+
+	return store, nil
+}
+
+// prefixedStore prepends the prefix to all keys.
+type prefixedStore struct {
+	BlobStore
+	prefix string
+}
+
+func (s *prefixedStore) CheckAccess(prefix string) error {
+	return s.BlobStore.CheckAccess(path.Join(s.prefix, prefix))
+}
+
+func (s *prefixedStore) PutObject(key string, r io.Reader, meta map[string]string) (*BlobSpec, error) {
+	return s.BlobStore.PutObject(path.Join(s.prefix, key), r, meta)
+}
+
+func (s *prefixedStore) GetObject(key string) (*BlobSpec, error) {
+	return s.BlobStore.GetObject(path.Join(s.prefix, key))
+}
+
+// readSeeker returns r if it's seekable, otherwise reads it into memory,
+// since SDKs need to know the size and rewind bodies for retries.
+func readSeeker(r io.Reader) (io.ReadSeeker, int64, error) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		size, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return nil, 0, err
+		}
+
+		return rs, size, nil
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+func touchBody() io.Reader {
+	return strings.NewReader(time.Now().UTC().String())
+}
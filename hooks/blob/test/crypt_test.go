@@ -0,0 +1,337 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	blobHook "github.com/InjectiveLabs/suplog/hooks/blob"
+)
+
+func newMasterKey(t *testing.T) []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	return key
+}
+
+// fakeKMS keeps data keys as is, failing the first failures calls of WrapKey.
+type fakeKMS struct {
+	mux      sync.Mutex
+	wraps    int
+	failures int
+}
+
+func (k *fakeKMS) WrapKey(dataKey []byte) (string, []byte, error) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	k.wraps++
+	if k.failures > 0 {
+		k.failures--
+		return "", nil, errors.New("KMS is unavailable")
+	}
+
+	return "fake", dataKey, nil
+}
+
+func (k *fakeKMS) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != "fake" {
+		return nil, blobHook.ErrUnknownKeyID
+	}
+
+	return wrapped, nil
+}
+
+func TestLocalKMS(t *testing.T) {
+	oldKey, newKey := newMasterKey(t), newMasterKey(t)
+
+	kms, err := blobHook.NewLocalKMS(oldKey)
+	require.NoError(t, err)
+
+	dataKey := newMasterKey(t)
+	keyID, wrapped, err := kms.WrapKey(dataKey)
+	require.NoError(t, err)
+	require.Equal(t, blobHook.MasterKeyID(oldKey), keyID)
+	require.True(t, strings.HasPrefix(keyID, "local:"))
+	require.False(t, bytes.Contains(wrapped, dataKey))
+
+	// after rotation, the old key is still used to unwrap
+	rotated, err := blobHook.NewLocalKMS(newKey, oldKey)
+	require.NoError(t, err)
+
+	unwrapped, err := rotated.UnwrapKey(keyID, wrapped)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, unwrapped)
+
+	newKeyID, _, err := rotated.WrapKey(dataKey)
+	require.NoError(t, err)
+	require.Equal(t, blobHook.MasterKeyID(newKey), newKeyID)
+
+	// the key ID is authenticated
+	_, err = rotated.UnwrapKey(newKeyID, wrapped)
+	require.Error(t, err)
+
+	withoutOld, err := blobHook.NewLocalKMS(newKey)
+	require.NoError(t, err)
+	_, err = withoutOld.UnwrapKey(keyID, wrapped)
+	require.ErrorIs(t, err, blobHook.ErrUnknownKeyID)
+
+	_, err = blobHook.NewLocalKMS([]byte("short"))
+	require.Error(t, err)
+	_, err = blobHook.NewLocalKMS()
+	require.ErrorIs(t, err, blobHook.ErrNoMasterKey)
+}
+
+func TestLocalKMSFromEnv(t *testing.T) {
+	oldKey, newKey := newMasterKey(t), newMasterKey(t)
+
+	t.Setenv("LOG_BLOB_MASTER_KEY", "")
+	t.Setenv("LOG_BLOB_MASTER_KEY_FILE", "")
+	_, err := blobHook.LocalKMSFromEnv()
+	require.ErrorIs(t, err, blobHook.ErrNoMasterKey)
+
+	t.Setenv("LOG_BLOB_MASTER_KEY", base64.StdEncoding.EncodeToString(oldKey))
+	kms, err := blobHook.LocalKMSFromEnv()
+	require.NoError(t, err)
+
+	keyID, _, err := kms.WrapKey(newMasterKey(t))
+	require.NoError(t, err)
+	require.Equal(t, blobHook.MasterKeyID(oldKey), keyID)
+
+	keyFile := filepath.Join(t.TempDir(), "master.keys")
+	require.NoError(t, os.WriteFile(keyFile, []byte(strings.Join([]string{
+		"# rotated on 2026-10-01",
+		base64.StdEncoding.EncodeToString(newKey),
+		"",
+		base64.StdEncoding.EncodeToString(oldKey),
+	}, "\n")), 0o600))
+
+	// the file takes precedence
+	t.Setenv("LOG_BLOB_MASTER_KEY_FILE", keyFile)
+	kms, err = blobHook.LocalKMSFromEnv()
+	require.NoError(t, err)
+
+	keyID, _, err = kms.WrapKey(newMasterKey(t))
+	require.NoError(t, err)
+	require.Equal(t, blobHook.MasterKeyID(newKey), keyID)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("not base64"), 0o600))
+	_, err = blobHook.LocalKMSFromEnv()
+	require.Error(t, err)
+}
+
+func TestBlobEncryption(t *testing.T) {
+	payload := strings.Repeat(`{"email": "user@example.com"}`, 200)
+
+	t.Run("encrypts with local KMS", func(t *testing.T) {
+		masterKey := newMasterKey(t)
+		kms, err := blobHook.NewLocalKMS(masterKey)
+		require.NoError(t, err)
+
+		store, err := blobHook.NewFileStore(t.TempDir())
+		require.NoError(t, err)
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:             "test",
+			BlobStore:       store,
+			BlobKMS:         kms,
+			BlobCompression: blobHook.CompressionZstd,
+		})
+		defer hook.Close()
+
+		log.WithField("blob", payload).Info("order of user@example.com")
+		require.NoError(t, hook.Flush(context.Background()))
+
+		fields := entryFields(t, out.String())
+		key := refKey(fields["blob"].(string))
+		require.NotContains(t, fields, "blob_sha256")
+		checksum := sha256.Sum256([]byte(payload))
+
+		spec, err := store.GetObject(key)
+		require.NoError(t, err)
+		stored, err := io.ReadAll(spec.Body)
+		spec.Body.Close()
+		require.NoError(t, err)
+
+		require.NotContains(t, string(stored), "user@example.com")
+		for _, v := range spec.Meta {
+			require.NotContains(t, v, "user@example.com")
+			require.NotContains(t, v, hex.EncodeToString(checksum[:]))
+		}
+
+		// metadata revealing the payload is sealed
+		require.NotContains(t, spec.Meta, blobHook.MetaSHA256)
+		require.NotContains(t, spec.Meta, blobHook.MetaOriginalSize)
+		require.NotContains(t, spec.Meta, blobHook.MetaMessage)
+		require.NotEmpty(t, spec.Meta[blobHook.MetaSealed])
+		require.Equal(t, blobHook.EncryptionAES256GCM, spec.Meta[blobHook.MetaEncryption])
+		require.Equal(t, blobHook.MasterKeyID(masterKey), spec.Meta[blobHook.MetaKeyID])
+		require.NotEmpty(t, spec.Meta[blobHook.MetaWrappedKey])
+		require.Equal(t, blobHook.CompressionZstd, spec.Meta[blobHook.MetaContentEncoding])
+		require.Less(t, len(stored), len(payload)/10)
+
+		_, _, err = blobHook.ReadBlob(store, key, nil)
+		require.ErrorIs(t, err, blobHook.ErrEncrypted)
+
+		data, decrypted, err := blobHook.ReadBlob(store, key, kms)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(data))
+		require.Equal(t, hex.EncodeToString(checksum[:]), decrypted.Meta[blobHook.MetaSHA256])
+		require.Equal(t, "order of user@example.com", decrypted.Meta[blobHook.MetaMessage])
+		require.NotContains(t, decrypted.Meta, blobHook.MetaSealed)
+
+		// every blob has its own data key
+		log.WithField("blob", payload).Info("with another encrypted blob")
+		require.NoError(t, hook.Flush(context.Background()))
+
+		another, err := store.GetObject(refKey(entryFields(t, out.String())["blob"].(string)))
+		require.NoError(t, err)
+		another.Body.Close()
+		require.NotEqual(t, spec.Meta[blobHook.MetaWrappedKey], another.Meta[blobHook.MetaWrappedKey])
+
+		// sealed metadata is bound to the data key
+		swapped := make(map[string]string, len(spec.Meta))
+		for k, v := range spec.Meta {
+			swapped[k] = v
+		}
+		swapped[blobHook.MetaSealed] = another.Meta[blobHook.MetaSealed]
+		_, err = blobHook.DecodeBlob(stored, swapped, kms)
+		require.Error(t, err)
+
+		stored[len(stored)-1] ^= 0xff
+		_, err = blobHook.DecodeBlob(stored, spec.Meta, kms)
+		require.Error(t, err)
+	})
+
+	t.Run("retries KMS failures", func(t *testing.T) {
+		kms := &fakeKMS{failures: 2}
+		store := newMemStore()
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:                "test",
+			BlobStore:          store,
+			BlobKMS:            kms,
+			UploadRetryBackoff: time.Millisecond,
+		})
+		defer hook.Close()
+
+		log.WithField("blob", payload).Info("with encrypted blob")
+		require.NoError(t, hook.Flush(context.Background()))
+		require.Empty(t, hook.Failed())
+		require.Equal(t, 3, kms.wraps)
+
+		data, _, err := blobHook.ReadBlob(store, refKey(blobRef(t, out.String())), kms)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(data))
+	})
+
+	t.Run("spools encrypted payloads", func(t *testing.T) {
+		spoolDir := t.TempDir()
+		kms, err := blobHook.NewLocalKMS(newMasterKey(t))
+		require.NoError(t, err)
+
+		failing := newMemStore()
+		failing.failures = -1
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:                "test",
+			BlobStore:          failing,
+			BlobKMS:            kms,
+			SpoolDir:           spoolDir,
+			UploadRetryBackoff: time.Hour,
+		})
+
+		log.WithField("blob", payload).Info("with encrypted blob")
+		key := refKey(blobRef(t, out.String()))
+		require.NoError(t, hook.Close())
+
+		var spooled int
+		require.NoError(t, filepath.WalkDir(spoolDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NotContains(t, string(data), "user@example.com")
+			spooled++
+
+			return nil
+		}))
+		require.Equal(t, 2, spooled)
+
+		store := newMemStore()
+		_, hook, _ = newTestLogger(t, &blobHook.HookOptions{
+			Env:       "test",
+			BlobStore: store,
+			BlobKMS:   kms,
+			SpoolDir:  spoolDir,
+		})
+		defer hook.Close()
+
+		require.NoError(t, hook.Flush(context.Background()))
+		data, _, err := blobHook.ReadBlob(store, key, kms)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(data))
+	})
+
+	t.Run("master key from env", func(t *testing.T) {
+		masterKey := newMasterKey(t)
+		t.Setenv("LOG_BLOB_MASTER_KEY_FILE", "")
+		t.Setenv("LOG_BLOB_MASTER_KEY", base64.StdEncoding.EncodeToString(masterKey))
+
+		store, err := blobHook.NewFileStore(t.TempDir())
+		require.NoError(t, err)
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:       "test",
+			BlobStore: store,
+		})
+		defer hook.Close()
+
+		log.WithField("blob", payload).Info("with encrypted blob")
+		require.NoError(t, hook.Flush(context.Background()))
+
+		kms, err := blobHook.NewLocalKMS(masterKey)
+		require.NoError(t, err)
+
+		data, spec, err := blobHook.ReadBlob(store, refKey(blobRef(t, out.String())), kms)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(data))
+		require.Equal(t, blobHook.MasterKeyID(masterKey), spec.Meta[blobHook.MetaKeyID])
+	})
+
+	t.Run("invalid master key disables uploads", func(t *testing.T) {
+		t.Setenv("LOG_BLOB_MASTER_KEY_FILE", "")
+		t.Setenv("LOG_BLOB_MASTER_KEY", base64.StdEncoding.EncodeToString([]byte("short")))
+
+		store := newMemStore()
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:       "test",
+			BlobStore: store,
+		})
+		defer hook.Close()
+
+		log.WithField("blob", payload).Info("with blob")
+		require.NoError(t, hook.Flush(context.Background()))
+
+		require.NotContains(t, entryFields(t, out.String()), "blob")
+		require.Zero(t, store.count())
+	})
+}
@@ -0,0 +1,169 @@
+package blob
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"mime"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	blobHook "github.com/InjectiveLabs/suplog/hooks/blob"
+)
+
+func TestDetectContentType(t *testing.T) {
+	var proto []byte
+	proto = protowire.AppendTag(proto, 1, protowire.BytesType)
+	proto = protowire.AppendString(proto, "order")
+	proto = protowire.AppendTag(proto, 2, protowire.VarintType)
+	proto = protowire.AppendVarint(proto, 1500)
+
+	for _, tc := range []struct {
+		data     string
+		expected string
+	}{
+		{`{"id": 1}`, blobHook.ContentTypeJSON},
+		{" [1, 2]\n", blobHook.ContentTypeJSON},
+		{`{"id": 1`, blobHook.ContentTypeText},
+		{"plain text\nwith lines", blobHook.ContentTypeText},
+		{"42", blobHook.ContentTypeText},
+		{string(proto), blobHook.ContentTypeProtobuf},
+		{"\xff\xff\xff\x00\x01", blobHook.ContentTypeBinary},
+		{"", blobHook.ContentTypeBinary},
+	} {
+		require.Equal(t, tc.expected, blobHook.DetectContentType([]byte(tc.data)), "%q", tc.data)
+	}
+}
+
+// entryFields parses the last log entry.
+func entryFields(t *testing.T, out string) map[string]interface{} {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &fields))
+
+	return fields
+}
+
+func TestBlobEncoding(t *testing.T) {
+	payload := `{"items": [` + strings.Repeat(`{"name": "item", "price": 100},`, 200) + `{}]}`
+
+	for _, compression := range []string{blobHook.CompressionNone, blobHook.CompressionGzip, blobHook.CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			store, err := blobHook.NewFileStore(t.TempDir())
+			require.NoError(t, err)
+
+			log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+				Env:             "test",
+				BlobStore:       store,
+				BlobCompression: compression,
+			})
+			defer hook.Close()
+
+			log.WithField("blob", payload).Warning("response of the order service")
+			require.NoError(t, hook.Flush(context.Background()))
+
+			fields := entryFields(t, out.String())
+			require.Equal(t, float64(len(payload)), fields["blob_size"])
+
+			data, spec, err := blobHook.ReadBlob(store, refKey(fields["blob"].(string)), nil)
+			require.NoError(t, err)
+			require.Equal(t, payload, string(data))
+
+			require.Equal(t, fields["blob_sha256"], spec.Meta[blobHook.MetaSHA256])
+			require.Equal(t, strconv.Itoa(len(payload)), spec.Meta[blobHook.MetaOriginalSize])
+			require.Equal(t, blobHook.ContentTypeJSON, spec.Meta[blobHook.MetaContentType])
+			require.Equal(t, "test", spec.Meta[blobHook.MetaEnv])
+			require.Equal(t, "warning", spec.Meta[blobHook.MetaLevel])
+			require.Equal(t, "response of the order service", spec.Meta[blobHook.MetaMessage])
+			require.NotEmpty(t, spec.Meta[blobHook.MetaExpiresAt])
+
+			if compression == blobHook.CompressionNone {
+				require.Empty(t, spec.Meta[blobHook.MetaContentEncoding])
+				require.Equal(t, int64(len(payload)), spec.Size)
+			} else {
+				require.Equal(t, compression, spec.Meta[blobHook.MetaContentEncoding])
+				require.Less(t, spec.Size, int64(len(payload)/10))
+			}
+		})
+	}
+
+	t.Run("small and incompressible blobs", func(t *testing.T) {
+		store, err := blobHook.NewFileStore(t.TempDir())
+		require.NoError(t, err)
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:                 "test",
+			BlobStore:           store,
+			BlobCompression:     blobHook.CompressionGzip,
+			BlobCompressMinSize: 100,
+		})
+		defer hook.Close()
+
+		random := make([]byte, 1000)
+		_, err = rand.Read(random)
+		require.NoError(t, err)
+
+		for _, blob := range []string{"small", string(random)} {
+			log.WithField("blob", blob).Info("blob")
+			require.NoError(t, hook.Flush(context.Background()))
+
+			data, spec, err := blobHook.ReadBlob(store, refKey(entryFields(t, out.String())["blob"].(string)), nil)
+			require.NoError(t, err)
+			require.Equal(t, blob, string(data))
+			require.Empty(t, spec.Meta[blobHook.MetaContentEncoding])
+		}
+	})
+
+	t.Run("message metadata", func(t *testing.T) {
+		store, err := blobHook.NewFileStore(t.TempDir())
+		require.NoError(t, err)
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:       "test",
+			BlobStore: store,
+		})
+		defer hook.Close()
+
+		msg := "заказ\nотклонён " + strings.Repeat("ж", 200)
+		log.WithField("blob", "payload").Error(msg)
+		require.NoError(t, hook.Flush(context.Background()))
+
+		_, spec, err := blobHook.ReadBlob(store, refKey(entryFields(t, out.String())["blob"].(string)), nil)
+		require.NoError(t, err)
+		require.NotContains(t, spec.Meta[blobHook.MetaMessage], "\n")
+
+		decoded, err := new(mime.WordDecoder).DecodeHeader(spec.Meta[blobHook.MetaMessage])
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(msg, decoded))
+		require.LessOrEqual(t, len(decoded), 256)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		store, err := blobHook.NewFileStore(t.TempDir())
+		require.NoError(t, err)
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:       "test",
+			BlobStore: store,
+		})
+		defer hook.Close()
+
+		log.WithField("blob", "payload").Info("blob")
+		require.NoError(t, hook.Flush(context.Background()))
+
+		key := refKey(entryFields(t, out.String())["blob"].(string))
+		spec, err := store.GetObject(key)
+		require.NoError(t, err)
+		spec.Body.Close()
+		require.NoError(t, os.WriteFile(spec.Path, []byte("tampered"), 0o644))
+
+		_, _, err = blobHook.ReadBlob(store, key, nil)
+		require.ErrorIs(t, err, blobHook.ErrChecksumMismatch)
+	})
+}
@@ -0,0 +1,421 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeObject struct {
+	data    []byte
+	meta    map[string]string
+	updated time.Time
+}
+
+// fakeBucket keeps objects of fake servers.
+type fakeBucket struct {
+	mux     sync.Mutex
+	objects map[string]*fakeObject
+	puts    int
+
+	// lifecycle is the raw S3 lifecycle configuration
+	lifecycle   []byte
+	noLifecycle bool
+}
+
+func newFakeBucket() *fakeBucket {
+	return &fakeBucket{
+		objects: make(map[string]*fakeObject),
+	}
+}
+
+func (b *fakeBucket) put(key string, data []byte, meta map[string]string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.puts++
+	b.objects[key] = &fakeObject{
+		data:    data,
+		meta:    meta,
+		updated: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func (b *fakeBucket) get(key string) *fakeObject {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.objects[key]
+}
+
+func (b *fakeBucket) delete(key string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	delete(b.objects, key)
+}
+
+// age moves the update time of the object into the past.
+func (b *fakeBucket) age(key string, d time.Duration) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.objects[key].updated = b.objects[key].updated.Add(-d)
+}
+
+func (b *fakeBucket) list(prefix string) []string {
+	var keys []string
+	for _, key := range b.keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+func (b *fakeBucket) keys() []string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	keys := make([]string, 0, len(b.objects))
+	for k := range b.objects {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// newFakeS3 serves path-style S3 requests for the bucket.
+func newFakeS3(t *testing.T, bucketName string, bucket *fakeBucket) *httptest.Server {
+	s3Error := func(w http.ResponseWriter, status int, code string) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimSuffix(r.URL.Path, "/") == "/"+bucketName {
+			serveFakeS3Bucket(w, r, bucket, s3Error)
+			return
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, "/"+bucketName+"/")
+		if key == r.URL.Path {
+			http.Error(w, "unknown bucket", http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			meta := make(map[string]string)
+			for name, values := range r.Header {
+				if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-meta-") {
+					meta[strings.TrimPrefix(name, "x-amz-meta-")] = values[0]
+				}
+			}
+
+			bucket.put(key, data, meta)
+			w.Header().Set("ETag", `"etag"`)
+		case http.MethodGet:
+			obj := bucket.get(key)
+			if obj == nil {
+				w.Header().Set("Content-Type", "application/xml")
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>not found</Message><Key>%s</Key></Error>`, key)
+				return
+			}
+
+			for k, v := range obj.meta {
+				w.Header().Set("x-amz-meta-"+k, v)
+			}
+			w.Header().Set("Last-Modified", obj.updated.Format(http.TimeFormat))
+			w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+			_, _ = w.Write(obj.data)
+		case http.MethodDelete:
+			bucket.delete(key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serveFakeS3Bucket serves bucket requests: listing and lifecycle configuration.
+func serveFakeS3Bucket(w http.ResponseWriter, r *http.Request, bucket *fakeBucket, s3Error func(http.ResponseWriter, int, string)) {
+	query := r.URL.Query()
+
+	switch {
+	case query.Has("lifecycle") && bucket.noLifecycle:
+		s3Error(w, http.StatusNotImplemented, "NotImplemented")
+	case query.Has("lifecycle") && r.Method == http.MethodGet:
+		bucket.mux.Lock()
+		lifecycle := bucket.lifecycle
+		bucket.mux.Unlock()
+
+		if lifecycle == nil {
+			s3Error(w, http.StatusNotFound, "NoSuchLifecycleConfiguration")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(lifecycle)
+	case query.Has("lifecycle") && r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+
+		bucket.mux.Lock()
+		bucket.lifecycle = data
+		bucket.mux.Unlock()
+	case query.Get("list-type") == "2" && r.Method == http.MethodGet:
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><IsTruncated>false</IsTruncated>`)
+		for _, key := range bucket.list(query.Get("prefix")) {
+			obj := bucket.get(key)
+			fmt.Fprintf(&b, `<Contents><Key>%s</Key><LastModified>%s</LastModified><Size>%d</Size></Contents>`,
+				key, obj.updated.Format(time.RFC3339), len(obj.data))
+		}
+		b.WriteString(`</ListBucketResult>`)
+
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = io.WriteString(w, b.String())
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+// newFakeGCS serves GCS JSON API requests for the bucket.
+func newFakeGCS(t *testing.T, bucketName, token string, bucket *fakeBucket) *httptest.Server {
+	objectResource := func(key string, obj *fakeObject) map[string]interface{} {
+		return map[string]interface{}{
+			"name":       key,
+			"bucket":     bucketName,
+			"size":       strconv.Itoa(len(obj.data)),
+			"generation": "1",
+			"updated":    obj.updated,
+			"metadata":   obj.meta,
+		}
+	}
+
+	objectJSON := func(w http.ResponseWriter, key string, obj *fakeObject) {
+		_ = json.NewEncoder(w).Encode(objectResource(key, obj))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		uploadPath := "/upload/storage/v1/b/" + bucketName + "/o"
+		objectPrefix := "/storage/v1/b/" + bucketName + "/o/"
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == uploadPath:
+			_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || r.URL.Query().Get("uploadType") != "multipart" {
+				http.Error(w, "bad upload", http.StatusBadRequest)
+				return
+			}
+
+			mr := multipart.NewReader(r.Body, params["boundary"])
+			metaPart, err := mr.NextPart()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var resource struct {
+				Name     string            `json:"name"`
+				Metadata map[string]string `json:"metadata"`
+			}
+			if err := json.NewDecoder(metaPart).Decode(&resource); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			mediaPart, err := mr.NextPart()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			data, _ := io.ReadAll(mediaPart)
+			bucket.put(resource.Name, data, resource.Metadata)
+			objectJSON(w, resource.Name, bucket.get(resource.Name))
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.EscapedPath(), objectPrefix):
+			key, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), objectPrefix))
+			obj := bucket.get(key)
+			if obj == nil {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+
+			if r.URL.Query().Get("alt") == "media" {
+				_, _ = w.Write(obj.data)
+				return
+			}
+
+			objectJSON(w, key, obj)
+		case r.Method == http.MethodGet && r.URL.Path == strings.TrimSuffix(objectPrefix, "/"):
+			items := []interface{}{}
+			for _, key := range bucket.list(r.URL.Query().Get("prefix")) {
+				items = append(items, objectResource(key, bucket.get(key)))
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"kind":  "storage#objects",
+				"items": items,
+			})
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.EscapedPath(), objectPrefix):
+			key, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), objectPrefix))
+			if bucket.get(key) == nil {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+
+			bucket.delete(key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "unsupported", http.StatusBadRequest)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newFakeAzure serves Azure Blob requests for the container, as Azurite does: the account is in the path.
+func newFakeAzure(t *testing.T, account, accountKey, container string, bucket *fakeBucket) *httptest.Server {
+	key, err := base64.StdEncoding.DecodeString(accountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "SharedKey "+account+":"+azureSignature(r, account, key) {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+
+		if r.URL.Path == "/"+account+"/"+container && r.URL.Query().Get("comp") == "list" {
+			var b strings.Builder
+			b.WriteString(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`)
+			for _, key := range bucket.list(r.URL.Query().Get("prefix")) {
+				obj := bucket.get(key)
+				fmt.Fprintf(&b, `<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>%d</Content-Length></Properties><Metadata>`,
+					key, obj.updated.Format(http.TimeFormat), len(obj.data))
+				for k, v := range obj.meta {
+					fmt.Fprintf(&b, `<%s>%s</%s>`, k, v, k)
+				}
+				b.WriteString(`</Metadata></Blob>`)
+			}
+			b.WriteString(`</Blobs><NextMarker /></EnumerationResults>`)
+
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = io.WriteString(w, b.String())
+			return
+		}
+
+		blobPath, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/"+account+"/"+container+"/"))
+
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				http.Error(w, "bad blob type", http.StatusBadRequest)
+				return
+			}
+
+			data, _ := io.ReadAll(r.Body)
+			meta := make(map[string]string)
+			for name, values := range r.Header {
+				if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-meta-") {
+					meta[strings.TrimPrefix(name, "x-ms-meta-")] = values[0]
+				}
+			}
+
+			bucket.put(blobPath, data, meta)
+			w.Header().Set("ETag", `"0x1"`)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			obj := bucket.get(blobPath)
+			if obj == nil {
+				http.Error(w, "BlobNotFound", http.StatusNotFound)
+				return
+			}
+
+			for k, v := range obj.meta {
+				w.Header().Set("x-ms-meta-"+k, v)
+			}
+			w.Header().Set("Last-Modified", obj.updated.Format(http.TimeFormat))
+			_, _ = w.Write(obj.data)
+		case http.MethodDelete:
+			if bucket.get(blobPath) == nil {
+				http.Error(w, "BlobNotFound", http.StatusNotFound)
+				return
+			}
+
+			bucket.delete(blobPath)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// azureSignature computes Shared Key signature of blob requests.
+func azureSignature(r *http.Request, account string, key []byte) string {
+	var headers []string
+	for name := range r.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			headers = append(headers, name+":"+r.Header.Get(name))
+		}
+	}
+	sort.Strings(headers)
+
+	contentLength := r.Header.Get("Content-Length")
+	if r.ContentLength > 0 {
+		contentLength = strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	stringToSign := strings.Join([]string{
+		r.Method, "", "", contentLength, "", r.Header.Get("Content-Type"), "", "", "", "", "", "",
+	}, "\n") + "\n" + strings.Join(headers, "\n") + "\n/" + account + r.URL.EscapedPath()
+
+	query := r.URL.Query()
+	params := make([]string, 0, len(query))
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+
+	for _, name := range params {
+		stringToSign += "\n" + name + ":" + strings.Join(query[name], ",")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,34 @@
+package blob
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	blobHook "github.com/InjectiveLabs/suplog/hooks/blob"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+func newTestLogger(t *testing.T, opt *blobHook.HookOptions) (suplog.Logger, *blobHook.Hook, *strings.Builder) {
+	out := new(strings.Builder)
+	hook := blobHook.NewHook(suplog.DefaultLogger, opt)
+	log := suplog.NewLogger(out, new(suplog.JSONFormatter), hook)
+
+	return log, hook, out
+}
+
+// blobRef returns the blob field of the last logged entry.
+func blobRef(t *testing.T, out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &entry))
+
+	ref, ok := entry["blob"].(string)
+	require.True(t, ok, "no blob reference in %s", lines[len(lines)-1])
+
+	return ref
+}
@@ -0,0 +1,409 @@
+package blob
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	blobHook "github.com/InjectiveLabs/suplog/hooks/blob"
+)
+
+const azuriteKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+// retentionStore is a store, which blobs could be made older.
+type retentionStore struct {
+	store blobHook.BlobStore
+	age   func(key string, d time.Duration)
+	// listsMeta is set for stores returning expiration metadata in listings
+	listsMeta bool
+}
+
+func retentionStores() map[string]func(t *testing.T) *retentionStore {
+	stores := map[string]func(t *testing.T) *retentionStore{
+		"file": func(t *testing.T) *retentionStore {
+			dir := t.TempDir()
+			store, err := blobHook.NewBlobStore("file://"+dir, nil)
+			require.NoError(t, err)
+
+			return &retentionStore{
+				store: store,
+				age: func(key string, d time.Duration) {
+					mtime := time.Now().Add(-d)
+					require.NoError(t, os.Chtimes(filepath.Join(dir, key), mtime, mtime))
+				},
+				listsMeta: true,
+			}
+		},
+		"gcs": func(t *testing.T) *retentionStore {
+			bucket := newFakeBucket()
+			srv := newFakeGCS(t, "logs", "access-token", bucket)
+
+			store, err := blobHook.NewBlobStore("gs://logs?endpoint="+srv.URL, &blobHook.StoreCredentials{
+				Key: "access-token",
+			})
+			require.NoError(t, err)
+
+			return &retentionStore{store: store, age: bucket.age, listsMeta: true}
+		},
+		"azure": func(t *testing.T) *retentionStore {
+			bucket := newFakeBucket()
+			srv := newFakeAzure(t, "devstoreaccount1", azuriteKey, "logs", bucket)
+
+			store, err := blobHook.NewBlobStore("azblob://logs/nested", &blobHook.StoreCredentials{
+				Account:  "devstoreaccount1",
+				Key:      azuriteKey,
+				Endpoint: srv.URL + "/devstoreaccount1",
+			})
+			require.NoError(t, err)
+
+			return &retentionStore{
+				store: store,
+				age: func(key string, d time.Duration) {
+					bucket.age("nested/"+key, d)
+				},
+				listsMeta: true,
+			}
+		},
+	}
+
+	for _, sdk := range []string{"v1", "v2"} {
+		sdk := sdk
+		stores["s3 "+sdk] = func(t *testing.T) *retentionStore {
+			bucket := newFakeBucket()
+			srv := newFakeS3(t, "logs", bucket)
+
+			store, err := blobHook.NewBlobStore("s3://logs/suplog?sdk="+sdk, &blobHook.StoreCredentials{
+				Account:  "minio",
+				Key:      "minio123",
+				Endpoint: srv.URL,
+			})
+			require.NoError(t, err)
+
+			return &retentionStore{
+				store: store,
+				age: func(key string, d time.Duration) {
+					bucket.age("suplog/"+key, d)
+				},
+			}
+		}
+	}
+
+	return stores
+}
+
+func listKeys(t *testing.T, store blobHook.BlobStore, prefix string) []string {
+	var keys []string
+	require.NoError(t, store.(blobHook.BlobLister).ListObjects(prefix, func(info *blobHook.BlobInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	}))
+
+	sort.Strings(keys)
+	return keys
+}
+
+func TestDeleteExpired(t *testing.T) {
+	const ttl = 30 * 24 * time.Hour
+
+	for name, newStore := range retentionStores() {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+
+			for _, key := range []string{"test/old", "test/new", "test2/old"} {
+				_, err := s.store.PutObject(key, strings.NewReader(key), nil)
+				require.NoError(t, err)
+			}
+
+			_, err := s.store.PutObject("test/expired", strings.NewReader("expired"), map[string]string{
+				blobHook.MetaExpiresAt: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+			})
+			require.NoError(t, err)
+
+			s.age("test/old", ttl+time.Hour)
+			s.age("test2/old", ttl+time.Hour)
+
+			require.Equal(t, []string{"test/expired", "test/new", "test/old"}, listKeys(t, s.store, "test"))
+
+			expected := []string{"test/old"}
+			if s.listsMeta {
+				expected = []string{"test/expired", "test/old"}
+			}
+
+			stats, err := blobHook.DeleteExpired(s.store, "test", ttl, true)
+			require.NoError(t, err)
+			require.Equal(t, 3, stats.Scanned)
+			require.Equal(t, len(expected), stats.Deleted)
+			require.Equal(t, []string{"test/expired", "test/new", "test/old"}, listKeys(t, s.store, "test"))
+
+			stats, err = blobHook.DeleteExpired(s.store, "test", ttl, false)
+			require.NoError(t, err)
+			require.Equal(t, len(expected), stats.Deleted)
+
+			remaining := []string{"test/new"}
+			if !s.listsMeta {
+				remaining = []string{"test/expired", "test/new"}
+			}
+
+			require.Equal(t, remaining, listKeys(t, s.store, "test"))
+			require.Equal(t, []string{"test2/old"}, listKeys(t, s.store, "test2"))
+
+			_, err = s.store.GetObject("test/old")
+			require.ErrorIs(t, err, blobHook.ErrNotFound)
+		})
+	}
+}
+
+func TestRetentionEmptyPrefix(t *testing.T) {
+	const ttl = time.Hour
+
+	dir := t.TempDir()
+	store, err := blobHook.NewBlobStore("file://"+dir, nil)
+	require.NoError(t, err)
+
+	for _, key := range []string{"test/old", "other/old"} {
+		_, err := store.PutObject(key, strings.NewReader(key), nil)
+		require.NoError(t, err)
+
+		mtime := time.Now().Add(-2 * ttl)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, key), mtime, mtime))
+	}
+
+	_, err = blobHook.DeleteExpired(store, "", ttl, false)
+	require.ErrorIs(t, err, blobHook.ErrEmptyPrefix)
+	require.Equal(t, []string{"other/old", "test/old"}, listKeys(t, store, ""))
+
+	stats, err := blobHook.DeleteExpired(store, blobHook.AllBlobs, ttl, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.Deleted)
+	require.Empty(t, listKeys(t, store, ""))
+
+	for _, storeURL := range []string{"s3://logs?sdk=v1", "s3://logs?sdk=v2", "s3://logs/suplog?sdk=v2"} {
+		t.Run(storeURL, func(t *testing.T) {
+			bucket := newFakeBucket()
+			srv := newFakeS3(t, "logs", bucket)
+
+			store, err := blobHook.NewBlobStore(storeURL, &blobHook.StoreCredentials{
+				Account:  "minio",
+				Key:      "minio123",
+				Endpoint: srv.URL,
+			})
+			require.NoError(t, err)
+
+			lifecycle := store.(blobHook.LifecycleStore)
+			require.ErrorIs(t, lifecycle.EnsureLifecycle("", ttl, true), blobHook.ErrEmptyPrefix)
+			require.Nil(t, bucket.lifecycle)
+
+			require.NoError(t, lifecycle.EnsureLifecycle(blobHook.AllBlobs, ttl, true))
+
+			expected := map[string]string{"suplog-retention": " 1d"}
+			if strings.Contains(storeURL, "/suplog") {
+				expected = map[string]string{"suplog-retention-suplog": "suplog/ 1d"}
+			}
+
+			require.Equal(t, expected, lifecycleRules(t, bucket))
+		})
+	}
+}
+
+func TestFileStoreListSkipsHidden(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blobHook.NewBlobStore("file://"+dir, nil)
+	require.NoError(t, err)
+
+	_, err = store.PutObject("test/blob", strings.NewReader("payload"), map[string]string{"level": "info"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test", ".blob.tmp123"), nil, 0o644))
+
+	require.Equal(t, []string{"test/blob"}, listKeys(t, store, ""))
+	require.NoFileExists(t, filepath.Join(dir, ".meta", "test", "blob"))
+
+	require.NoError(t, store.(blobHook.BlobLister).DeleteObject("test/blob"))
+	require.NoFileExists(t, filepath.Join(dir, ".meta", "test", "blob.json"))
+	require.NoError(t, store.(blobHook.BlobLister).DeleteObject("test/blob"))
+}
+
+// lifecycleRules parses rules of the lifecycle configuration put into the fake bucket.
+func lifecycleRules(t *testing.T, bucket *fakeBucket) map[string]string {
+	var config struct {
+		Rules []struct {
+			ID     string `xml:"ID"`
+			Prefix string `xml:"Filter>Prefix"`
+			Days   int    `xml:"Expiration>Days"`
+		} `xml:"Rule"`
+	}
+
+	bucket.mux.Lock()
+	defer bucket.mux.Unlock()
+	require.NoError(t, xml.Unmarshal(bucket.lifecycle, &config))
+
+	rules := make(map[string]string)
+	for _, rule := range config.Rules {
+		rules[rule.ID] = fmt.Sprintf("%s %dd", rule.Prefix, rule.Days)
+	}
+
+	return rules
+}
+
+func TestS3Lifecycle(t *testing.T) {
+	const otherRule = `<LifecycleConfiguration><Rule><ID>other</ID><Status>Enabled</Status>` +
+		`<Filter><Prefix>other/</Prefix></Filter><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`
+
+	for _, sdk := range []string{"v1", "v2"} {
+		t.Run(sdk, func(t *testing.T) {
+			bucket := newFakeBucket()
+			srv := newFakeS3(t, "logs", bucket)
+
+			store, err := blobHook.NewBlobStore("s3://logs/suplog?sdk="+sdk, &blobHook.StoreCredentials{
+				Account:  "minio",
+				Key:      "minio123",
+				Endpoint: srv.URL,
+			})
+			require.NoError(t, err)
+
+			lifecycle := store.(blobHook.LifecycleStore)
+			require.ErrorIs(t, lifecycle.EnsureLifecycle("test", 30*24*time.Hour, false), blobHook.ErrNoLifecycleRule)
+
+			bucket.lifecycle = []byte(otherRule)
+			require.ErrorIs(t, lifecycle.EnsureLifecycle("test", 30*24*time.Hour, false), blobHook.ErrNoLifecycleRule)
+
+			require.NoError(t, lifecycle.EnsureLifecycle("test", 30*24*time.Hour, true))
+			require.Equal(t, map[string]string{
+				"other":                        "other/ 1d",
+				"suplog-retention-suplog-test": "suplog/test/ 30d",
+			}, lifecycleRules(t, bucket))
+
+			require.NoError(t, lifecycle.EnsureLifecycle("test", 30*24*time.Hour, false))
+			require.NoError(t, lifecycle.EnsureLifecycle("test", 40*24*time.Hour, false))
+
+			// the rule keeps blobs longer than needed
+			err = lifecycle.EnsureLifecycle("test", 12*time.Hour, false)
+			require.Error(t, err)
+			require.NotErrorIs(t, err, blobHook.ErrNoLifecycleRule)
+
+			require.NoError(t, lifecycle.EnsureLifecycle("test", 12*time.Hour, true))
+			require.Equal(t, map[string]string{
+				"other":                        "other/ 1d",
+				"suplog-retention-suplog-test": "suplog/test/ 1d",
+			}, lifecycleRules(t, bucket))
+
+			bucket.noLifecycle = true
+			require.ErrorIs(t, lifecycle.EnsureLifecycle("test", 12*time.Hour, true), blobHook.ErrNotSupported)
+		})
+	}
+}
+
+func TestS3LifecycleFilters(t *testing.T) {
+	rules := map[string]string{
+		"tag": `<Filter><Tag><Key>keep</Key><Value>no</Value></Tag></Filter>`,
+		"and": `<Filter><And><Prefix></Prefix><Tag><Key>keep</Key><Value>no</Value></Tag></And></Filter>`,
+	}
+
+	for _, sdk := range []string{"v1", "v2"} {
+		for name, filter := range rules {
+			t.Run(sdk+" "+name, func(t *testing.T) {
+				bucket := newFakeBucket()
+				srv := newFakeS3(t, "logs", bucket)
+
+				store, err := blobHook.NewBlobStore("s3://logs/suplog?sdk="+sdk, &blobHook.StoreCredentials{
+					Account:  "minio",
+					Key:      "minio123",
+					Endpoint: srv.URL,
+				})
+				require.NoError(t, err)
+
+				// the rule has no prefix, but applies only to tagged blobs
+				bucket.lifecycle = []byte(`<LifecycleConfiguration><Rule><ID>tagged</ID><Status>Enabled</Status>` +
+					filter + `<Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`)
+
+				lifecycle := store.(blobHook.LifecycleStore)
+				require.ErrorIs(t, lifecycle.EnsureLifecycle("test", 30*24*time.Hour, false), blobHook.ErrNoLifecycleRule)
+			})
+		}
+	}
+
+	t.Run("v2 size", func(t *testing.T) {
+		bucket := newFakeBucket()
+		srv := newFakeS3(t, "logs", bucket)
+
+		store, err := blobHook.NewBlobStore("s3://logs/suplog?sdk=v2", &blobHook.StoreCredentials{
+			Account:  "minio",
+			Key:      "minio123",
+			Endpoint: srv.URL,
+		})
+		require.NoError(t, err)
+
+		bucket.lifecycle = []byte(`<LifecycleConfiguration><Rule><ID>large</ID><Status>Enabled</Status>` +
+			`<Filter><ObjectSizeGreaterThan>1048576</ObjectSizeGreaterThan></Filter>` +
+			`<Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`)
+
+		lifecycle := store.(blobHook.LifecycleStore)
+		require.ErrorIs(t, lifecycle.EnsureLifecycle("test", 30*24*time.Hour, false), blobHook.ErrNoLifecycleRule)
+	})
+}
+
+func TestBlobHookRetention(t *testing.T) {
+	t.Run("expiration metadata and janitor", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := blobHook.NewFileStore(dir)
+		require.NoError(t, err)
+
+		_, err = store.PutObject("test/old", strings.NewReader("old"), nil)
+		require.NoError(t, err)
+
+		mtime := time.Now().Add(-2 * time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "test", "old"), mtime, mtime))
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:              "test",
+			BlobStore:        store,
+			BlobRetentionTTL: time.Hour,
+			JanitorInterval:  10 * time.Millisecond,
+		})
+
+		log.WithField("blob", "payload").Info("with blob")
+		require.NoError(t, hook.Flush(context.Background()))
+
+		spec, err := store.GetObject(refKey(blobRef(t, out.String())))
+		require.NoError(t, err)
+		spec.Body.Close()
+
+		expiresAt, err := time.Parse(time.RFC3339, spec.Meta[blobHook.MetaExpiresAt])
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(filepath.Join(dir, "test", "old"))
+			return os.IsNotExist(err)
+		}, time.Second, 10*time.Millisecond)
+
+		require.NoError(t, hook.Close())
+		require.FileExists(t, spec.Path)
+	})
+
+	t.Run("installs lifecycle rule", func(t *testing.T) {
+		bucket := newFakeBucket()
+		srv := newFakeS3(t, "logs", bucket)
+
+		_, hook, _ := newTestLogger(t, &blobHook.HookOptions{
+			Env:               "test",
+			BlobStoreURL:      "s3://logs",
+			BlobStoreAccount:  "minio",
+			BlobStoreKey:      "minio123",
+			BlobStoreEndpoint: srv.URL,
+			BlobLifecycle:     blobHook.LifecycleInstall,
+		})
+		defer hook.Close()
+
+		require.Equal(t, map[string]string{
+			"suplog-retention-test": "test/ 30d",
+		}, lifecycleRules(t, bucket))
+	})
+}
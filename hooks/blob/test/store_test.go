@@ -0,0 +1,125 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	blobHook "github.com/InjectiveLabs/suplog/hooks/blob"
+)
+
+func testStore(t *testing.T, store blobHook.BlobStore) {
+	require.NoError(t, store.CheckAccess("test"))
+
+	spec, err := store.PutObject("test/blob-1", strings.NewReader("payload"), map[string]string{
+		blobHook.MetaLevel:        "error",
+		blobHook.MetaOriginalSize: "7",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), spec.Size)
+
+	spec, err = store.GetObject("test/blob-1")
+	require.NoError(t, err)
+	defer spec.Body.Close()
+
+	data, err := io.ReadAll(spec.Body)
+	require.NoError(t, err)
+	require.Equal(t, "payload", string(data))
+	require.Equal(t, "error", spec.Meta["level"])
+	require.Equal(t, "7", spec.Meta["original-size"])
+	require.False(t, spec.UpdatedAt.IsZero())
+
+	_, err = store.GetObject("test/missing")
+	require.ErrorIs(t, err, blobHook.ErrNotFound)
+}
+
+func TestBlobStores(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := blobHook.NewBlobStore("file://"+dir, nil)
+		require.NoError(t, err)
+
+		testStore(t, store)
+
+		require.FileExists(t, filepath.Join(dir, "test", "blob-1"))
+		require.FileExists(t, filepath.Join(dir, "test", "_touch"))
+	})
+
+	for _, sdk := range []string{"v1", "v2"} {
+		t.Run("s3 "+sdk, func(t *testing.T) {
+			bucket := newFakeBucket()
+			srv := newFakeS3(t, "logs", bucket)
+
+			store, err := blobHook.NewBlobStore("s3://logs/suplog?sdk="+sdk, &blobHook.StoreCredentials{
+				Account:  "minio",
+				Key:      "minio123",
+				Endpoint: srv.URL,
+			})
+			require.NoError(t, err)
+
+			testStore(t, store)
+			require.Equal(t, []string{"suplog/test/_touch", "suplog/test/blob-1"}, bucket.keys())
+		})
+	}
+
+	t.Run("gcs", func(t *testing.T) {
+		bucket := newFakeBucket()
+		srv := newFakeGCS(t, "logs", "access-token", bucket)
+
+		store, err := blobHook.NewBlobStore("gs://logs?endpoint="+srv.URL, &blobHook.StoreCredentials{
+			Key: "access-token",
+		})
+		require.NoError(t, err)
+
+		testStore(t, store)
+		require.Equal(t, []string{"test/_touch", "test/blob-1"}, bucket.keys())
+	})
+
+	t.Run("azure", func(t *testing.T) {
+		// well-known Azurite account key
+		const accountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+		bucket := newFakeBucket()
+		srv := newFakeAzure(t, "devstoreaccount1", accountKey, "logs", bucket)
+
+		store, err := blobHook.NewBlobStore("azblob://logs/nested/prefix", &blobHook.StoreCredentials{
+			Account:  "devstoreaccount1",
+			Key:      accountKey,
+			Endpoint: srv.URL + "/devstoreaccount1",
+		})
+		require.NoError(t, err)
+
+		testStore(t, store)
+		require.Equal(t, []string{"nested/prefix/test/_touch", "nested/prefix/test/blob-1"}, bucket.keys())
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		_, err := blobHook.NewBlobStore("ftp://host/dir", nil)
+		require.Error(t, err)
+		require.False(t, blobHook.IsBlobStoreURL("https://bucket.s3.amazonaws.com"))
+	})
+}
+
+func TestBlobHookStoreURL(t *testing.T) {
+	dir := t.TempDir()
+	log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+		Env:          "test",
+		BlobStoreURL: "file://" + dir,
+	})
+	defer hook.Close()
+
+	log.WithField("blob", []byte("request body")).Info("with blob")
+	require.NoError(t, hook.Flush(context.Background()))
+
+	ref := blobRef(t, out.String())
+	require.True(t, strings.HasPrefix(ref, "file://"+dir+"/test/"), ref)
+
+	data, err := os.ReadFile(strings.TrimPrefix(ref, "file://"))
+	require.NoError(t, err)
+	require.Equal(t, "request body", string(data))
+}
@@ -0,0 +1,395 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	blobHook "github.com/InjectiveLabs/suplog/hooks/blob"
+)
+
+// memStore keeps blobs in memory, failing the first failures uploads,
+// and blocking uploads until released if blocked.
+type memStore struct {
+	mux      sync.Mutex
+	objects  map[string][]byte
+	meta     map[string]map[string]string
+	attempts int
+	started  int
+	failures int
+	releaseC chan struct{}
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		objects: make(map[string][]byte),
+		meta:    make(map[string]map[string]string),
+	}
+}
+
+func (s *memStore) CheckAccess(prefix string) error {
+	return nil
+}
+
+func (s *memStore) PutObject(key string, r io.Reader, meta map[string]string) (*blobHook.BlobSpec, error) {
+	s.mux.Lock()
+	s.started++
+	releaseC := s.releaseC
+	s.mux.Unlock()
+
+	if releaseC != nil {
+		<-releaseC
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.attempts++
+	if s.failures != 0 {
+		if s.failures > 0 {
+			s.failures--
+		}
+
+		return nil, errors.New("service unavailable")
+	}
+
+	s.objects[key] = data
+	s.meta[key] = meta
+
+	return &blobHook.BlobSpec{Key: key, Size: int64(len(data))}, nil
+}
+
+func (s *memStore) GetObject(key string) (*blobHook.BlobSpec, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, blobHook.ErrNotFound
+	}
+
+	return &blobHook.BlobSpec{
+		Key:  key,
+		Body: io.NopCloser(strings.NewReader(string(data))),
+		Meta: s.meta[key],
+		Size: int64(len(data)),
+	}, nil
+}
+
+func (s *memStore) get(key string) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return string(s.objects[key])
+}
+
+func (s *memStore) count() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return len(s.objects)
+}
+
+// refKey strips the store URL from the blob reference.
+func refKey(ref string) string {
+	return ref[strings.Index(ref, "test/"):]
+}
+
+func TestBlobUploads(t *testing.T) {
+	t.Run("uploads in background", func(t *testing.T) {
+		store := newMemStore()
+		store.releaseC = make(chan struct{})
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:       "test",
+			BlobStore: store,
+		})
+		defer hook.Close()
+
+		log.WithField("blob", "payload").Info("not blocked")
+		key := refKey(blobRef(t, out.String()))
+
+		pending := hook.Pending()
+		require.Len(t, pending, 1)
+		require.Equal(t, key, pending[0].Key)
+		require.Equal(t, 7, pending[0].Size)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, hook.Flush(ctx), context.DeadlineExceeded)
+
+		close(store.releaseC)
+		require.NoError(t, hook.Flush(context.Background()))
+		require.Empty(t, hook.Pending())
+		require.Equal(t, "payload", store.get(key))
+	})
+
+	t.Run("retries with backoff", func(t *testing.T) {
+		store := newMemStore()
+		store.failures = 2
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:                "test",
+			BlobStore:          store,
+			UploadRetryBackoff: time.Millisecond,
+		})
+		defer hook.Close()
+
+		log.WithField("blob", "payload").Info("retried")
+		require.NoError(t, hook.Flush(context.Background()))
+
+		require.Equal(t, "payload", store.get(refKey(blobRef(t, out.String()))))
+		require.Equal(t, 3, store.attempts)
+		require.Empty(t, hook.Failed())
+	})
+
+	t.Run("fails after retries", func(t *testing.T) {
+		store := newMemStore()
+		store.failures = -1
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:                "test",
+			BlobStore:          store,
+			UploadRetries:      1,
+			UploadRetryBackoff: time.Millisecond,
+		})
+		defer hook.Close()
+
+		log.WithField("blob", "payload").Info("failed")
+		require.NoError(t, hook.Flush(context.Background()))
+
+		failed := hook.Failed()
+		require.Len(t, failed, 1)
+		require.Equal(t, refKey(blobRef(t, out.String())), failed[0].Key)
+		require.Equal(t, 2, failed[0].Attempts)
+		require.Equal(t, "service unavailable", failed[0].LastError)
+	})
+
+	t.Run("queue overflow", func(t *testing.T) {
+		store := newMemStore()
+		store.releaseC = make(chan struct{})
+
+		log, hook, _ := newTestLogger(t, &blobHook.HookOptions{
+			Env:             "test",
+			BlobStore:       store,
+			UploadWorkers:   1,
+			UploadQueueSize: 1,
+		})
+
+		// the first one is taken by the worker, the second one is queued
+		log.WithField("blob", "first").Info("first")
+		require.Eventually(t, func() bool {
+			log.WithField("blob", "next").Info("next")
+			return len(hook.Failed()) > 0
+		}, time.Second, time.Millisecond)
+		require.Equal(t, "upload queue is full", hook.Failed()[0].LastError)
+
+		close(store.releaseC)
+		require.NoError(t, hook.Close())
+		require.ErrorIs(t, hook.Close(), blobHook.ErrClosed)
+		require.Empty(t, hook.Pending())
+	})
+
+	t.Run("spool survives restart", func(t *testing.T) {
+		spoolDir := t.TempDir()
+
+		failing := newMemStore()
+		failing.failures = -1
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:                "test",
+			BlobStore:          failing,
+			SpoolDir:           spoolDir,
+			UploadRetryBackoff: time.Hour,
+		})
+
+		log.WithField("blob", "spooled payload").Info("spooled")
+		key := refKey(blobRef(t, out.String()))
+
+		require.Eventually(t, func() bool {
+			failing.mux.Lock()
+			defer failing.mux.Unlock()
+			return failing.attempts > 0
+		}, time.Second, time.Millisecond)
+		require.NoError(t, hook.Close())
+		require.Empty(t, hook.Failed())
+
+		store := newMemStore()
+		_, hook, _ = newTestLogger(t, &blobHook.HookOptions{
+			Env:       "test",
+			BlobStore: store,
+			SpoolDir:  spoolDir,
+		})
+		defer hook.Close()
+
+		require.NoError(t, hook.Flush(context.Background()))
+		require.Equal(t, "spooled payload", store.get(key))
+		require.Equal(t, 1, store.count())
+		require.Empty(t, hook.Pending())
+	})
+
+	t.Run("spool larger than queue", func(t *testing.T) {
+		spoolDir := t.TempDir()
+
+		failing := newMemStore()
+		failing.failures = -1
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:                "test",
+			BlobStore:          failing,
+			SpoolDir:           spoolDir,
+			UploadWorkers:      1,
+			UploadRetryBackoff: time.Hour,
+		})
+
+		for i := 0; i < 4; i++ {
+			log.WithField("blob", "spooled payload").Info("spooled")
+		}
+		require.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 4)
+		require.NoError(t, hook.Close())
+		require.Empty(t, hook.Failed())
+
+		store := newMemStore()
+		store.releaseC = make(chan struct{})
+
+		log, hook, out = newTestLogger(t, &blobHook.HookOptions{
+			Env:             "test",
+			BlobStore:       store,
+			SpoolDir:        spoolDir,
+			UploadWorkers:   1,
+			UploadQueueSize: 1,
+		})
+		release := sync.OnceFunc(func() { close(store.releaseC) })
+		defer hook.Close()
+		defer release()
+
+		// the worker is busy with a restored upload, the new one is queued
+		require.Eventually(t, func() bool {
+			store.mux.Lock()
+			defer store.mux.Unlock()
+			return store.started > 0
+		}, time.Second, time.Millisecond)
+		log.WithField("blob", "new payload").Info("new")
+		require.Empty(t, hook.Failed())
+
+		release()
+		require.NoError(t, hook.Flush(context.Background()))
+		require.Equal(t, "new payload", store.get(refKey(blobRef(t, out.String()))))
+		require.Equal(t, 5, store.count())
+		require.Empty(t, hook.Failed())
+	})
+
+	t.Run("failed uploads are spooled", func(t *testing.T) {
+		spoolDir := t.TempDir()
+
+		store := newMemStore()
+		store.failures = -1
+
+		log, hook, _ := newTestLogger(t, &blobHook.HookOptions{
+			Env:           "test",
+			BlobStore:     store,
+			SpoolDir:      spoolDir,
+			UploadRetries: -1,
+		})
+
+		log.WithField("blob", "lost payload").Info("failed")
+		require.NoError(t, hook.Flush(context.Background()))
+		require.NoError(t, hook.Close())
+
+		_, hook, _ = newTestLogger(t, &blobHook.HookOptions{
+			Env:       "test",
+			BlobStore: newMemStore(),
+			SpoolDir:  spoolDir,
+		})
+		defer hook.Close()
+
+		failed := hook.Failed()
+		require.Len(t, failed, 1)
+		require.Equal(t, 1, failed[0].Attempts)
+		require.Empty(t, hook.Pending())
+	})
+
+	t.Run("failed uploads are retried", func(t *testing.T) {
+		spoolDir := t.TempDir()
+
+		store := newMemStore()
+		store.failures = 3
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:              "test",
+			BlobStore:        store,
+			SpoolDir:         spoolDir,
+			UploadRetries:    -1,
+			MaxFailedUploads: 2,
+		})
+		defer hook.Close()
+
+		var keys []string
+		for i := 0; i < 3; i++ {
+			log.WithField("blob", "failed payload").Info("failed")
+			keys = append(keys, refKey(blobRef(t, out.String())))
+			require.NoError(t, hook.Flush(context.Background()))
+		}
+
+		// the oldest one is removed
+		failed := hook.Failed()
+		require.Len(t, failed, 2)
+		require.Equal(t, keys[1:], []string{failed[0].Key, failed[1].Key})
+
+		n, err := hook.RetryFailed()
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		require.NoError(t, hook.Flush(context.Background()))
+		require.Empty(t, hook.Failed())
+		require.Equal(t, 2, store.count())
+		require.Equal(t, "failed payload", store.get(keys[2]))
+	})
+
+	t.Run("corrupt spool entries are skipped", func(t *testing.T) {
+		spoolDir := t.TempDir()
+
+		failing := newMemStore()
+		failing.failures = -1
+
+		log, hook, out := newTestLogger(t, &blobHook.HookOptions{
+			Env:                "test",
+			BlobStore:          failing,
+			SpoolDir:           spoolDir,
+			UploadRetryBackoff: time.Hour,
+		})
+
+		log.WithField("blob", "spooled payload").Info("spooled")
+		key := refKey(blobRef(t, out.String()))
+		require.NoError(t, hook.Close())
+
+		pendingDir := filepath.Join(spoolDir, "pending")
+		require.NoError(t, os.WriteFile(filepath.Join(pendingDir, "corrupt.json"), []byte("{"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(pendingDir, "orphan.json"), []byte(`{"key":"test/orphan"}`), 0o600))
+
+		store := newMemStore()
+		_, hook, _ = newTestLogger(t, &blobHook.HookOptions{
+			Env:       "test",
+			BlobStore: store,
+			SpoolDir:  spoolDir,
+		})
+		defer hook.Close()
+
+		require.NoError(t, hook.Flush(context.Background()))
+		require.Equal(t, "spooled payload", store.get(key))
+		require.Equal(t, 1, store.count())
+	})
+}
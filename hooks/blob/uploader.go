@@ -0,0 +1,513 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Upload describes a pending or failed blob upload.
+type Upload struct {
+	Key       string            `json:"key"`
+	Size      int               `json:"size"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	Attempts  int               `json:"attempts"`
+	LastError string            `json:"last_error,omitempty"`
+	QueuedAt  time.Time         `json:"queued_at"`
+	// Encoded is set once the payload is compressed and encrypted, Meta is final then.
+	Encoded bool `json:"encoded,omitempty"`
+}
+
+type upload struct {
+	Upload
+	payload []byte
+}
+
+// ErrClosed is returned when closing the hook twice.
+var ErrClosed = errors.New("blob hook is closed")
+
+// uploader dispatches uploads to a pool of workers, retrying failed ones with backoff.
+type uploader struct {
+	opt    *HookOptions
+	store  BlobStore
+	logger RootLogger
+	spool  *spool
+
+	queueC chan *upload
+	closeC chan struct{}
+	wg     sync.WaitGroup
+
+	mux     sync.Mutex
+	pending map[string]*upload
+	// restored uploads are taken by workers when the queue is empty,
+	// so they don't count against the queue size, restoredC is closed when more are added
+	restored  []*upload
+	restoredC chan struct{}
+	failed    []Upload
+	idleC     chan struct{}
+	closed    bool
+}
+
+func newUploader(opt *HookOptions, store BlobStore, logger RootLogger) *uploader {
+	u := &uploader{
+		opt:       opt,
+		store:     store,
+		logger:    logger,
+		queueC:    make(chan *upload, opt.UploadQueueSize),
+		closeC:    make(chan struct{}),
+		pending:   make(map[string]*upload),
+		restoredC: make(chan struct{}),
+		idleC:     make(chan struct{}),
+	}
+
+	// nothing is pending yet
+	close(u.idleC)
+
+	if len(opt.SpoolDir) > 0 {
+		sp, err := newSpool(opt.SpoolDir, logger)
+		if err != nil {
+			logger.Errorf("failed to init blob spool, uploads won't survive restarts: %+v", err)
+		} else {
+			u.spool = sp
+			u.restore()
+		}
+	}
+
+	for i := 0; i < opt.UploadWorkers; i++ {
+		u.wg.Add(1)
+		go u.run()
+	}
+
+	return u
+}
+
+// restore queues uploads left in the spool by the previous run.
+func (u *uploader) restore() {
+	u.pruneFailed()
+
+	uploads, err := u.spool.list(spoolPending, true)
+	if err != nil {
+		u.logger.Errorf("failed to list spooled blob uploads: %+v", err)
+		return
+	}
+
+	u.mux.Lock()
+	u.addRestored(uploads)
+	u.mux.Unlock()
+}
+
+// addRestored adds uploads from the spool for workers. Must be called with mux locked.
+func (u *uploader) addRestored(uploads []*upload) {
+	if len(uploads) == 0 {
+		return
+	}
+
+	for _, up := range uploads {
+		u.addPending(up)
+	}
+
+	u.restored = append(u.restored, uploads...)
+	close(u.restoredC)
+	u.restoredC = make(chan struct{})
+}
+
+// nextRestored returns the next upload restored from the spool, nil if there are none left or closed,
+// along with the channel closed when more are added.
+func (u *uploader) nextRestored() (*upload, <-chan struct{}) {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	if u.closed || len(u.restored) == 0 {
+		return nil, u.restoredC
+	}
+
+	up := u.restored[0]
+	u.restored[0] = nil
+	u.restored = u.restored[1:]
+
+	return up, u.restoredC
+}
+
+// RetryFailed moves failed uploads from the spool back to pending, giving them all retries again.
+func (u *uploader) RetryFailed() (int, error) {
+	u.mux.Lock()
+	closed := u.closed
+	u.mux.Unlock()
+
+	if closed {
+		return 0, ErrClosed
+	} else if u.spool == nil {
+		return 0, nil
+	}
+
+	failed, err := u.spool.list(spoolFailed, true)
+	if err != nil {
+		return 0, err
+	}
+
+	uploads := make([]*upload, 0, len(failed))
+	for _, up := range failed {
+		up.Attempts = 0
+		up.LastError = ""
+
+		if err := u.spool.save(spoolPending, up, true); err != nil {
+			u.logger.Errorf("failed to spool blob %s: %+v", up.Key, err)
+			continue
+		}
+
+		u.spool.remove(spoolFailed, up)
+		uploads = append(uploads, up)
+	}
+
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	if u.closed {
+		// left in the spool for the next run
+		return len(uploads), nil
+	}
+
+	u.addRestored(uploads)
+
+	return len(uploads), nil
+}
+
+// pruneFailed removes the oldest failed uploads over the limit, and ones past retention from the spool.
+func (u *uploader) pruneFailed() {
+	u.spool.prune(spoolFailed, u.opt.MaxFailedUploads, time.Now().Add(-u.opt.BlobRetentionTTL))
+}
+
+func (u *uploader) addPending(up *upload) {
+	if len(u.pending) == 0 {
+		u.idleC = make(chan struct{})
+	}
+
+	u.pending[up.Key] = up
+}
+
+func (u *uploader) removePending(up *upload) {
+	delete(u.pending, up.Key)
+
+	if len(u.pending) == 0 {
+		close(u.idleC)
+	}
+}
+
+func (u *uploader) enqueue(key string, payload []byte, meta map[string]string) {
+	up := &upload{
+		Upload: Upload{
+			Key:      key,
+			Size:     len(payload),
+			Meta:     meta,
+			QueuedAt: time.Now().UTC(),
+		},
+		payload: payload,
+	}
+
+	if u.spool != nil && u.opt.BlobKMS != nil {
+		// the spool only gets encrypted payloads
+		if body, meta, err := u.encode(up); err != nil {
+			u.logger.Warningf("failed to encrypt blob %s, it's not spooled: %+v", key, err)
+		} else {
+			up.payload, up.Meta, up.Encoded = body, meta, true
+		}
+	}
+
+	if u.spooled(up) {
+		if err := u.spool.save(spoolPending, up, true); err != nil {
+			u.logger.Errorf("failed to spool blob %s: %+v", key, err)
+		}
+	}
+
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	if u.closed {
+		up.LastError = ErrClosed.Error()
+		u.fail(up)
+		return
+	}
+
+	// added before queueing, so workers can't finish the upload before it's pending
+	u.addPending(up)
+
+	select {
+	case u.queueC <- up:
+	default:
+		up.LastError = "upload queue is full"
+		u.fail(up)
+	}
+}
+
+func (u *uploader) run() {
+	defer u.wg.Done()
+
+	for {
+		select {
+		case up := <-u.queueC:
+			u.process(up)
+			continue
+		default:
+		}
+
+		up, restoredC := u.nextRestored()
+		if up != nil {
+			u.process(up)
+			continue
+		}
+
+		select {
+		case up := <-u.queueC:
+			u.process(up)
+		case <-restoredC:
+		case <-u.closeC:
+			// give everything queued one more attempt
+			for {
+				select {
+				case up := <-u.queueC:
+					u.process(up)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// spooled reports whether the upload is kept in the spool, payloads are spooled encrypted if there is a KMS.
+func (u *uploader) spooled(up *upload) bool {
+	return u.spool != nil && (u.opt.BlobKMS == nil || up.Encoded)
+}
+
+func (u *uploader) process(up *upload) {
+	var (
+		body    []byte
+		meta    map[string]string
+		encoded = up.Encoded
+	)
+
+	if encoded {
+		body, meta = up.payload, up.Meta
+	}
+
+	for attempts := up.Attempts + 1; ; attempts++ {
+		var err error
+		if !encoded {
+			// encryption could fail with a remote KMS, so it's retried as well
+			body, meta, err = u.encode(up)
+			encoded = err == nil
+		}
+
+		if err == nil {
+			if _, err = u.store.PutObject(up.Key, bytes.NewReader(body), meta); err == nil {
+				u.done(up)
+				return
+			}
+		}
+
+		u.mux.Lock()
+		up.Attempts = attempts
+		up.LastError = err.Error()
+
+		if u.opt.UploadRetries < 0 || attempts > u.opt.UploadRetries {
+			u.fail(up)
+			u.mux.Unlock()
+			return
+		}
+		u.mux.Unlock()
+
+		timer := time.NewTimer(u.backoff(attempts))
+		select {
+		case <-timer.C:
+		case <-u.closeC:
+			timer.Stop()
+			u.giveUp(up)
+			return
+		}
+	}
+}
+
+// encode returns the body and metadata to upload, leaving the upload as is,
+// so the spool always keeps the original payload. Blobs are compressed before encryption.
+func (u *uploader) encode(up *upload) ([]byte, map[string]string, error) {
+	meta := make(map[string]string, len(up.Meta)+5)
+	for k, v := range up.Meta {
+		meta[k] = v
+	}
+
+	body, err := encodeBlob(up.payload, meta, u.opt.BlobCompression, u.opt.BlobCompressMinSize)
+	if err != nil {
+		u.logger.Warningf("failed to compress blob %s, uploading it as is: %+v", up.Key, err)
+		delete(meta, MetaContentEncoding)
+		body = up.payload
+	}
+
+	if u.opt.BlobKMS != nil {
+		if body, err = encryptBlob(body, meta, u.opt.BlobKMS); err != nil {
+			return nil, nil, fmt.Errorf("failed to encrypt blob: %w", err)
+		}
+	}
+
+	return body, meta, nil
+}
+
+// backoff returns the delay before the next attempt, growing exponentially with jitter.
+func (u *uploader) backoff(attempts int) time.Duration {
+	interval := u.opt.UploadRetryBackoff
+	for i := 1; i < attempts && interval < u.opt.UploadMaxBackoff; i++ {
+		interval *= 2
+	}
+
+	if interval > u.opt.UploadMaxBackoff {
+		interval = u.opt.UploadMaxBackoff
+	}
+
+	return interval/2 + time.Duration(rand.Int63n(int64(interval/2)+1))
+}
+
+func (u *uploader) done(up *upload) {
+	if u.spool != nil {
+		u.spool.remove(spoolPending, up)
+	}
+
+	u.mux.Lock()
+	up.Attempts++
+	up.LastError = ""
+	u.removePending(up)
+	u.mux.Unlock()
+}
+
+// fail gives up on the upload, keeping it for inspection. Must be called with mux locked.
+func (u *uploader) fail(up *upload) {
+	u.logger.Errorf("failed to upload blob %s after %d attempts: %s", up.Key, up.Attempts, up.LastError)
+
+	if u.spooled(up) {
+		if err := u.spool.save(spoolFailed, up, true); err != nil {
+			u.logger.Errorf("failed to spool failed blob %s: %+v", up.Key, err)
+		} else {
+			u.spool.remove(spoolPending, up)
+		}
+
+		u.pruneFailed()
+	} else {
+		if len(u.failed) == u.opt.MaxFailedUploads {
+			u.failed = u.failed[1:]
+		}
+
+		u.failed = append(u.failed, up.Upload)
+	}
+
+	if _, ok := u.pending[up.Key]; ok {
+		u.removePending(up)
+	}
+}
+
+// giveUp stops retrying the upload on close, it's left in the spool for the next run.
+func (u *uploader) giveUp(up *upload) {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+
+	if !u.spooled(up) {
+		u.fail(up)
+		return
+	}
+
+	if err := u.spool.save(spoolPending, up, false); err != nil {
+		u.logger.Errorf("failed to spool blob %s: %+v", up.Key, err)
+	}
+
+	u.logger.Warningf("blob upload %s is left in spool after %d attempts: %s", up.Key, up.Attempts, up.LastError)
+	u.removePending(up)
+}
+
+// Flush blocks until there are no pending uploads, or the context is done.
+func (u *uploader) Flush(ctx context.Context) error {
+	u.mux.Lock()
+	idleC := u.idleC
+	u.mux.Unlock()
+
+	select {
+	case <-idleC:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting uploads, and waits until queued uploads are done. Each of them
+// gets one more attempt, failed ones are left in the spool for the next run.
+func (u *uploader) Close() error {
+	u.mux.Lock()
+	if u.closed {
+		u.mux.Unlock()
+		return ErrClosed
+	}
+
+	u.closed = true
+	close(u.closeC)
+	u.mux.Unlock()
+
+	u.wg.Wait()
+
+	// uploads restored from the spool, but not taken by workers yet, stay there
+	u.mux.Lock()
+	u.restored = nil
+	if len(u.pending) > 0 {
+		u.pending = make(map[string]*upload)
+		close(u.idleC)
+	}
+	u.mux.Unlock()
+
+	return nil
+}
+
+func (u *uploader) Pending() []Upload {
+	u.mux.Lock()
+	uploads := make([]Upload, 0, len(u.pending))
+	for _, up := range u.pending {
+		uploads = append(uploads, up.Upload)
+	}
+	u.mux.Unlock()
+
+	sortUploads(uploads)
+	return uploads
+}
+
+func (u *uploader) Failed() []Upload {
+	u.mux.Lock()
+	uploads := make([]Upload, len(u.failed))
+	copy(uploads, u.failed)
+	u.mux.Unlock()
+
+	if u.spool == nil {
+		return uploads
+	}
+
+	// uploads which couldn't be encrypted are kept in memory
+	failed, err := u.spool.list(spoolFailed, false)
+	if err != nil {
+		u.logger.Errorf("failed to list failed blob uploads: %+v", err)
+	}
+
+	for _, up := range failed {
+		uploads = append(uploads, up.Upload)
+	}
+
+	sortUploads(uploads)
+	return uploads
+}
+
+func sortUploads(uploads []Upload) {
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].QueuedAt.Equal(uploads[j].QueuedAt) {
+			return uploads[i].Key < uploads[j].Key
+		}
+
+		return uploads[i].QueuedAt.Before(uploads[j].QueuedAt)
+	})
+}
@@ -2,7 +2,6 @@ package bugsnag
 
 import (
 	"fmt"
-	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -39,7 +38,7 @@ func newErrorWithStackFrames(err error, stackFrames []runtime.Frame) ErrorWithSt
 
 	for i, frame := range stackFrames {
 		e.frames[i] = errors.StackFrame{
-			File:           limitPath(frame.File, 3),
+			File:           stackcache.LimitPath(frame.File, 3),
 			LineNumber:     frame.Line,
 			Name:           frame.Function,
 			Package:        stackcache.GetPackageName(frame.Function),
@@ -58,19 +57,6 @@ func (e *errorWithStack) StackFrames() []errors.StackFrame {
 	return e.frames
 }
 
-func limitPath(path string, n int) string {
-	if n <= 0 {
-		return path
-	}
-
-	pathParts := strings.Split(path, string(filepath.Separator))
-	if len(pathParts) > n {
-		pathParts = pathParts[len(pathParts)-n:]
-	}
-
-	return filepath.Join(pathParts...)
-}
-
 type pkgErrorsStackTracer interface {
 	StackTrace() pkgerrors.StackTrace
 }
@@ -118,7 +104,7 @@ func newErrorWithPkgErrorsStackTrace(err error, stackTrace pkgerrors.StackTrace)
 		fileName = parts[1][:lineNumIdx]
 
 		e.frames[i] = errors.StackFrame{
-			File:           limitPath(fileName, 3),
+			File:           stackcache.LimitPath(fileName, 3),
 			LineNumber:     fileLineNumber,
 			Name:           fnName,
 			Package:        stackcache.GetPackageName(fnName),
@@ -3,7 +3,6 @@ package debug
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -80,7 +79,10 @@ func (h *hook) Levels() []logrus.Level {
 }
 
 func (h *hook) Fire(e *logrus.Entry) error {
-	caller := h.stack.GetCaller()
+	caller, ok := stackcache.CallerFromContext(e.Context)
+	if !ok {
+		caller = h.stack.GetCaller()
+	}
 
 	if len(caller.Function) > 0 {
 		parts := strings.Split(caller.Function, "/")
@@ -88,7 +90,7 @@ func (h *hook) Fire(e *logrus.Entry) error {
 		e.Data["fn"] = nameParts[len(nameParts)-1]
 	}
 
-	callerFile := limitPath(caller.File, h.opt.PathSegmentsLimit)
+	callerFile := stackcache.LimitPath(caller.File, h.opt.PathSegmentsLimit)
 	e.Data["src"] = fmt.Sprintf("%s:%d", callerFile, caller.Line)
 
 	if len(h.opt.AppVersion) > 0 {
@@ -98,15 +100,3 @@ func (h *hook) Fire(e *logrus.Entry) error {
 	return nil
 }
 
-func limitPath(path string, n int) string {
-	if n <= 0 {
-		return path
-	}
-
-	pathParts := strings.Split(path, string(filepath.Separator))
-	if len(pathParts) > n {
-		pathParts = pathParts[len(pathParts)-n:]
-	}
-
-	return filepath.Join(pathParts...)
-}
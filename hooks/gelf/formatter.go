@@ -0,0 +1,134 @@
+package gelf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/InjectiveLabs/suplog/hooks/syslog"
+)
+
+// FormatterOptions allows to set additional Formatter options.
+type FormatterOptions struct {
+	// Host is reported in host field, defaults to the hostname.
+	Host string
+	// Facility is reported as _facility additional field, if set.
+	Facility string
+}
+
+func checkFormatterOptions(opt *FormatterOptions) *FormatterOptions {
+	if opt == nil {
+		opt = &FormatterOptions{}
+	}
+
+	if len(opt.Host) == 0 {
+		opt.Host, _ = os.Hostname()
+		if len(opt.Host) == 0 {
+			opt.Host = "localhost"
+		}
+	}
+
+	if len(opt.Facility) == 0 {
+		opt.Facility = os.Getenv("LOG_GELF_FACILITY")
+	}
+
+	return opt
+}
+
+// NewFormatter returns a formatter of GELF 1.1 messages, see
+// https://go2docs.graylog.org/current/getting_in_log_data/gelf.html
+// The first line of the message is short_message, the whole message is full_message
+// if it has many lines. Fields become additional fields prefixed with underscore.
+func NewFormatter(opt *FormatterOptions) logrus.Formatter {
+	return &formatter{
+		opt: checkFormatterOptions(opt),
+	}
+}
+
+type formatter struct {
+	opt *FormatterOptions
+}
+
+var invalidFieldChars = regexp.MustCompile(`[^\w.\-]`)
+
+func (f *formatter) Format(e *logrus.Entry) ([]byte, error) {
+	msg := make(map[string]interface{}, len(e.Data)+7)
+
+	short := strings.TrimRight(e.Message, "\r\n")
+	if idx := strings.IndexByte(short, '\n'); idx >= 0 {
+		msg["full_message"] = e.Message
+		short = strings.TrimRight(short[:idx], "\r")
+	}
+
+	if len(short) == 0 {
+		// short_message must not be empty
+		short = "-"
+	}
+
+	msg["version"] = "1.1"
+	msg["host"] = f.opt.Host
+	msg["short_message"] = short
+	msg["timestamp"] = float64(e.Time.UnixNano()/int64(time.Millisecond)) / 1000
+	msg["level"] = syslog.Severity(e.Level)
+
+	if len(f.opt.Facility) > 0 {
+		msg["_facility"] = f.opt.Facility
+	}
+
+	for k, v := range e.Data {
+		key := "_" + invalidFieldChars.ReplaceAllString(k, "_")
+		if key == "_id" {
+			// reserved by GELF
+			key = "__id"
+		}
+
+		msg[key] = fieldValue(v)
+	}
+
+	b := e.Buffer
+	if b == nil {
+		b = new(bytes.Buffer)
+	}
+
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(msg); err != nil {
+		return nil, fmt.Errorf("failed to marshal GELF message, %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// fieldValue converts values into strings or numbers, the only types allowed by GELF.
+func fieldValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case bool:
+		return fmt.Sprint(v)
+	case error:
+		return v.Error()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	}
+
+	if out, err := json.Marshal(v); err == nil {
+		return string(out)
+	}
+
+	return fmt.Sprintf("%+v", v)
+}
@@ -0,0 +1,250 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Compression of UDP messages, TCP messages are never compressed.
+type Compression int
+
+const (
+	// CompressGzip compresses UDP messages with gzip.
+	CompressGzip Compression = iota
+	// CompressNone sends UDP messages as is.
+	CompressNone
+)
+
+// HookOptions allows to set additional Hook options.
+type HookOptions struct {
+	// Levels enables this hook for all listed levels, defaults to all levels.
+	Levels []logrus.Level
+	// Address is the Graylog input, e.g. "udp://graylog:12201" or "tcp://graylog:12201".
+	Address string
+	// Formatter options, such as host and facility.
+	Formatter *FormatterOptions
+	// Compression of UDP messages, defaults to gzip.
+	Compression Compression
+	// ChunkSize is the maximum UDP datagram size, bigger messages are chunked. Defaults to 1420.
+	ChunkSize int
+	// Timeout limits dial and write time, defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+const (
+	defaultChunkSize = 1420
+	minChunkSize     = 128
+	maxChunks        = 128
+	chunkHeaderSize  = 12
+)
+
+func checkHookOptions(opt *HookOptions) *HookOptions {
+	if opt == nil {
+		opt = &HookOptions{}
+	}
+
+	if len(opt.Levels) == 0 {
+		opt.Levels = logrus.AllLevels
+	}
+
+	if len(opt.Address) == 0 {
+		opt.Address = os.Getenv("LOG_GELF_ADDRESS")
+	}
+
+	if opt.ChunkSize <= 0 {
+		opt.ChunkSize = defaultChunkSize
+	} else if opt.ChunkSize < minChunkSize {
+		opt.ChunkSize = minChunkSize
+	}
+
+	if opt.Timeout <= 0 {
+		opt.Timeout = 5 * time.Second
+	}
+
+	return opt
+}
+
+type RootLogger interface {
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+	Printf(format string, args ...interface{})
+}
+
+// ErrMessageTooLarge is returned when UDP message needs more than 128 chunks.
+var ErrMessageTooLarge = errors.New("GELF message is too large")
+
+// NewHook initializes a new hook that ships GELF messages over UDP or TCP. Connection is
+// established on the first entry, and re-established after write failures. Provide a root
+// logger to print any errors occuring during sending, it must not use this hook itself.
+func NewHook(logger RootLogger, opt *HookOptions) (*Hook, error) {
+	opt = checkHookOptions(opt)
+
+	u, err := url.Parse(opt.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GELF address: %w", err)
+	} else if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("GELF address must be udp:// or tcp://, got %q", opt.Address)
+	}
+
+	return &Hook{
+		opt:       opt,
+		logger:    logger,
+		network:   u.Scheme,
+		addr:      u.Host,
+		formatter: NewFormatter(opt.Formatter),
+	}, nil
+}
+
+// Hook ships entries as GELF messages.
+type Hook struct {
+	opt       *HookOptions
+	logger    RootLogger
+	network   string
+	addr      string
+	formatter logrus.Formatter
+
+	mux  sync.Mutex
+	conn net.Conn
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return h.opt.Levels
+}
+
+func (h *Hook) Fire(e *logrus.Entry) error {
+	// entry buffer belongs to the logger formatter
+	entry := *e
+	entry.Buffer = nil
+
+	msg, err := h.formatter.Format(&entry)
+	if err != nil {
+		h.logger.Errorf("failed to format GELF message: %v", err)
+		return nil
+	}
+
+	msg = bytes.TrimSuffix(msg, []byte("\n"))
+
+	if err := h.send(msg); err != nil {
+		h.logger.Errorf("failed to send GELF message: %v", err)
+	}
+
+	return nil
+}
+
+func (h *Hook) send(msg []byte) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if h.network == "tcp" {
+		// null byte terminates messages
+		msg = append(msg, 0)
+
+		// retry once, since the connection could be closed by the server
+		err := h.write(msg)
+		if err != nil {
+			err = h.write(msg)
+		}
+
+		return err
+	}
+
+	if h.opt.Compression == CompressGzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(msg)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		msg = buf.Bytes()
+	}
+
+	if len(msg) <= h.opt.ChunkSize {
+		return h.write(msg)
+	}
+
+	return h.writeChunks(msg)
+}
+
+// writeChunks splits UDP message into chunks with GELF chunk headers:
+// magic bytes 0x1e 0x0f, 8 bytes of message ID, sequence number and count.
+func (h *Hook) writeChunks(msg []byte) error {
+	dataSize := h.opt.ChunkSize - chunkHeaderSize
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > maxChunks {
+		return ErrMessageTooLarge
+	}
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 0, h.opt.ChunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = append(chunk, id[:]...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, msg[i*dataSize:end]...)
+
+		if err := h.write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write sends the data, dialing if needed. Connection is dropped on failures.
+func (h *Hook) write(data []byte) error {
+	if h.conn == nil {
+		conn, err := net.DialTimeout(h.network, h.addr, h.opt.Timeout)
+		if err != nil {
+			return err
+		}
+
+		h.conn = conn
+	}
+
+	_ = h.conn.SetWriteDeadline(time.Now().Add(h.opt.Timeout))
+
+	if _, err := h.conn.Write(data); err != nil {
+		_ = h.conn.Close()
+		h.conn = nil
+
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the connection, the next entry will open a new one.
+func (h *Hook) Close() error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if h.conn == nil {
+		return nil
+	}
+
+	err := h.conn.Close()
+	h.conn = nil
+
+	return err
+}
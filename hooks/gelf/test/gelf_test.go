@@ -0,0 +1,180 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	gelfHook "github.com/InjectiveLabs/suplog/hooks/gelf"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+func TestGELFFormatter(t *testing.T) {
+	var recorder strings.Builder
+	log := suplog.NewLogger(&recorder, gelfHook.NewFormatter(&gelfHook.FormatterOptions{
+		Host:     "test-host",
+		Facility: "api",
+	}))
+
+	log.WithFields(suplog.Fields{
+		"user name": "alice",
+		"count":     3,
+		"id":        "x1",
+	}).WithError(errors.New("fail")).Error("first line\nsecond line")
+
+	var msg map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(recorder.String()), &msg))
+
+	require.Equal(t, "1.1", msg["version"])
+	require.Equal(t, "test-host", msg["host"])
+	require.Equal(t, "first line", msg["short_message"])
+	require.Equal(t, "first line\nsecond line", msg["full_message"])
+	require.EqualValues(t, 3, msg["level"])
+	require.NotZero(t, msg["timestamp"])
+	require.Equal(t, "api", msg["_facility"])
+	require.Equal(t, "alice", msg["_user_name"])
+	require.EqualValues(t, 3, msg["_count"])
+	require.Equal(t, "x1", msg["__id"])
+	require.Equal(t, "fail", msg["_error"])
+}
+
+// readUDPMessage reads datagrams until a whole message is received.
+func readUDPMessage(t *testing.T, conn net.PacketConn) (map[string]interface{}, int) {
+	buf := make([]byte, 65536)
+	chunks := make(map[byte][]byte)
+	datagrams := 0
+
+	for {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		datagrams++
+
+		data := append([]byte(nil), buf[:n]...)
+
+		var payload []byte
+		if data[0] == 0x1e && data[1] == 0x0f {
+			chunks[data[10]] = data[12:]
+			count := int(data[11])
+			if len(chunks) < count {
+				continue
+			}
+
+			for i := 0; i < count; i++ {
+				payload = append(payload, chunks[byte(i)]...)
+			}
+		} else {
+			payload = data
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		require.NoError(t, err)
+		raw, err := io.ReadAll(zr)
+		require.NoError(t, err)
+
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(raw, &msg))
+
+		return msg, datagrams
+	}
+}
+
+func TestGELFHookUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	hook, err := gelfHook.NewHook(suplog.DefaultLogger, &gelfHook.HookOptions{
+		Address:   "udp://" + conn.LocalAddr().String(),
+		ChunkSize: 256,
+	})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	log := suplog.NewLogger(io.Discard, nil, hook)
+
+	log.Info("small message")
+	msg, datagrams := readUDPMessage(t, conn)
+	require.Equal(t, "small message", msg["short_message"])
+	require.Equal(t, 1, datagrams)
+
+	// even compressed, the payload needs many chunks
+	var big strings.Builder
+	for i := 0; big.Len() < 4000; i++ {
+		big.WriteString(time.Now().Add(time.Duration(i) * time.Nanosecond).Format(time.RFC3339Nano))
+	}
+
+	log.WithField("payload", big.String()).Warning("big message")
+	msg, datagrams = readUDPMessage(t, conn)
+	require.Equal(t, "big message", msg["short_message"])
+	require.Equal(t, big.String(), msg["_payload"])
+	require.Greater(t, datagrams, 1)
+}
+
+func TestGELFHookTCP(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				r := bufio.NewReader(conn)
+				for {
+					msg, err := r.ReadString(0)
+					if err != nil {
+						return
+					}
+
+					messages <- strings.TrimSuffix(msg, "\x00")
+				}
+			}()
+		}
+	}()
+
+	hook, err := gelfHook.NewHook(suplog.DefaultLogger, &gelfHook.HookOptions{
+		Address: "tcp://" + lis.Addr().String(),
+	})
+	require.NoError(t, err)
+	defer hook.Close()
+
+	log := suplog.NewLogger(io.Discard, nil, hook)
+	log.Info("first")
+	log.Warning("second")
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case raw := <-messages:
+			var msg map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(raw), &msg))
+			require.Equal(t, expected, msg["short_message"])
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
+
+func TestGELFHookInvalidAddress(t *testing.T) {
+	_, err := gelfHook.NewHook(suplog.DefaultLogger, &gelfHook.HookOptions{
+		Address: "http://graylog:12201",
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package journald
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// sendFile writes the data into a sealed memfd, or an unlinked temp file if memfd
+// is not available, and sends its descriptor with an empty datagram.
+func (h *hook) sendFile(data []byte) error {
+	file, err := newDataFile(data)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rawConn, err := h.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// WriteMsgUnix refuses connected datagram sockets, so sendmsg is used directly
+	var sendErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		sendErr = unix.Sendmsg(int(fd), nil, unix.UnixRights(int(file.Fd())), nil, 0)
+		return sendErr != unix.EAGAIN
+	})
+
+	if err == nil {
+		err = sendErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to send entry descriptor: %w", err)
+	}
+
+	return nil
+}
+
+func newDataFile(data []byte) (*os.File, error) {
+	fd, err := unix.MemfdCreate("journal-entry", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err == nil {
+		file := os.NewFile(uintptr(fd), "journal-entry")
+
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		// journald accepts memfds only if they are sealed
+		_, err = unix.FcntlInt(file.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		return file, nil
+	}
+
+	return newTempFile(data)
+}
+
+// newTempFile creates an unlinked temp file, preferably in memory.
+func newTempFile(data []byte) (*os.File, error) {
+	dir := "/dev/shm"
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		dir = os.TempDir()
+	}
+
+	file, err := os.CreateTemp(dir, "journal-entry-")
+	if err != nil {
+		return nil, err
+	}
+
+	_ = os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package journald
+
+import "errors"
+
+// sendFile is not supported, since journald runs only on Linux.
+func (h *hook) sendFile(data []byte) error {
+	return errors.New("entry is too large for a datagram")
+}
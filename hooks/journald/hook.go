@@ -0,0 +1,282 @@
+package journald
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/InjectiveLabs/suplog/hooks/syslog"
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+// HookOptions allows to set additional Hook options.
+type HookOptions struct {
+	// Levels enables this hook for all listed levels, defaults to all levels.
+	Levels []logrus.Level
+	// SocketPath is the journald native socket, defaults to "/run/systemd/journal/socket".
+	SocketPath string
+	// Identifier is reported as SYSLOG_IDENTIFIER, defaults to the program name.
+	Identifier string
+	// ExtraFields are added to each entry, names are converted same as entry fields.
+	ExtraFields map[string]string
+}
+
+// DefaultSocketPath is the native protocol socket of journald.
+const DefaultSocketPath = "/run/systemd/journal/socket"
+
+func checkHookOptions(opt *HookOptions) *HookOptions {
+	if opt == nil {
+		opt = &HookOptions{}
+	}
+
+	if len(opt.Levels) == 0 {
+		opt.Levels = logrus.AllLevels
+	}
+
+	if len(opt.SocketPath) == 0 {
+		opt.SocketPath = os.Getenv("LOG_JOURNALD_SOCKET")
+		if len(opt.SocketPath) == 0 {
+			opt.SocketPath = DefaultSocketPath
+		}
+	}
+
+	if len(opt.Identifier) == 0 {
+		opt.Identifier = filepath.Base(os.Args[0])
+	}
+
+	return opt
+}
+
+type RootLogger interface {
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+	Printf(format string, args ...interface{})
+}
+
+// Available reports whether the journald socket exists, e.g. to enable the hook
+// only when running as a systemd unit.
+func Available(socketPath string) bool {
+	if len(socketPath) == 0 {
+		socketPath = DefaultSocketPath
+	}
+
+	info, err := os.Stat(socketPath)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// NewHook initializes a new hook that sends entries to journald using its native protocol,
+// see https://systemd.io/JOURNAL_NATIVE_PROTOCOL/ Fields become uppercase journal fields,
+// debug hook fields "src" and "fn" become CODE_FILE, CODE_LINE and CODE_FUNC.
+// Provide a root logger to print any errors occuring during sending.
+func NewHook(logger RootLogger, opt *HookOptions) logrus.Hook {
+	opt = checkHookOptions(opt)
+
+	extra := make(map[string]string, len(opt.ExtraFields))
+	for k, v := range opt.ExtraFields {
+		extra[FieldName(k)] = v
+	}
+
+	return &hook{
+		opt:    opt,
+		logger: logger,
+		extra:  extra,
+	}
+}
+
+type hook struct {
+	opt    *HookOptions
+	logger RootLogger
+	extra  map[string]string
+
+	mux  sync.Mutex
+	conn *net.UnixConn
+}
+
+func (h *hook) Levels() []logrus.Level {
+	return h.opt.Levels
+}
+
+// reserved fields are set by the hook, entry fields with same names get "FIELD_" prefix.
+var reserved = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+}
+
+// FieldName converts a field name into a valid journal field name: uppercase
+// letters, digits and underscores, not starting with an underscore or a digit.
+func FieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		default:
+			return '_'
+		}
+	}, name)
+
+	// leading underscores are reserved for trusted fields
+	name = strings.TrimLeft(name, "_")
+
+	if len(name) == 0 || (name[0] >= '0' && name[0] <= '9') {
+		name = "FIELD_" + name
+	}
+
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return name
+}
+
+func (h *hook) Fire(e *logrus.Entry) error {
+	var b bytes.Buffer
+
+	writeField(&b, "MESSAGE", e.Message)
+	writeField(&b, "PRIORITY", strconv.Itoa(syslog.Severity(e.Level)))
+	writeField(&b, "SYSLOG_IDENTIFIER", h.opt.Identifier)
+	writeCodeFields(&b, e)
+
+	for k, v := range h.extra {
+		writeField(&b, k, v)
+	}
+
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		switch k {
+		case "src", "fn":
+			// reported as CODE_* fields
+			continue
+		}
+
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		name := FieldName(k)
+		if reserved[name] {
+			name = "FIELD_" + name
+		}
+
+		writeField(&b, name, fieldValue(e.Data[k]))
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if err := h.send(b.Bytes()); err != nil {
+		h.logger.Errorf("failed to send journald entry: %v", err)
+	}
+
+	return nil
+}
+
+// writeCodeFields uses the debug hook fields, then the caller from context or reported by logger.
+func writeCodeFields(b *bytes.Buffer, e *logrus.Entry) {
+	if src, ok := e.Data["src"].(string); ok {
+		file := src
+		if idx := strings.LastIndexByte(src, ':'); idx > 0 {
+			if _, err := strconv.Atoi(src[idx+1:]); err == nil {
+				file = src[:idx]
+				writeField(b, "CODE_LINE", src[idx+1:])
+			}
+		}
+
+		writeField(b, "CODE_FILE", file)
+
+		if fn, ok := e.Data["fn"].(string); ok {
+			writeField(b, "CODE_FUNC", fn)
+		}
+
+		return
+	}
+
+	caller, ok := stackcache.CallerFromContext(e.Context)
+	if !ok && e.HasCaller() {
+		caller, ok = *e.Caller, true
+	}
+
+	if ok {
+		writeField(b, "CODE_FILE", caller.File)
+		writeField(b, "CODE_LINE", strconv.Itoa(caller.Line))
+		writeField(b, "CODE_FUNC", caller.Function)
+	}
+}
+
+// writeField writes "NAME=value\n", or binary-safe form for multi-line values:
+// "NAME\n", little-endian 64-bit length, value and "\n".
+func writeField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+
+	b.WriteByte('\n')
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+func fieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (h *hook) send(data []byte) error {
+	if h.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{
+			Name: h.opt.SocketPath,
+			Net:  "unixgram",
+		})
+		if err != nil {
+			return err
+		}
+
+		h.conn = conn
+	}
+
+	_, err := h.conn.Write(data)
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		// too large for a datagram, pass it as a file descriptor
+		return h.sendFile(data)
+	}
+
+	_ = h.conn.Close()
+	h.conn = nil
+
+	return err
+}
@@ -0,0 +1,155 @@
+//go:build linux
+
+package journald
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	debugHook "github.com/InjectiveLabs/suplog/hooks/debug"
+	journaldHook "github.com/InjectiveLabs/suplog/hooks/journald"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+// fakeJournal stands in for journald, reading entries from the native socket.
+type fakeJournal struct {
+	conn *net.UnixConn
+	path string
+}
+
+func newFakeJournal(t *testing.T) *fakeJournal {
+	path := filepath.Join(t.TempDir(), "socket")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return &fakeJournal{
+		conn: conn,
+		path: path,
+	}
+}
+
+func (j *fakeJournal) read(t *testing.T) map[string]string {
+	buf := make([]byte, 1<<20)
+	oob := make([]byte, 1024)
+
+	require.NoError(t, j.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, oobn, _, _, err := j.conn.ReadMsgUnix(buf, oob)
+	require.NoError(t, err)
+
+	data := buf[:n]
+	if n == 0 {
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+
+		fds, err := syscall.ParseUnixRights(&msgs[0])
+		require.NoError(t, err)
+		require.Len(t, fds, 1)
+
+		file := os.NewFile(uintptr(fds[0]), "entry")
+		defer file.Close()
+
+		_, err = file.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+
+		data, err = io.ReadAll(file)
+		require.NoError(t, err)
+	}
+
+	return parseEntry(t, data)
+}
+
+func parseEntry(t *testing.T, data []byte) map[string]string {
+	fields := make(map[string]string)
+
+	for len(data) > 0 {
+		idx := bytes.IndexAny(data, "=\n")
+		require.True(t, idx > 0, "invalid entry: %q", data)
+
+		name := string(data[:idx])
+		if data[idx] == '=' {
+			end := bytes.IndexByte(data, '\n')
+			fields[name] = string(data[idx+1 : end])
+			data = data[end+1:]
+			continue
+		}
+
+		size := binary.LittleEndian.Uint64(data[idx+1 : idx+9])
+		fields[name] = string(data[idx+9 : idx+9+int(size)])
+		require.Equal(t, byte('\n'), data[idx+9+int(size)])
+		data = data[idx+10+int(size):]
+	}
+
+	return fields
+}
+
+func TestJournaldHook(t *testing.T) {
+	journal := newFakeJournal(t)
+	require.True(t, journaldHook.Available(journal.path))
+
+	log := suplog.NewLogger(io.Discard, nil, debugHook.NewHook(suplog.DefaultLogger, &debugHook.HookOptions{
+		Levels: logrus.AllLevels,
+	}), journaldHook.NewHook(suplog.DefaultLogger, &journaldHook.HookOptions{
+		SocketPath:  journal.path,
+		Identifier:  "app",
+		ExtraFields: map[string]string{"unit-version": "1"},
+	}))
+
+	t.Run("fields", func(t *testing.T) {
+		log.WithFields(suplog.Fields{
+			"user.name": "alice",
+			"_trusted":  "no",
+			"priority":  "clash",
+			"1st":       "digit",
+		}).WithError(errors.New("fail")).Error("first line\nsecond line")
+
+		entry := journal.read(t)
+		require.Equal(t, "first line\nsecond line", entry["MESSAGE"])
+		require.Equal(t, "3", entry["PRIORITY"])
+		require.Equal(t, "app", entry["SYSLOG_IDENTIFIER"])
+		require.Equal(t, "1", entry["UNIT_VERSION"])
+		require.Equal(t, "alice", entry["USER_NAME"])
+		require.Equal(t, "no", entry["TRUSTED"])
+		require.Equal(t, "clash", entry["FIELD_PRIORITY"])
+		require.Equal(t, "digit", entry["FIELD_1ST"])
+		require.Equal(t, "fail", entry["ERROR"])
+
+		require.True(t, strings.HasSuffix(entry["CODE_FILE"], "journald_test.go"), entry["CODE_FILE"])
+		require.NotEmpty(t, entry["CODE_LINE"])
+		require.NotEmpty(t, entry["CODE_FUNC"])
+		require.NotContains(t, entry, "SRC")
+	})
+
+	t.Run("large entry", func(t *testing.T) {
+		payload := strings.Repeat("x", 4<<20)
+		log.WithField("payload", payload).Info("large")
+
+		entry := journal.read(t)
+		require.Equal(t, "large", entry["MESSAGE"])
+		require.Equal(t, "6", entry["PRIORITY"])
+		require.Equal(t, payload, entry["PAYLOAD"])
+	})
+}
+
+func TestFieldName(t *testing.T) {
+	require.Equal(t, "HTTP_METHOD", journaldHook.FieldName("http.method"))
+	require.Equal(t, "FIELD_", journaldHook.FieldName("__"))
+	require.Len(t, journaldHook.FieldName(strings.Repeat("a", 100)), 64)
+}
@@ -0,0 +1,32 @@
+// Package enable registers the OpenTelemetry hook for default suplogger, when LOG_OTEL_ENABLED is set.
+// Import it for side effects:
+//
+//	import _ "github.com/InjectiveLabs/suplog/hooks/otel/enable"
+package enable
+
+import (
+	"os"
+	"strings"
+
+	"github.com/InjectiveLabs/suplog"
+	otelHook "github.com/InjectiveLabs/suplog/hooks/otel"
+)
+
+func init() {
+	suplog.RegisterDefaultHook(func(hookLogger suplog.Logger) suplog.Hook {
+		if !isTrue(os.Getenv("LOG_OTEL_ENABLED")) {
+			return nil
+		}
+
+		return otelHook.NewHook(hookLogger, nil)
+	})
+}
+
+func isTrue(v string) bool {
+	switch strings.ToLower(v) {
+	case "1", "true", "y":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,200 @@
+package otel
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HookOptions allows to set additional Hook options.
+type HookOptions struct {
+	// Levels enables this hook for all listed levels, defaults to all levels.
+	Levels []logrus.Level
+	// TraceIDField is the field name of trace ID, defaults to "trace_id".
+	// Use "-" to omit the field.
+	TraceIDField string
+	// SpanIDField is the field name of span ID, defaults to "span_id".
+	// Use "-" to omit the field.
+	SpanIDField string
+	// TraceFlagsField is the field name of trace flags, defaults to "trace_flags".
+	// Use "-" to omit the field.
+	TraceFlagsField string
+	// SpanEvents enables recording log entries as events on the active span.
+	SpanEvents bool
+	// SpanEventLevels limits the levels recorded as span events, defaults to Info and above.
+	SpanEventLevels []logrus.Level
+}
+
+// OmitField disables a field when used as its name.
+const OmitField = "-"
+
+func checkHookOptions(opt *HookOptions) *HookOptions {
+	if opt == nil {
+		opt = &HookOptions{}
+	}
+
+	if len(opt.Levels) == 0 {
+		opt.Levels = logrus.AllLevels
+	}
+
+	if len(opt.TraceIDField) == 0 {
+		opt.TraceIDField = getenvDefault("LOG_OTEL_TRACE_ID_FIELD", "trace_id")
+	}
+
+	if len(opt.SpanIDField) == 0 {
+		opt.SpanIDField = getenvDefault("LOG_OTEL_SPAN_ID_FIELD", "span_id")
+	}
+
+	if len(opt.TraceFlagsField) == 0 {
+		opt.TraceFlagsField = getenvDefault("LOG_OTEL_TRACE_FLAGS_FIELD", "trace_flags")
+	}
+
+	if !opt.SpanEvents {
+		opt.SpanEvents = toBool(os.Getenv("LOG_OTEL_SPAN_EVENTS"))
+	}
+
+	if len(opt.SpanEventLevels) == 0 {
+		opt.SpanEventLevels = []logrus.Level{
+			logrus.PanicLevel,
+			logrus.FatalLevel,
+			logrus.ErrorLevel,
+			logrus.WarnLevel,
+			logrus.InfoLevel,
+		}
+	}
+
+	return opt
+}
+
+type RootLogger interface {
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+	Printf(format string, args ...interface{})
+}
+
+// NewHook initializes a new logrus.Hook that adds trace ID, span ID and trace flags
+// of the span found in the entry context, see Logger.WithContext. Optionally, entries
+// are recorded as events on that span. Provide a root logger to print any errors
+// occuring during the plugin init.
+func NewHook(logger RootLogger, opt *HookOptions) logrus.Hook {
+	opt = checkHookOptions(opt)
+
+	h := &hook{
+		opt:         opt,
+		logger:      logger,
+		eventLevels: make(map[logrus.Level]bool, len(opt.SpanEventLevels)),
+	}
+
+	for _, lvl := range opt.SpanEventLevels {
+		h.eventLevels[lvl] = true
+	}
+
+	return h
+}
+
+type hook struct {
+	opt         *HookOptions
+	logger      RootLogger
+	eventLevels map[logrus.Level]bool
+}
+
+func (h *hook) Levels() []logrus.Level {
+	return h.opt.Levels
+}
+
+func (h *hook) Fire(e *logrus.Entry) error {
+	if e.Context == nil {
+		return nil
+	}
+
+	span := trace.SpanFromContext(e.Context)
+	spanCtx := span.SpanContext()
+	if !spanCtx.IsValid() {
+		return nil
+	}
+
+	if h.opt.SpanEvents && h.eventLevels[e.Level] && span.IsRecording() {
+		// before trace fields are added, the span knows them anyway
+		span.AddEvent("log", trace.WithTimestamp(e.Time), trace.WithAttributes(eventAttributes(e)...))
+	}
+
+	setField(e, h.opt.TraceIDField, spanCtx.TraceID().String())
+	setField(e, h.opt.SpanIDField, spanCtx.SpanID().String())
+	setField(e, h.opt.TraceFlagsField, spanCtx.TraceFlags().String())
+
+	return nil
+}
+
+func setField(e *logrus.Entry, key, value string) {
+	if key == OmitField {
+		return
+	}
+
+	e.Data[key] = value
+}
+
+func eventAttributes(e *logrus.Entry) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(e.Data)+4)
+	attrs = append(attrs,
+		attribute.String("log.severity", e.Level.String()),
+		attribute.String("log.message", e.Message),
+	)
+
+	for k, v := range e.Data {
+		if err, ok := v.(error); ok && k == logrus.ErrorKey {
+			attrs = append(attrs,
+				attribute.String("exception.type", fmt.Sprintf("%T", err)),
+				attribute.String("exception.message", err.Error()),
+			)
+
+			continue
+		}
+
+		attrs = append(attrs, toAttribute(k, v))
+	}
+
+	return attrs
+}
+
+func toAttribute(k string, v interface{}) attribute.KeyValue {
+	switch v := v.(type) {
+	case string:
+		return attribute.String(k, v)
+	case bool:
+		return attribute.Bool(k, v)
+	case int:
+		return attribute.Int(k, v)
+	case int64:
+		return attribute.Int64(k, v)
+	case float64:
+		return attribute.Float64(k, v)
+	case error:
+		return attribute.String(k, v.Error())
+	case fmt.Stringer:
+		return attribute.String(k, v.String())
+	default:
+		return attribute.String(k, fmt.Sprint(v))
+	}
+}
+
+func getenvDefault(key, defaultValue string) string {
+	if v := os.Getenv(key); len(v) > 0 {
+		return v
+	}
+
+	return defaultValue
+}
+
+func toBool(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "1", "t", "yes":
+		return true
+	default:
+		return false
+	}
+}
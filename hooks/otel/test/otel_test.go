@@ -0,0 +1,102 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	otelHook "github.com/InjectiveLabs/suplog/hooks/otel"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+func TestOtelHook(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer("test")
+
+	t.Run("adds trace fields", func(t *testing.T) {
+		var out strings.Builder
+		log := suplog.NewLogger(&out, new(suplog.TextFormatter), otelHook.NewHook(suplog.DefaultLogger, nil))
+
+		ctx, span := tracer.Start(context.Background(), "op")
+		log.WithContext(ctx).Info("in span")
+		span.End()
+
+		spanCtx := span.SpanContext()
+		require.Contains(t, out.String(), "trace_id="+spanCtx.TraceID().String())
+		require.Contains(t, out.String(), "span_id="+spanCtx.SpanID().String())
+		require.Contains(t, out.String(), "trace_flags=01")
+	})
+
+	t.Run("no span in context", func(t *testing.T) {
+		var out strings.Builder
+		log := suplog.NewLogger(&out, new(suplog.TextFormatter), otelHook.NewHook(suplog.DefaultLogger, nil))
+
+		log.WithContext(context.Background()).Info("no span")
+		log.Info("no context")
+
+		require.NotContains(t, out.String(), "trace_id")
+	})
+
+	t.Run("custom field names", func(t *testing.T) {
+		var out strings.Builder
+		log := suplog.NewLogger(&out, new(suplog.TextFormatter), otelHook.NewHook(suplog.DefaultLogger, &otelHook.HookOptions{
+			TraceIDField:    "dd.trace_id",
+			SpanIDField:     "dd.span_id",
+			TraceFlagsField: otelHook.OmitField,
+		}))
+
+		ctx, span := tracer.Start(context.Background(), "op")
+		log.WithContext(ctx).Info("in span")
+		span.End()
+
+		require.Contains(t, out.String(), "dd.trace_id="+span.SpanContext().TraceID().String())
+		require.Contains(t, out.String(), "dd.span_id=")
+		require.NotContains(t, out.String(), "trace_flags")
+	})
+
+	t.Run("records span events", func(t *testing.T) {
+		var out strings.Builder
+		log := suplog.NewLogger(&out, new(suplog.TextFormatter), otelHook.NewHook(suplog.DefaultLogger, &otelHook.HookOptions{
+			SpanEvents: true,
+		}))
+		log.(suplog.LoggerConfigurator).SetLevel(suplog.DebugLevel)
+
+		ctx, span := tracer.Start(context.Background(), "events")
+		log.WithContext(ctx).Debug("not recorded")
+		log.WithContext(ctx).WithField("user", "alice").WithError(errors.New("fail")).Error("failed")
+		span.End()
+
+		var events []sdktrace.Event
+		for _, s := range recorder.Ended() {
+			if s.Name() == "events" {
+				events = s.Events()
+			}
+		}
+
+		require.Len(t, events, 1)
+		require.Equal(t, "log", events[0].Name)
+
+		attrs := attribute.NewSet(events[0].Attributes...)
+		for key, value := range map[string]string{
+			"log.severity":      "error",
+			"log.message":       "failed",
+			"user":              "alice",
+			"exception.message": "fail",
+		} {
+			v, ok := attrs.Value(attribute.Key(key))
+			require.True(t, ok, key)
+			require.Equal(t, value, v.AsString())
+		}
+
+		_, ok := attrs.Value("trace_id")
+		require.False(t, ok)
+	})
+}
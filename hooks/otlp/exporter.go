@@ -0,0 +1,269 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type exporter interface {
+	export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error
+	shutdown(ctx context.Context) error
+}
+
+func newExporter(opt *HookOptions) (exporter, error) {
+	switch opt.Protocol {
+	case ProtocolGRPC:
+		return newGRPCExporter(opt)
+	case ProtocolHTTP:
+		return newHTTPExporter(opt)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol: %s", opt.Protocol)
+	}
+}
+
+// retryableError marks errors worth another attempt, with an optional
+// delay requested by the server.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// partialSuccessError reports records rejected by the collector, not retried.
+type partialSuccessError struct {
+	rejected int64
+	message  string
+}
+
+func (e *partialSuccessError) Error() string {
+	return fmt.Sprintf("collector rejected %d log records: %s", e.rejected, e.message)
+}
+
+func checkPartialSuccess(resp *collogspb.ExportLogsServiceResponse) error {
+	if ps := resp.GetPartialSuccess(); ps != nil && ps.RejectedLogRecords > 0 {
+		return &partialSuccessError{
+			rejected: ps.RejectedLogRecords,
+			message:  ps.ErrorMessage,
+		}
+	}
+
+	return nil
+}
+
+// retry calls fn until it succeeds, fails with non-retryable error,
+// or the retry time is elapsed. Intervals grow exponentially with jitter.
+func retry(ctx context.Context, opt *HookOptions, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	interval := opt.RetryInitialInterval
+
+	for {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var retryErr *retryableError
+		if !errors.As(err, &retryErr) || opt.RetryMaxElapsedTime < 0 {
+			return err
+		}
+
+		delay := interval/2 + time.Duration(rand.Int63n(int64(interval)))
+		if retryErr.retryAfter > delay {
+			delay = retryErr.retryAfter
+		}
+
+		if time.Since(start)+delay > opt.RetryMaxElapsedTime {
+			return fmt.Errorf("retries exhausted: %w", err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("retries aborted: %w", err)
+		case <-timer.C:
+		}
+
+		if interval *= 2; interval > opt.RetryMaxInterval {
+			interval = opt.RetryMaxInterval
+		}
+	}
+}
+
+type grpcExporter struct {
+	conn    *grpc.ClientConn
+	client  collogspb.LogsServiceClient
+	headers metadata.MD
+}
+
+func newGRPCExporter(opt *HookOptions) (*grpcExporter, error) {
+	endpoint := opt.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = "localhost:4317"
+	}
+
+	creds := credentials.NewTLS(opt.TLSConfig)
+	if opt.Insecure || strings.HasPrefix(endpoint, "http://") {
+		creds = insecure.NewCredentials()
+	}
+
+	endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://")
+
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to init OTLP gRPC client: %w", err)
+	}
+
+	return &grpcExporter{
+		conn:    conn,
+		client:  collogspb.NewLogsServiceClient(conn),
+		headers: metadata.New(opt.Headers),
+	}, nil
+}
+
+func (e *grpcExporter) export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	if len(e.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, e.headers)
+	}
+
+	resp, err := e.client.Export(ctx, req)
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted,
+			codes.Aborted, codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+			return &retryableError{err: err}
+		default:
+			return err
+		}
+	}
+
+	return checkPartialSuccess(resp)
+}
+
+func (e *grpcExporter) shutdown(ctx context.Context) error {
+	return e.conn.Close()
+}
+
+type httpExporter struct {
+	url     string
+	client  *http.Client
+	headers map[string]string
+}
+
+func newHTTPExporter(opt *HookOptions) (*httpExporter, error) {
+	endpoint := opt.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = "http://localhost:4318"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OTLP endpoint: %w", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("OTLP HTTP endpoint must be http(s) URL: %s", endpoint)
+	}
+
+	if len(strings.Trim(u.Path, "/")) == 0 {
+		u.Path = "/v1/logs"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opt.TLSConfig != nil {
+		transport.TLSClientConfig = opt.TLSConfig
+	}
+
+	return &httpExporter{
+		url: u.String(),
+		client: &http.Client{
+			Transport: transport,
+		},
+		headers: opt.Headers,
+	}, nil
+}
+
+func (e *httpExporter) export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal export request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range e.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		// network errors are usually transient
+		return &retryableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return &retryableError{err: err}
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		var exportResp collogspb.ExportLogsServiceResponse
+		if len(respBody) > 0 && proto.Unmarshal(respBody, &exportResp) == nil {
+			return checkPartialSuccess(&exportResp)
+		}
+
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return &retryableError{
+			err:        fmt.Errorf("OTLP export failed with status %d", resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	default:
+		return fmt.Errorf("OTLP export failed with status %d", resp.StatusCode)
+	}
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	return 0
+}
+
+func (e *httpExporter) shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
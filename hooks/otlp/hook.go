@@ -0,0 +1,405 @@
+package otlp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+// Protocol is the OTLP transport protocol.
+type Protocol string
+
+const (
+	// ProtocolGRPC exports logs over gRPC, default endpoint is "localhost:4317".
+	ProtocolGRPC Protocol = "grpc"
+	// ProtocolHTTP exports logs as protobuf over HTTP, default endpoint is
+	// "http://localhost:4318/v1/logs".
+	ProtocolHTTP Protocol = "http/protobuf"
+)
+
+// HookOptions allows to set additional Hook options.
+type HookOptions struct {
+	// Levels enables this hook for all listed levels, defaults to all levels.
+	Levels []logrus.Level
+	// Protocol selects the transport, defaults to ProtocolGRPC.
+	Protocol Protocol
+	// Endpoint is "host:port" for gRPC, or URL for HTTP. Path "/v1/logs"
+	// is added to HTTP endpoints without path.
+	Endpoint string
+	// Insecure disables TLS for gRPC. HTTP uses the scheme of the endpoint,
+	// certificates of https endpoints are always verified.
+	Insecure bool
+	// TLSConfig is used for TLS connections, defaults to system roots.
+	TLSConfig *tls.Config
+	// Headers are sent with each export request, e.g. API keys.
+	Headers map[string]string
+
+	// ServiceName is reported as service.name resource attribute.
+	ServiceName string
+	// AppVersion is reported as service.version resource attribute.
+	AppVersion string
+	// Env is reported as deployment.environment resource attribute.
+	Env string
+	// ResourceAttributes are added to the resource of each export request.
+	ResourceAttributes map[string]string
+
+	// BatchSize is the maximum amount of records in one export request, defaults to 512.
+	BatchSize int
+	// QueueSize limits the amount of records waiting for export, new records are
+	// dropped once the limit is reached. Defaults to 4096.
+	QueueSize int
+	// FlushInterval is the maximum delay of records in queue, defaults to 1 second.
+	FlushInterval time.Duration
+	// ExportTimeout limits a single export attempt, defaults to 10 seconds.
+	ExportTimeout time.Duration
+
+	// RetryInitialInterval is the first backoff interval, defaults to 500ms.
+	RetryInitialInterval time.Duration
+	// RetryMaxInterval limits backoff interval, defaults to 5 seconds.
+	RetryMaxInterval time.Duration
+	// RetryMaxElapsedTime limits the time spent retrying one batch, defaults to 30 seconds.
+	// Negative value disables retries.
+	RetryMaxElapsedTime time.Duration
+
+	// StackTraceOffset allows to wrap logger into greater stack depth and still
+	// get reports on accurate positions.
+	StackTraceOffset int
+}
+
+func checkHookOptions(opt *HookOptions) *HookOptions {
+	if opt == nil {
+		opt = &HookOptions{}
+	}
+
+	if len(opt.Levels) == 0 {
+		opt.Levels = logrus.AllLevels
+	}
+
+	if len(opt.Protocol) == 0 {
+		opt.Protocol = Protocol(os.Getenv("LOG_OTLP_PROTOCOL"))
+		if len(opt.Protocol) == 0 {
+			opt.Protocol = ProtocolGRPC
+		}
+	}
+
+	if len(opt.Endpoint) == 0 {
+		opt.Endpoint = os.Getenv("LOG_OTLP_ENDPOINT")
+	}
+
+	if !opt.Insecure {
+		opt.Insecure = toBool(os.Getenv("LOG_OTLP_INSECURE"))
+	}
+
+	if len(opt.Headers) == 0 {
+		opt.Headers = parseHeaders(os.Getenv("LOG_OTLP_HEADERS"))
+	}
+
+	if len(opt.ServiceName) == 0 {
+		opt.ServiceName = os.Getenv("LOG_OTLP_SERVICE_NAME")
+		if len(opt.ServiceName) == 0 {
+			opt.ServiceName = "unknown_service:" + filepath.Base(os.Args[0])
+		}
+	}
+
+	if len(opt.AppVersion) == 0 {
+		opt.AppVersion = os.Getenv("APP_VERSION")
+	}
+
+	if len(opt.Env) == 0 {
+		opt.Env = os.Getenv("APP_ENV")
+	}
+
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = 512
+	}
+
+	if opt.QueueSize <= 0 {
+		opt.QueueSize = 4096
+	}
+
+	if opt.FlushInterval <= 0 {
+		opt.FlushInterval = time.Second
+	}
+
+	if opt.ExportTimeout <= 0 {
+		opt.ExportTimeout = 10 * time.Second
+	}
+
+	if opt.RetryInitialInterval <= 0 {
+		opt.RetryInitialInterval = 500 * time.Millisecond
+	}
+
+	if opt.RetryMaxInterval <= 0 {
+		opt.RetryMaxInterval = 5 * time.Second
+	}
+
+	if opt.RetryMaxElapsedTime == 0 {
+		opt.RetryMaxElapsedTime = 30 * time.Second
+	}
+
+	return opt
+}
+
+// parseHeaders parses "key1=value1,key2=value2" lists.
+func parseHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return headers
+}
+
+func toBool(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "1", "t", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+type RootLogger interface {
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+	Printf(format string, args ...interface{})
+}
+
+const defaultStackSearchOffset = 6
+
+// ErrShutdown is returned when using the hook after Shutdown.
+var ErrShutdown = errors.New("otlp hook is shut down")
+
+// NewHook initializes a new OTLP exporting hook using provided params and options.
+// Provide a root logger to print any errors occuring during the init or export, it must
+// not use this hook itself. If the exporter fails to init, the hook drops all records.
+func NewHook(logger RootLogger, opt *HookOptions) *Hook {
+	opt = checkHookOptions(opt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h := &Hook{
+		opt:      opt,
+		logger:   logger,
+		stack:    stackcache.New(defaultStackSearchOffset, opt.StackTraceOffset, "github.com/InjectiveLabs/suplog"),
+		resource: newResource(opt),
+		records:  make(chan *logspb.LogRecord, opt.QueueSize),
+		flushC:   make(chan chan struct{}),
+		stopC:    make(chan struct{}),
+		doneC:    make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	exp, err := newExporter(opt)
+	if err != nil {
+		logger.Errorf("failed to init OTLP exporter, records are dropped: %v", err)
+
+		// the hook is shut down from the start
+		cancel()
+		atomic.StoreInt32(&h.stopped, 1)
+		h.stopOnce.Do(func() {})
+		close(h.doneC)
+
+		return h
+	}
+
+	h.exporter = exp
+	go h.run()
+
+	return h
+}
+
+// Hook converts entries into OTLP log records, and exports them in batches.
+type Hook struct {
+	opt      *HookOptions
+	logger   RootLogger
+	stack    stackcache.StackCache
+	exporter exporter
+	resource *resourceInfo
+
+	records chan *logspb.LogRecord
+	flushC  chan chan struct{}
+	stopC   chan struct{}
+	doneC   chan struct{}
+
+	// ctx is cancelled when Shutdown deadline is exceeded, aborting retries.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stopOnce sync.Once
+	stopped  int32
+	dropped  uint64
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return h.opt.Levels
+}
+
+// Fire converts the entry into a log record and queues it for export. Entries
+// at Fatal and Panic levels are exported before Fire returns. After Shutdown,
+// records are dropped and counted by Dropped.
+func (h *Hook) Fire(e *logrus.Entry) error {
+	if atomic.LoadInt32(&h.stopped) == 1 {
+		atomic.AddUint64(&h.dropped, 1)
+		return nil
+	}
+
+	select {
+	case h.records <- h.newRecord(e):
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+		return nil
+	}
+
+	if e.Level <= logrus.FatalLevel {
+		ctx, cancel := context.WithTimeout(context.Background(), h.opt.ExportTimeout)
+		defer cancel()
+
+		_ = h.Flush(ctx)
+	}
+
+	return nil
+}
+
+// Dropped returns the amount of records dropped, due to full queue or failed export.
+func (h *Hook) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// Flush exports all queued records, blocks until done or the context is done.
+func (h *Hook) Flush(ctx context.Context) error {
+	doneC := make(chan struct{})
+
+	select {
+	case h.flushC <- doneC:
+	case <-h.doneC:
+		return ErrShutdown
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-doneC:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports all queued records and closes the exporter. Once the context
+// is done, pending retries are aborted and their records are dropped.
+func (h *Hook) Shutdown(ctx context.Context) error {
+	err := ErrShutdown
+
+	h.stopOnce.Do(func() {
+		atomic.StoreInt32(&h.stopped, 1)
+		close(h.stopC)
+
+		select {
+		case <-h.doneC:
+			err = nil
+		case <-ctx.Done():
+			h.cancel()
+			<-h.doneC
+			err = ctx.Err()
+		}
+
+		h.cancel()
+
+		if closeErr := h.exporter.shutdown(ctx); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	})
+
+	return err
+}
+
+func (h *Hook) run() {
+	defer close(h.doneC)
+
+	ticker := time.NewTicker(h.opt.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*logspb.LogRecord, 0, h.opt.BatchSize)
+
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		h.export(batch)
+		batch = make([]*logspb.LogRecord, 0, h.opt.BatchSize)
+	}
+
+	drain := func() {
+		for {
+			select {
+			case rec := <-h.records:
+				batch = append(batch, rec)
+				if len(batch) >= h.opt.BatchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case rec := <-h.records:
+			batch = append(batch, rec)
+			if len(batch) >= h.opt.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case doneC := <-h.flushC:
+			drain()
+			close(doneC)
+		case <-h.stopC:
+			drain()
+			return
+		}
+	}
+}
+
+func (h *Hook) export(batch []*logspb.LogRecord) {
+	req := h.resource.newRequest(batch)
+
+	err := retry(h.ctx, h.opt, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, h.opt.ExportTimeout)
+		defer cancel()
+
+		return h.exporter.export(ctx, req)
+	})
+
+	var partialErr *partialSuccessError
+	if errors.As(err, &partialErr) {
+		atomic.AddUint64(&h.dropped, uint64(partialErr.rejected))
+		h.logger.Warningf("%v", partialErr)
+	} else if err != nil {
+		atomic.AddUint64(&h.dropped, uint64(len(batch)))
+		h.logger.Errorf("failed to export %d log records: %v", len(batch), err)
+	}
+}
@@ -0,0 +1,229 @@
+package otlp
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+const scopeName = "github.com/InjectiveLabs/suplog"
+
+// SeverityNumber maps logrus levels to OTLP severity numbers.
+func SeverityNumber(level logrus.Level) logspb.SeverityNumber {
+	switch level {
+	case logrus.PanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL4
+	case logrus.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case logrus.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case logrus.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case logrus.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case logrus.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case logrus.TraceLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_TRACE
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED
+	}
+}
+
+func (h *Hook) newRecord(e *logrus.Entry) *logspb.LogRecord {
+	rec := &logspb.LogRecord{
+		TimeUnixNano:         uint64(e.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       SeverityNumber(e.Level),
+		SeverityText:         strings.ToUpper(e.Level.String()),
+		Body:                 stringValue(e.Message),
+		Attributes:           make([]*commonpb.KeyValue, 0, len(e.Data)+3),
+	}
+
+	for k, v := range e.Data {
+		if err, ok := v.(error); ok && k == logrus.ErrorKey {
+			rec.Attributes = append(rec.Attributes, h.exceptionAttributes(e, err)...)
+			continue
+		}
+
+		rec.Attributes = append(rec.Attributes, &commonpb.KeyValue{
+			Key:   k,
+			Value: anyValue(v),
+		})
+	}
+
+	if e.Context != nil {
+		if spanCtx := trace.SpanContextFromContext(e.Context); spanCtx.IsValid() {
+			traceID := spanCtx.TraceID()
+			spanID := spanCtx.SpanID()
+
+			rec.TraceId = traceID[:]
+			rec.SpanId = spanID[:]
+			rec.Flags = uint32(spanCtx.TraceFlags())
+		}
+	}
+
+	return rec
+}
+
+func (h *Hook) exceptionAttributes(e *logrus.Entry, err error) []*commonpb.KeyValue {
+	var frames []runtime.Frame
+	if caller, ok := stackcache.CallerFromContext(e.Context); ok {
+		// logged later than produced, only the original caller is known
+		frames = []runtime.Frame{caller}
+	} else {
+		frames = h.stack.GetStackFrames()
+	}
+
+	attrs := []*commonpb.KeyValue{
+		{Key: "exception.type", Value: stringValue(fmt.Sprintf("%T", err))},
+		{Key: "exception.message", Value: stringValue(err.Error())},
+	}
+
+	if len(frames) > 0 {
+		attrs = append(attrs,
+			&commonpb.KeyValue{Key: "exception.stacktrace", Value: stringValue(formatStack(frames))},
+			&commonpb.KeyValue{Key: "code.function", Value: stringValue(frames[0].Function)},
+			&commonpb.KeyValue{Key: "code.filepath", Value: stringValue(frames[0].File)},
+			&commonpb.KeyValue{Key: "code.lineno", Value: intValue(int64(frames[0].Line))},
+		)
+	}
+
+	return attrs
+}
+
+// formatStack formats frames the same way as runtime/debug.Stack does.
+func formatStack(frames []runtime.Frame) string {
+	var b strings.Builder
+
+	for _, f := range frames {
+		fmt.Fprintf(&b, "%s()\n\t%s:%d\n", f.Function, f.File, f.Line)
+	}
+
+	return b.String()
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{
+		Value: &commonpb.AnyValue_StringValue{StringValue: s},
+	}
+}
+
+func intValue(n int64) *commonpb.AnyValue {
+	return &commonpb.AnyValue{
+		Value: &commonpb.AnyValue_IntValue{IntValue: n},
+	}
+}
+
+func anyValue(v interface{}) *commonpb.AnyValue {
+	switch v := v.(type) {
+	case nil:
+		return &commonpb.AnyValue{}
+	case string:
+		return stringValue(v)
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return intValue(int64(v))
+	case int8:
+		return intValue(int64(v))
+	case int16:
+		return intValue(int64(v))
+	case int32:
+		return intValue(int64(v))
+	case int64:
+		return intValue(v)
+	case uint:
+		return intValue(int64(v))
+	case uint8:
+		return intValue(int64(v))
+	case uint16:
+		return intValue(int64(v))
+	case uint32:
+		return intValue(int64(v))
+	case uint64:
+		return intValue(int64(v))
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(v)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case []byte:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v}}
+	case time.Time:
+		return stringValue(v.Format(time.RFC3339Nano))
+	case error:
+		return stringValue(v.Error())
+	case fmt.Stringer:
+		return stringValue(v.String())
+	case []string:
+		values := make([]*commonpb.AnyValue, 0, len(v))
+		for _, s := range v {
+			values = append(values, stringValue(s))
+		}
+
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{
+			ArrayValue: &commonpb.ArrayValue{Values: values},
+		}}
+	default:
+		return stringValue(fmt.Sprint(v))
+	}
+}
+
+type resourceInfo struct {
+	resource *resourcepb.Resource
+	scope    *commonpb.InstrumentationScope
+}
+
+func newResource(opt *HookOptions) *resourceInfo {
+	attrs := []*commonpb.KeyValue{
+		{Key: "service.name", Value: stringValue(opt.ServiceName)},
+	}
+
+	if len(opt.AppVersion) > 0 {
+		attrs = append(attrs, &commonpb.KeyValue{Key: "service.version", Value: stringValue(opt.AppVersion)})
+	}
+
+	if len(opt.Env) > 0 {
+		attrs = append(attrs, &commonpb.KeyValue{Key: "deployment.environment", Value: stringValue(opt.Env)})
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		attrs = append(attrs, &commonpb.KeyValue{Key: "host.name", Value: stringValue(hostname)})
+	}
+
+	for k, v := range opt.ResourceAttributes {
+		attrs = append(attrs, &commonpb.KeyValue{Key: k, Value: stringValue(v)})
+	}
+
+	return &resourceInfo{
+		resource: &resourcepb.Resource{
+			Attributes: attrs,
+		},
+		scope: &commonpb.InstrumentationScope{
+			Name: scopeName,
+		},
+	}
+}
+
+func (r *resourceInfo) newRequest(records []*logspb.LogRecord) *collogspb.ExportLogsServiceRequest {
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: r.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      r.scope,
+				LogRecords: records,
+			}},
+		}},
+	}
+}
@@ -0,0 +1,309 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	otlpHook "github.com/InjectiveLabs/suplog/hooks/otlp"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+// fakeCollector records export requests, failing the first failures of them.
+type fakeCollector struct {
+	collogspb.UnimplementedLogsServiceServer
+
+	mux      sync.Mutex
+	failures int
+	requests []*collogspb.ExportLogsServiceRequest
+	headers  []string
+}
+
+func (c *fakeCollector) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		c.headers = append(c.headers, md.Get("api-key")...)
+	}
+
+	if c.failures > 0 {
+		c.failures--
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+
+	c.requests = append(c.requests, req)
+
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (c *fakeCollector) records() []*logspb.LogRecord {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	var records []*logspb.LogRecord
+	for _, req := range c.requests {
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				records = append(records, sl.LogRecords...)
+			}
+		}
+	}
+
+	return records
+}
+
+func attrs(rec *logspb.LogRecord) map[string]*commonpb.AnyValue {
+	m := make(map[string]*commonpb.AnyValue, len(rec.Attributes))
+	for _, kv := range rec.Attributes {
+		m[kv.Key] = kv.Value
+	}
+
+	return m
+}
+
+func fastRetries(opt *otlpHook.HookOptions) *otlpHook.HookOptions {
+	opt.RetryInitialInterval = 10 * time.Millisecond
+	opt.RetryMaxInterval = 20 * time.Millisecond
+	opt.RetryMaxElapsedTime = 5 * time.Second
+	opt.ServiceName = "otlp-test"
+
+	return opt
+}
+
+func TestOTLPHookGRPC(t *testing.T) {
+	collector := &fakeCollector{failures: 2}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(srv, collector)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	var rootOut strings.Builder
+	hook := otlpHook.NewHook(suplog.NewLogger(&rootOut, new(suplog.TextFormatter)), fastRetries(&otlpHook.HookOptions{
+		Protocol: otlpHook.ProtocolGRPC,
+		Endpoint: lis.Addr().String(),
+		Insecure: true,
+		Headers:  map[string]string{"api-key": "secret"},
+	}))
+
+	log := suplog.NewLogger(io.Discard, new(suplog.TextFormatter), hook)
+
+	tracer := sdktrace.NewTracerProvider().Tracer("test")
+	ctx, span := tracer.Start(context.Background(), "op")
+
+	log.WithContext(ctx).WithFields(suplog.Fields{
+		"user":  "alice",
+		"count": 3,
+	}).Info("hello")
+	log.WithError(errors.New("fail")).Error("failed")
+	span.End()
+
+	require.NoError(t, hook.Shutdown(context.Background()))
+	require.Empty(t, rootOut.String())
+	require.Zero(t, hook.Dropped())
+
+	records := collector.records()
+	require.Len(t, records, 2)
+
+	info := records[0]
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_INFO, info.SeverityNumber)
+	require.Equal(t, "INFO", info.SeverityText)
+	require.Equal(t, "hello", info.Body.GetStringValue())
+	require.Equal(t, "alice", attrs(info)["user"].GetStringValue())
+	require.EqualValues(t, 3, attrs(info)["count"].GetIntValue())
+
+	traceID := span.SpanContext().TraceID()
+	spanID := span.SpanContext().SpanID()
+	require.Equal(t, traceID[:], info.TraceId)
+	require.Equal(t, spanID[:], info.SpanId)
+
+	failed := records[1]
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, failed.SeverityNumber)
+	require.Equal(t, "fail", attrs(failed)["exception.message"].GetStringValue())
+	require.Equal(t, "*errors.errorString", attrs(failed)["exception.type"].GetStringValue())
+	require.Contains(t, attrs(failed)["exception.stacktrace"].GetStringValue(), "otlp_test.go")
+	require.Contains(t, attrs(failed)["code.filepath"].GetStringValue(), "otlp_test.go")
+	require.Empty(t, failed.TraceId)
+
+	collector.mux.Lock()
+	require.Equal(t, "otlp-test", collector.requests[0].ResourceLogs[0].Resource.Attributes[0].Value.GetStringValue())
+	require.Equal(t, []string{"secret", "secret", "secret"}, collector.headers)
+	collector.mux.Unlock()
+
+	// after shutdown entries are dropped
+	log.Info("too late")
+	require.EqualValues(t, 1, hook.Dropped())
+	require.ErrorIs(t, hook.Flush(context.Background()), otlpHook.ErrShutdown)
+}
+
+func TestOTLPHookHTTP(t *testing.T) {
+	collector := new(fakeCollector)
+
+	var (
+		mux      sync.Mutex
+		attempts int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/logs", r.URL.Path)
+		require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+
+		mux.Lock()
+		attempts++
+		n := attempts
+		mux.Unlock()
+
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		req := new(collogspb.ExportLogsServiceRequest)
+		require.NoError(t, proto.Unmarshal(body, req))
+
+		resp, _ := collector.Export(r.Context(), req)
+		out, _ := proto.Marshal(resp)
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = w.Write(out)
+	}))
+	defer server.Close()
+
+	hook := otlpHook.NewHook(suplog.DefaultLogger, fastRetries(&otlpHook.HookOptions{
+		Protocol:  otlpHook.ProtocolHTTP,
+		Endpoint:  server.URL,
+		BatchSize: 2,
+	}))
+	defer hook.Shutdown(context.Background())
+
+	log := suplog.NewLogger(io.Discard, new(suplog.TextFormatter), hook)
+	log.(suplog.LoggerConfigurator).SetLevel(suplog.TraceLevel)
+
+	log.Trace("one")
+	log.Warning("two")
+	log.Debug("three")
+
+	require.NoError(t, hook.Flush(context.Background()))
+
+	records := collector.records()
+	require.Len(t, records, 3)
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_TRACE, records[0].SeverityNumber)
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_WARN, records[1].SeverityNumber)
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG, records[2].SeverityNumber)
+
+	collector.mux.Lock()
+	require.Len(t, collector.requests, 2) // batched by 2
+	collector.mux.Unlock()
+}
+
+func TestOTLPHookNonRetryable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	var rootOut strings.Builder
+	hook := otlpHook.NewHook(suplog.NewLogger(&rootOut, new(suplog.TextFormatter)), fastRetries(&otlpHook.HookOptions{
+		Protocol: otlpHook.ProtocolHTTP,
+		Endpoint: server.URL,
+	}))
+
+	log := suplog.NewLogger(io.Discard, new(suplog.TextFormatter), hook)
+	log.Info("rejected")
+
+	require.NoError(t, hook.Shutdown(context.Background()))
+	require.EqualValues(t, 1, hook.Dropped())
+	require.Contains(t, rootOut.String(), "status 400")
+}
+
+func TestOTLPHookHTTPVerifiesTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer server.Close()
+
+	var rootOut strings.Builder
+	opt := fastRetries(&otlpHook.HookOptions{
+		Protocol: otlpHook.ProtocolHTTP,
+		Endpoint: server.URL,
+		Insecure: true, // applies to gRPC only
+	})
+	opt.RetryMaxElapsedTime = 100 * time.Millisecond
+
+	hook := otlpHook.NewHook(suplog.NewLogger(&rootOut, new(suplog.TextFormatter)), opt)
+
+	log := suplog.NewLogger(io.Discard, new(suplog.TextFormatter), hook)
+	log.Info("unverified")
+
+	require.NoError(t, hook.Shutdown(context.Background()))
+	require.EqualValues(t, 1, hook.Dropped())
+	require.Contains(t, rootOut.String(), "certificate")
+
+	// trusted with TLSConfig
+	hook = otlpHook.NewHook(suplog.DefaultLogger, fastRetries(&otlpHook.HookOptions{
+		Protocol:  otlpHook.ProtocolHTTP,
+		Endpoint:  server.URL,
+		TLSConfig: server.Client().Transport.(*http.Transport).TLSClientConfig,
+	}))
+
+	log = suplog.NewLogger(io.Discard, new(suplog.TextFormatter), hook)
+	log.Info("verified")
+
+	require.NoError(t, hook.Shutdown(context.Background()))
+	require.Zero(t, hook.Dropped())
+}
+
+func TestOTLPHookDropsRecords(t *testing.T) {
+	var rootOut strings.Builder
+	hook := otlpHook.NewHook(suplog.NewLogger(&rootOut, new(suplog.TextFormatter)), &otlpHook.HookOptions{
+		Protocol: "smoke-signals",
+	})
+	require.Contains(t, rootOut.String(), "failed to init OTLP exporter")
+
+	log := suplog.NewLogger(io.Discard, new(suplog.TextFormatter), hook)
+	log.Info("not exported")
+
+	require.EqualValues(t, 1, hook.Dropped())
+	require.ErrorIs(t, hook.Flush(context.Background()), otlpHook.ErrShutdown)
+	require.ErrorIs(t, hook.Shutdown(context.Background()), otlpHook.ErrShutdown)
+
+	// after shutdown
+	hook = otlpHook.NewHook(suplog.DefaultLogger, fastRetries(&otlpHook.HookOptions{
+		Protocol: otlpHook.ProtocolHTTP,
+		Endpoint: "http://127.0.0.1:1",
+	}))
+	require.NoError(t, hook.Shutdown(context.Background()))
+
+	log = suplog.NewLogger(io.Discard, new(suplog.TextFormatter), hook)
+	log.Info("after shutdown")
+
+	require.EqualValues(t, 1, hook.Dropped())
+}
@@ -0,0 +1,270 @@
+package redact
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cosmos/go-bip39"
+	"github.com/sirupsen/logrus"
+)
+
+// Mode defines how redacted values are replaced.
+type Mode string
+
+const (
+	// ModeMask replaces the whole value with Placeholder.
+	ModeMask Mode = "mask"
+	// ModePartial masks the value, but keeps its last KeepLast characters.
+	ModePartial Mode = "partial"
+	// ModeHash replaces the value with its salted hash, so equal values could be correlated.
+	ModeHash Mode = "hash"
+)
+
+// Pattern is a named regexp matching sensitive values.
+type Pattern struct {
+	Name   string
+	Regexp *regexp.Regexp
+	// Validate, if set, filters the regexp matches.
+	Validate func(match string) bool
+}
+
+// Builtin patterns, see DefaultPatterns.
+var (
+	PatternJWT = Pattern{
+		Name:   "jwt",
+		Regexp: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]+`),
+	}
+	PatternEmail = Pattern{
+		Name:   "email",
+		Regexp: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	}
+	// PatternHexKey matches 64 hex digits, like private keys, but tx and block hashes too,
+	// so it's not in DefaultPatterns. Keys are redacted by field names, see DefaultKeys.
+	PatternHexKey = Pattern{
+		Name:   "hexkey",
+		Regexp: regexp.MustCompile(`\b(?:0x)?[0-9a-fA-F]{64}\b`),
+	}
+	// PatternMnemonic matches a value consisting only of 12 to 24 words
+	// from the BIP39 English word list, so regular sentences are left alone.
+	PatternMnemonic = Pattern{
+		Name:     "mnemonic",
+		Regexp:   regexp.MustCompile(`^\s*(?:[a-z]{3,8}\s+){11,23}[a-z]{3,8}\s*$`),
+		Validate: isMnemonic,
+	}
+)
+
+// DefaultPatterns are used when no patterns are configured.
+var DefaultPatterns = []Pattern{
+	PatternJWT,
+	PatternEmail,
+	PatternMnemonic,
+}
+
+// builtinPatterns are selected by name in LOG_REDACT_PATTERNS.
+var builtinPatterns = []Pattern{
+	PatternJWT,
+	PatternEmail,
+	PatternHexKey,
+	PatternMnemonic,
+}
+
+// DefaultKeys are used when no key rules are configured.
+var DefaultKeys = []string{
+	"password",
+	"passwd",
+	"*secret*",
+	"*token*",
+	"*api_key*",
+	"*apikey*",
+	"*private_key*",
+	"*privkey*",
+	"authorization",
+	"cookie",
+	"mnemonic",
+	"seed",
+}
+
+// HookOptions allows to set additional Hook options.
+type HookOptions struct {
+	// Levels enables this hook for all listed levels, defaults to all levels.
+	Levels []logrus.Level
+	// Keys are field name rules, matched case-insensitively: either exact names,
+	// or globs such as "*token*". Values of matching fields are redacted entirely.
+	// Defaults to DefaultKeys, or comma-separated LOG_REDACT_KEYS.
+	Keys []string
+	// Patterns are value regexps, their matches are redacted in string values, UTF-8 byte slices,
+	// errors and messages. Defaults to DefaultPatterns, or comma-separated builtin
+	// pattern names in LOG_REDACT_PATTERNS, use "none" to disable patterns.
+	Patterns []Pattern
+	// Mode of replacement, defaults to ModeMask, or LOG_REDACT_MODE.
+	Mode Mode
+	// Placeholder replaces masked values, defaults to "[REDACTED]".
+	Placeholder string
+	// KeepLast is the amount of characters kept by ModePartial, defaults to 4.
+	KeepLast int
+	// Salt is used by ModeHash, defaults to LOG_REDACT_SALT.
+	Salt string
+	// MaxDepth limits recursion into maps, slices and structs, defaults to 8.
+	MaxDepth int
+	// DisableMessage skips applying patterns to entry messages.
+	DisableMessage bool
+}
+
+const (
+	defaultPlaceholder = "[REDACTED]"
+	defaultKeepLast    = 4
+	defaultMaxDepth    = 8
+)
+
+func checkHookOptions(opt *HookOptions) *HookOptions {
+	if opt == nil {
+		opt = &HookOptions{}
+	}
+
+	if len(opt.Levels) == 0 {
+		opt.Levels = logrus.AllLevels
+	}
+
+	if len(opt.Keys) == 0 {
+		opt.Keys = DefaultKeys
+		if keys := splitList(os.Getenv("LOG_REDACT_KEYS")); len(keys) > 0 {
+			opt.Keys = keys
+		}
+	}
+
+	if opt.Patterns == nil {
+		opt.Patterns = DefaultPatterns
+		if names := splitList(os.Getenv("LOG_REDACT_PATTERNS")); len(names) > 0 {
+			opt.Patterns = patternsByName(names)
+		}
+	}
+
+	if len(opt.Mode) == 0 {
+		opt.Mode = ModeMask
+		if mode := Mode(strings.ToLower(os.Getenv("LOG_REDACT_MODE"))); len(mode) > 0 {
+			opt.Mode = mode
+		}
+	}
+
+	if len(opt.Placeholder) == 0 {
+		opt.Placeholder = defaultPlaceholder
+	}
+
+	if opt.KeepLast <= 0 {
+		opt.KeepLast = defaultKeepLast
+		if v, err := strconv.Atoi(os.Getenv("LOG_REDACT_KEEP_LAST")); err == nil && v > 0 {
+			opt.KeepLast = v
+		}
+	}
+
+	if len(opt.Salt) == 0 {
+		opt.Salt = os.Getenv("LOG_REDACT_SALT")
+	}
+
+	if opt.MaxDepth <= 0 {
+		opt.MaxDepth = defaultMaxDepth
+	}
+
+	return opt
+}
+
+func isMnemonic(s string) bool {
+	words := strings.Fields(s)
+	if len(words)%3 != 0 {
+		return false
+	}
+
+	for _, word := range words {
+		if _, ok := bip39.ReverseWordMap[word]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func patternsByName(names []string) []Pattern {
+	patterns := make([]Pattern, 0, len(names))
+	for _, name := range names {
+		for _, p := range builtinPatterns {
+			if strings.EqualFold(p.Name, name) {
+				patterns = append(patterns, p)
+			}
+		}
+	}
+
+	return patterns
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+type RootLogger interface {
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+	Printf(format string, args ...interface{})
+}
+
+// NewHook initializes a new logrus.Hook that redacts sensitive field values and
+// message parts. Add it before any hooks that ship entries elsewhere, e.g. bugsnag
+// and blob hooks. Provide a root logger to print any errors occuring during the plugin init.
+func NewHook(logger RootLogger, opt *HookOptions) logrus.Hook {
+	opt = checkHookOptions(opt)
+
+	switch opt.Mode {
+	case ModeMask, ModePartial, ModeHash:
+	default:
+		logger.Warningf("unknown redaction mode %q, using %q", opt.Mode, ModeMask)
+		opt.Mode = ModeMask
+	}
+
+	if opt.Mode == ModeHash && len(opt.Salt) == 0 {
+		logger.Warningf("redaction hashes are not salted, set LOG_REDACT_SALT")
+	}
+
+	return &hook{
+		opt:      opt,
+		logger:   logger,
+		redactor: newRedactor(opt),
+	}
+}
+
+type hook struct {
+	opt      *HookOptions
+	logger   RootLogger
+	redactor *redactor
+}
+
+func (h *hook) Levels() []logrus.Level {
+	return h.opt.Levels
+}
+
+func (h *hook) Fire(e *logrus.Entry) error {
+	if !h.opt.DisableMessage {
+		e.Message = h.redactor.redactString(e.Message)
+	}
+
+	for k, v := range e.Data {
+		if h.redactor.matchKey(k) {
+			e.Data[k], _ = h.redactor.redactKey(v)
+			continue
+		}
+
+		if redacted, changed := h.redactor.redactValue(v, 0); changed {
+			e.Data[k] = redacted
+		}
+	}
+
+	return nil
+}
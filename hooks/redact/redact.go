@@ -0,0 +1,293 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+type redactor struct {
+	opt   *HookOptions
+	exact map[string]struct{}
+	globs []string
+}
+
+func newRedactor(opt *HookOptions) *redactor {
+	r := &redactor{
+		opt:   opt,
+		exact: make(map[string]struct{}, len(opt.Keys)),
+	}
+
+	for _, key := range opt.Keys {
+		key = strings.ToLower(key)
+		if strings.ContainsAny(key, "*?[") {
+			r.globs = append(r.globs, key)
+			continue
+		}
+
+		r.exact[key] = struct{}{}
+	}
+
+	return r
+}
+
+func (r *redactor) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := r.exact[key]; ok {
+		return true
+	}
+
+	for _, glob := range r.globs {
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactKey redacts the whole value of a matching key, empty values are left as is.
+func (r *redactor) redactKey(v interface{}) (interface{}, bool) {
+	if v == nil || v == "" {
+		return v, false
+	}
+
+	return r.replace(v), true
+}
+
+// replace redacts the whole value.
+func (r *redactor) replace(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	switch r.opt.Mode {
+	case ModePartial:
+		runes := []rune(s)
+		if len(runes) <= r.opt.KeepLast*2 {
+			// too short to reveal anything
+			return r.opt.Placeholder
+		}
+
+		return strings.Repeat("*", len(runes)-r.opt.KeepLast) + string(runes[len(runes)-r.opt.KeepLast:])
+	case ModeHash:
+		mac := hmac.New(sha256.New, []byte(r.opt.Salt))
+		mac.Write([]byte(s))
+		return "sha256:" + hex.EncodeToString(mac.Sum(nil)[:8])
+	default:
+		return r.opt.Placeholder
+	}
+}
+
+// redactString replaces matches of value patterns.
+func (r *redactor) redactString(s string) string {
+	for _, p := range r.opt.Patterns {
+		s = p.Regexp.ReplaceAllStringFunc(s, func(match string) string {
+			if p.Validate != nil && !p.Validate(match) {
+				return match
+			}
+
+			return r.replace(match)
+		})
+	}
+
+	return s
+}
+
+// redactValue returns a redacted copy of the value, and whether anything has been redacted.
+// Maps, slices and structs are copied only if they contain redacted values, the original
+// values are never modified.
+func (r *redactor) redactValue(v interface{}, depth int) (interface{}, bool) {
+	if v == nil || depth > r.opt.MaxDepth {
+		return v, false
+	}
+
+	switch v := v.(type) {
+	case string:
+		redacted := r.redactString(v)
+		return redacted, redacted != v
+	case []byte:
+		// text payloads, e.g. blobs, are redacted as strings, binary ones are left alone
+		if !utf8.Valid(v) {
+			return v, false
+		}
+
+		s := string(v)
+		if redacted := r.redactString(s); redacted != s {
+			return []byte(redacted), true
+		}
+
+		return v, false
+	case error:
+		msg := v.Error()
+		if redacted := r.redactString(msg); redacted != msg {
+			return &redactedError{msg: redacted, err: v}, true
+		}
+
+		return v, false
+	case fmt.Stringer:
+		s := v.String()
+		if redacted := r.redactString(s); redacted != s {
+			return redacted, true
+		}
+
+		return v, false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		return r.redactMap(rv, depth)
+	case reflect.Slice, reflect.Array:
+		return r.redactSlice(rv, depth)
+	case reflect.Struct:
+		return r.redactStruct(rv, depth)
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return v, false
+		}
+
+		if elem := rv.Elem(); elem.Kind() == reflect.Struct {
+			return r.redactStruct(elem, depth)
+		}
+	}
+
+	return v, false
+}
+
+func (r *redactor) redactMap(rv reflect.Value, depth int) (interface{}, bool) {
+	if rv.Type().Key().Kind() != reflect.String {
+		return rv.Interface(), false
+	}
+
+	result := make(map[string]interface{}, rv.Len())
+	changed := false
+
+	iter := rv.MapRange()
+	for iter.Next() {
+		key := iter.Key().String()
+		value := iter.Value().Interface()
+
+		if r.matchKey(key) {
+			redacted, ok := r.redactKey(value)
+			result[key] = redacted
+			changed = changed || ok
+			continue
+		}
+
+		redacted, ok := r.redactValue(value, depth+1)
+		result[key] = redacted
+		changed = changed || ok
+	}
+
+	if !changed {
+		return rv.Interface(), false
+	}
+
+	return result, true
+}
+
+func (r *redactor) redactSlice(rv reflect.Value, depth int) (interface{}, bool) {
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return rv.Interface(), false
+	}
+
+	result := make([]interface{}, rv.Len())
+	changed := false
+
+	for i := 0; i < rv.Len(); i++ {
+		redacted, ok := r.redactValue(rv.Index(i).Interface(), depth+1)
+		result[i] = redacted
+		changed = changed || ok
+	}
+
+	if !changed {
+		return rv.Interface(), false
+	}
+
+	return result, true
+}
+
+// redactStruct converts the struct into a map of its exported fields, named
+// after their json tags, if any of the fields has been redacted.
+func (r *redactor) redactStruct(rv reflect.Value, depth int) (interface{}, bool) {
+	rt := rv.Type()
+	result := make(map[string]interface{}, rt.NumField())
+	changed := false
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			} else if len(tagName) > 0 {
+				name = tagName
+			}
+		}
+
+		value := rv.Field(i).Interface()
+		if r.matchKey(name) || r.matchKey(field.Name) {
+			redacted, ok := r.redactKey(value)
+			result[name] = redacted
+			changed = changed || ok
+			continue
+		}
+
+		redacted, ok := r.redactValue(value, depth+1)
+		result[name] = redacted
+		changed = changed || ok
+	}
+
+	if !changed {
+		return rv.Interface(), false
+	}
+
+	return result, true
+}
+
+type pkgErrorsStackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// redactedError hides the original error message, but keeps its stack
+// recorded by pkg/errors, so it could be still reported by the bugsnag hook.
+// It doesn't unwrap, since wrapped errors would reveal the message.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) StackTrace() pkgerrors.StackTrace {
+	var stackTrace pkgerrors.StackTrace
+	for err := e.err; err != nil; err = errors.Unwrap(err) {
+		if tracer, ok := err.(pkgErrorsStackTracer); ok {
+			stackTrace = tracer.StackTrace()
+		}
+	}
+
+	return stackTrace
+}
@@ -0,0 +1,407 @@
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Framing of messages sent over stream transports (TCP, TLS and unix stream sockets).
+type Framing int
+
+const (
+	// FramingOctetCounting prefixes each message with its length, see RFC 6587 section 3.4.1.
+	FramingOctetCounting Framing = iota
+	// FramingNonTransparent terminates each message with LF, see RFC 6587 section 3.4.2.
+	FramingNonTransparent
+)
+
+// HookOptions allows to set additional Hook options.
+type HookOptions struct {
+	// Levels enables this hook for all listed levels, defaults to all levels.
+	Levels []logrus.Level
+	// Address is "unix:///dev/log", "udp://host:514", "tcp://host:601" or "tls://host:6514".
+	// Local syslog socket is used by default.
+	Address string
+	// Format of messages, defaults to RFC5424.
+	Format Format
+	// Facility of messages, defaults to FacilityUser.
+	Facility Facility
+	// AppName is reported as APP-NAME or TAG, defaults to the program name.
+	AppName string
+	// Hostname is reported as HOSTNAME, defaults to the hostname.
+	Hostname string
+	// SDID is the structured data ID for fields in RFC5424 messages, defaults to DefaultSDID.
+	SDID string
+	// Framing of messages over stream transports, defaults to octet counting.
+	Framing Framing
+	// TLSConfig is used by tls:// addresses, defaults to system roots.
+	TLSConfig *tls.Config
+	// Timeout limits dial and write time, defaults to 5 seconds.
+	Timeout time.Duration
+	// QueueSize limits the amount of messages waiting to be sent, new messages are
+	// dropped once the limit is reached. Defaults to 1024.
+	QueueSize int
+}
+
+// localSockets are probed when the address is not set.
+var localSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+func checkHookOptions(opt *HookOptions) (*HookOptions, error) {
+	if opt == nil {
+		opt = &HookOptions{}
+	}
+
+	if len(opt.Levels) == 0 {
+		opt.Levels = logrus.AllLevels
+	}
+
+	if len(opt.Address) == 0 {
+		opt.Address = os.Getenv("LOG_SYSLOG_ADDRESS")
+	}
+
+	if opt.Format == RFC5424 {
+		switch strings.ToLower(os.Getenv("LOG_SYSLOG_FORMAT")) {
+		case "", "rfc5424":
+		case "rfc3164":
+			opt.Format = RFC3164
+		default:
+			return nil, fmt.Errorf("not a valid syslog format: %q", os.Getenv("LOG_SYSLOG_FORMAT"))
+		}
+	}
+
+	if opt.Facility == 0 {
+		opt.Facility = FacilityUser
+
+		if name := os.Getenv("LOG_SYSLOG_FACILITY"); len(name) > 0 {
+			facility, err := ParseFacility(name)
+			if err != nil {
+				return nil, err
+			}
+
+			opt.Facility = facility
+		}
+	}
+
+	if len(opt.AppName) == 0 {
+		opt.AppName = filepath.Base(os.Args[0])
+	}
+
+	if len(opt.Hostname) == 0 {
+		opt.Hostname, _ = os.Hostname()
+	}
+
+	if len(opt.SDID) == 0 {
+		opt.SDID = DefaultSDID
+	}
+
+	if opt.Timeout <= 0 {
+		opt.Timeout = 5 * time.Second
+	}
+
+	if opt.QueueSize <= 0 {
+		opt.QueueSize = 1024
+	}
+
+	return opt, nil
+}
+
+type RootLogger interface {
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+	Printf(format string, args ...interface{})
+}
+
+// NewHook initializes a new hook that writes entries to syslog. Messages are sent by a background
+// goroutine, so a slow collector doesn't block logging. Connection is established
+// on the first entry, and re-established after socket errors. Facility zero means
+// FacilityUser, since kernel messages are not expected from user space. Provide a root
+// logger to print any errors occuring during sending, it must not use this hook itself.
+func NewHook(logger RootLogger, opt *HookOptions) (*Hook, error) {
+	opt, err := checkHookOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Hook{
+		opt:    opt,
+		logger: logger,
+		header: &header{
+			format:   opt.Format,
+			facility: opt.Facility,
+			hostname: opt.Hostname,
+			appName:  opt.AppName,
+			procID:   strconv.Itoa(os.Getpid()),
+			sdID:     opt.SDID,
+		},
+		messages: make(chan []byte, opt.QueueSize),
+		flushC:   make(chan chan struct{}),
+		stopC:    make(chan struct{}),
+		doneC:    make(chan struct{}),
+	}
+
+	if len(opt.Address) > 0 {
+		u, err := url.Parse(opt.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse syslog address: %w", err)
+		}
+
+		switch u.Scheme {
+		case "unix", "unixgram":
+			h.network, h.addr = u.Scheme, u.Path
+		case "udp", "tcp", "tls":
+			h.network, h.addr = u.Scheme, u.Host
+		default:
+			return nil, fmt.Errorf("syslog address must be unix://, udp://, tcp:// or tls://, got %q", opt.Address)
+		}
+	}
+
+	go h.run()
+
+	return h, nil
+}
+
+// Hook writes entries to syslog.
+type Hook struct {
+	opt    *HookOptions
+	logger RootLogger
+	header *header
+
+	network string
+	addr    string
+
+	// conn is used by the sending goroutine only.
+	conn    net.Conn
+	connNet string
+
+	messages chan []byte
+	flushC   chan chan struct{}
+	stopC    chan struct{}
+	doneC    chan struct{}
+
+	stopOnce sync.Once
+	stopped  int32
+	dropped  uint64
+	closeErr error
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return h.opt.Levels
+}
+
+// Fire formats the entry and queues the message. Entries at Fatal and Panic
+// levels are sent before Fire returns.
+func (h *Hook) Fire(e *logrus.Entry) error {
+	if atomic.LoadInt32(&h.stopped) == 1 {
+		atomic.AddUint64(&h.dropped, 1)
+		return nil
+	}
+
+	var msg []byte
+	if h.opt.Format == RFC3164 {
+		msg = h.header.format3164(e)
+	} else {
+		msg = h.header.format5424(e)
+	}
+
+	select {
+	case h.messages <- msg:
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+		return nil
+	}
+
+	if e.Level <= logrus.FatalLevel {
+		ctx, cancel := context.WithTimeout(context.Background(), h.opt.Timeout)
+		defer cancel()
+
+		_ = h.Flush(ctx)
+	}
+
+	return nil
+}
+
+// Dropped returns the amount of messages dropped, due to full queue or failed writes.
+func (h *Hook) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// ErrClosed is returned when flushing a closed hook.
+var ErrClosed = errors.New("syslog hook is closed")
+
+// Flush sends all queued messages, blocks until done or the context is done.
+func (h *Hook) Flush(ctx context.Context) error {
+	doneC := make(chan struct{})
+
+	select {
+	case h.flushC <- doneC:
+	case <-h.doneC:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-doneC:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *Hook) run() {
+	defer close(h.doneC)
+
+	drain := func() {
+		for {
+			select {
+			case msg := <-h.messages:
+				h.send(msg)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case msg := <-h.messages:
+			h.send(msg)
+		case doneC := <-h.flushC:
+			drain()
+			close(doneC)
+		case <-h.stopC:
+			drain()
+
+			if h.conn != nil {
+				h.closeErr = h.conn.Close()
+				h.conn = nil
+			}
+
+			return
+		}
+	}
+}
+
+func (h *Hook) send(msg []byte) {
+	// retry once if nothing has been written, since the connection could be closed by the server.
+	// A partially written frame is not retried, it would corrupt octet-counted streams.
+	n, err := h.write(msg)
+	if err != nil && n == 0 {
+		_, err = h.write(msg)
+	}
+
+	if err != nil {
+		atomic.AddUint64(&h.dropped, 1)
+		h.logger.Errorf("failed to write syslog message: %v", err)
+	}
+}
+
+// ErrNoLocalSyslog is returned when no local syslog socket is found.
+var ErrNoLocalSyslog = errors.New("local syslog socket is not found")
+
+func (h *Hook) dial() error {
+	if len(h.network) == 0 {
+		for _, path := range localSockets {
+			for _, network := range []string{"unixgram", "unix"} {
+				conn, err := net.DialTimeout(network, path, h.opt.Timeout)
+				if err == nil {
+					h.conn, h.connNet = conn, network
+					return nil
+				}
+			}
+		}
+
+		return ErrNoLocalSyslog
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	switch h.network {
+	case "tls":
+		dialer := &net.Dialer{Timeout: h.opt.Timeout}
+		conn, err = tls.DialWithDialer(dialer, "tcp", h.addr, h.opt.TLSConfig)
+	case "unix":
+		// the socket type is unknown, /dev/log is usually a datagram one
+		if conn, err = net.DialTimeout("unixgram", h.addr, h.opt.Timeout); err == nil {
+			h.conn, h.connNet = conn, "unixgram"
+			return nil
+		}
+
+		conn, err = net.DialTimeout("unix", h.addr, h.opt.Timeout)
+	default:
+		conn, err = net.DialTimeout(h.network, h.addr, h.opt.Timeout)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	h.conn, h.connNet = conn, h.network
+
+	return nil
+}
+
+// write sends the message, dialing if needed. Connection is dropped on failures,
+// so the next message starts a new stream.
+func (h *Hook) write(msg []byte) (int, error) {
+	if h.conn == nil {
+		if err := h.dial(); err != nil {
+			return 0, err
+		}
+	}
+
+	var data []byte
+	switch {
+	case h.connNet == "udp" || h.connNet == "unixgram":
+		// one message per datagram
+		data = msg
+	case h.opt.Framing == FramingNonTransparent:
+		data = append(msg, '\n')
+	default:
+		data = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	_ = h.conn.SetWriteDeadline(time.Now().Add(h.opt.Timeout))
+
+	n, err := h.conn.Write(data)
+	if err != nil {
+		_ = h.conn.Close()
+		h.conn = nil
+
+		return n, err
+	}
+
+	return n, nil
+}
+
+// Close sends queued messages and closes the connection, entries fired later are dropped.
+func (h *Hook) Close() error {
+	err := ErrClosed
+
+	h.stopOnce.Do(func() {
+		atomic.StoreInt32(&h.stopped, 1)
+		close(h.stopC)
+		<-h.doneC
+
+		err = h.closeErr
+	})
+
+	return err
+}
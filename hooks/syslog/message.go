@@ -0,0 +1,254 @@
+package syslog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Format of syslog messages.
+type Format int
+
+const (
+	// RFC5424 is the modern syslog format, fields are sent as structured data.
+	RFC5424 Format = iota
+	// RFC3164 is the BSD syslog format, fields are appended to the message.
+	RFC3164
+)
+
+// Facility of syslog messages.
+type Facility int
+
+// Syslog facilities, see RFC 5424 section 6.2.1.
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	_
+	_
+	_
+	_
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+var facilityNames = map[string]Facility{
+	"kern":     FacilityKern,
+	"user":     FacilityUser,
+	"mail":     FacilityMail,
+	"daemon":   FacilityDaemon,
+	"auth":     FacilityAuth,
+	"syslog":   FacilitySyslog,
+	"lpr":      FacilityLPR,
+	"news":     FacilityNews,
+	"uucp":     FacilityUUCP,
+	"cron":     FacilityCron,
+	"authpriv": FacilityAuthPriv,
+	"ftp":      FacilityFTP,
+	"local0":   FacilityLocal0,
+	"local1":   FacilityLocal1,
+	"local2":   FacilityLocal2,
+	"local3":   FacilityLocal3,
+	"local4":   FacilityLocal4,
+	"local5":   FacilityLocal5,
+	"local6":   FacilityLocal6,
+	"local7":   FacilityLocal7,
+}
+
+// ParseFacility takes a facility name, e.g. "local0", and returns the facility.
+func ParseFacility(name string) (Facility, error) {
+	if f, ok := facilityNames[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+
+	return 0, fmt.Errorf("not a valid syslog facility: %q", name)
+}
+
+// Severity maps logrus levels to syslog severities, it's also used by GELF and journald hooks.
+func Severity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel:
+		return 1 // alert
+	case logrus.FatalLevel:
+		return 2 // critical
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	default:
+		return 7 // debug
+	}
+}
+
+// DefaultSDID is the structured data ID of suplog fields,
+// 32473 is the enterprise number reserved for examples.
+const DefaultSDID = "suplog@32473"
+
+// header carries message parts that don't depend on the entry.
+type header struct {
+	format   Format
+	facility Facility
+	hostname string
+	appName  string
+	procID   string
+	sdID     string
+}
+
+func (h *header) format5424(e *logrus.Entry) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s - ",
+		int(h.facility)*8+Severity(e.Level),
+		e.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		nilValue(h.hostname, 255),
+		nilValue(h.appName, 48),
+		nilValue(h.procID, 128),
+	)
+
+	if len(e.Data) == 0 {
+		b.WriteByte('-')
+	} else {
+		b.WriteByte('[')
+		b.WriteString(h.sdID)
+
+		for _, k := range sortedKeys(e.Data) {
+			b.WriteByte(' ')
+			b.WriteString(paramName(k))
+			b.WriteString(`="`)
+			writeParamValue(&b, fieldValue(e.Data[k]))
+			b.WriteByte('"')
+		}
+
+		b.WriteByte(']')
+	}
+
+	if len(e.Message) > 0 {
+		b.WriteByte(' ')
+		b.WriteString(e.Message)
+	}
+
+	return []byte(b.String())
+}
+
+func (h *header) format3164(e *logrus.Entry) []byte {
+	var b strings.Builder
+
+	tag := h.appName
+	if len(tag) > 32 {
+		tag = tag[:32]
+	}
+
+	fmt.Fprintf(&b, "<%d>%s %s %s[%s]: ",
+		int(h.facility)*8+Severity(e.Level),
+		e.Time.Format(time.Stamp),
+		nilValue(h.hostname, 255),
+		tag,
+		h.procID,
+	)
+
+	// multi-line messages are not supported by the format
+	b.WriteString(strings.ReplaceAll(e.Message, "\n", " "))
+
+	for _, k := range sortedKeys(e.Data) {
+		b.WriteByte(' ')
+		b.WriteString(paramName(k))
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(fieldValue(e.Data[k])))
+	}
+
+	return []byte(b.String())
+}
+
+func sortedKeys(fields logrus.Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// nilValue returns "-" for empty header fields, and keeps only printable ASCII.
+func nilValue(s string, maxLen int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+
+		return r
+	}, s)
+
+	if len(s) == 0 {
+		return "-"
+	} else if len(s) > maxLen {
+		return s[:maxLen]
+	}
+
+	return s
+}
+
+// paramName keeps only the characters allowed in SD-NAME, up to 32 of them.
+func paramName(s string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+
+		return r
+	}, s)
+
+	if len(name) == 0 {
+		return "_"
+	} else if len(name) > 32 {
+		return name[:32]
+	}
+
+	return name
+}
+
+// writeParamValue escapes '"', '\' and ']' as required by RFC 5424.
+func writeParamValue(b *strings.Builder, s string) {
+	for _, r := range s {
+		switch r {
+		case '"', '\\', ']':
+			b.WriteByte('\\')
+		}
+
+		b.WriteRune(r)
+	}
+}
+
+func fieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,300 @@
+package syslog
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	syslogHook "github.com/InjectiveLabs/suplog/hooks/syslog"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+func newLogger(t *testing.T, opt *syslogHook.HookOptions) (suplog.Logger, *syslogHook.Hook) {
+	opt.AppName = "app"
+	opt.Hostname = "host"
+
+	hook, err := syslogHook.NewHook(suplog.DefaultLogger, opt)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = hook.Close()
+	})
+
+	return suplog.NewLogger(io.Discard, nil, hook), hook
+}
+
+func readPacket(t *testing.T, conn net.PacketConn) string {
+	buf := make([]byte, 65536)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	return string(buf[:n])
+}
+
+// serveStream accepts connections and sends octet-counted messages into the channel.
+func serveStream(lis net.Listener, messages chan<- string, conns chan<- net.Conn) {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+
+		if conns != nil {
+			conns <- conn
+		}
+
+		go func() {
+			defer conn.Close()
+
+			r := bufio.NewReader(conn)
+			for {
+				size, err := r.ReadString(' ')
+				if err != nil {
+					return
+				}
+
+				n, err := strconv.Atoi(strings.TrimSpace(size))
+				if err != nil {
+					return
+				}
+
+				msg := make([]byte, n)
+				if _, err := io.ReadFull(r, msg); err != nil {
+					return
+				}
+
+				messages <- string(msg)
+			}
+		}()
+	}
+}
+
+func receive(t *testing.T, messages <-chan string) string {
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+		return ""
+	}
+}
+
+var rfc5424 = regexp.MustCompile(`^<(\d+)>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) host app \d+ - (.+)$`)
+
+func TestSyslogHookUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	log, _ := newLogger(t, &syslogHook.HookOptions{
+		Address:  "udp://" + conn.LocalAddr().String(),
+		Facility: syslogHook.FacilityLocal0,
+	})
+
+	log.WithFields(suplog.Fields{
+		"user":  "alice",
+		"quote": `a"b]c`,
+	}).WithError(errors.New("fail")).Error("failed")
+
+	m := rfc5424.FindStringSubmatch(readPacket(t, conn))
+	require.NotNil(t, m)
+	require.Equal(t, strconv.Itoa(16*8+3), m[1])
+	require.Equal(t, `[suplog@32473 error="fail" quote="a\"b\]c" user="alice"] failed`, m[3])
+
+	log.Info("no fields")
+	m = rfc5424.FindStringSubmatch(readPacket(t, conn))
+	require.NotNil(t, m)
+	require.Equal(t, strconv.Itoa(16*8+6), m[1])
+	require.Equal(t, "- no fields", m[3])
+}
+
+func TestSyslogHookRFC3164(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	log, _ := newLogger(t, &syslogHook.HookOptions{
+		Address: "udp://" + conn.LocalAddr().String(),
+		Format:  syslogHook.RFC3164,
+	})
+
+	log.WithField("user", "alice bob").Warning("line one\nline two")
+
+	msg := readPacket(t, conn)
+	require.Regexp(t, `^<12>\w{3} [ \d]\d \d\d:\d\d:\d\d host app\[\d+\]: line one line two user="alice bob"$`, msg)
+}
+
+func TestSyslogHookTCPReconnect(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	messages := make(chan string, 100)
+	conns := make(chan net.Conn, 10)
+	go serveStream(lis, messages, conns)
+
+	log, _ := newLogger(t, &syslogHook.HookOptions{
+		Address: "tcp://" + lis.Addr().String(),
+	})
+
+	log.Info("first")
+	require.True(t, strings.HasSuffix(receive(t, messages), " - first"))
+
+	// server drops the connection, the hook has to reconnect
+	(<-conns).Close()
+
+	require.Eventually(t, func() bool {
+		log.Info("again")
+
+		select {
+		case <-conns:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.True(t, strings.HasSuffix(receive(t, messages), " - again"))
+}
+
+func TestSyslogHookSlowCollector(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	// collector accepts connections, but never reads
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+
+			conns = append(conns, conn)
+		}
+	}()
+
+	log, hook := newLogger(t, &syslogHook.HookOptions{
+		Address:   "tcp://" + lis.Addr().String(),
+		QueueSize: 4,
+	})
+
+	payload := strings.Repeat("x", 64*1024)
+	start := time.Now()
+	for i := 0; i < 300; i++ {
+		log.WithField("payload", payload).Info("bulk")
+	}
+
+	// a blocked write would take the whole timeout of 5 seconds
+	require.Less(t, time.Since(start), 3*time.Second)
+	require.NotZero(t, hook.Dropped())
+}
+
+func TestSyslogHookTLS(t *testing.T) {
+	cert := newCertificate(t)
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})
+	require.NoError(t, err)
+	defer lis.Close()
+
+	messages := make(chan string, 10)
+	go serveStream(lis, messages, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+
+	log, _ := newLogger(t, &syslogHook.HookOptions{
+		Address: "tls://" + lis.Addr().String(),
+		TLSConfig: &tls.Config{
+			RootCAs:    roots,
+			ServerName: "localhost",
+		},
+	})
+
+	log.Info("secure")
+	require.True(t, strings.HasSuffix(receive(t, messages), " - secure"))
+}
+
+func TestSyslogHookUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+
+	conn, err := net.ListenPacket("unixgram", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	defer os.Remove(path)
+
+	log, _ := newLogger(t, &syslogHook.HookOptions{
+		Address: "unix://" + path,
+	})
+
+	log.Info("local")
+	require.True(t, strings.HasSuffix(readPacket(t, conn), " - local"))
+}
+
+func TestSyslogHookInvalidOptions(t *testing.T) {
+	_, err := syslogHook.NewHook(suplog.DefaultLogger, &syslogHook.HookOptions{
+		Address: "http://localhost:514",
+	})
+	require.Error(t, err)
+
+	_, err = syslogHook.ParseFacility("local9")
+	require.Error(t, err)
+}
+
+func newCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+}
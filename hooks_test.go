@@ -0,0 +1,53 @@
+package suplog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// fieldHook adds a field to all entries.
+type fieldHook struct {
+	key, value string
+}
+
+func (h *fieldHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *fieldHook) Fire(e *logrus.Entry) error {
+	e.Data[h.key] = h.value
+	return nil
+}
+
+func TestRegisterDefaultHook(t *testing.T) {
+	defaultHooksMux.Lock()
+	registered := defaultHooks
+	defaultHooksMux.Unlock()
+	t.Cleanup(func() {
+		defaultHooksMux.Lock()
+		defaultHooks = registered
+		defaultHooksMux.Unlock()
+	})
+
+	RegisterDefaultHook(func(hookLogger Logger) Hook {
+		require.NotNil(t, hookLogger)
+		return &fieldHook{key: "trace_id", value: "abc"}
+	})
+	RegisterDefaultHook(func(Logger) Hook {
+		return nil
+	})
+
+	var recorder strings.Builder
+	l := &suplogger{writer: &recorder}
+	l.Info("traced")
+
+	require.Contains(t, recorder.String(), `"trace_id":"abc"`)
+
+	// not added to loggers constructed by NewLogger
+	recorder.Reset()
+	NewLogger(&recorder, new(TextFormatter)).Info("untraced")
+	require.NotContains(t, recorder.String(), "trace_id")
+}
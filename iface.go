@@ -81,9 +81,17 @@ type LoggerConfigurator interface {
 	CallerName() string
 }
 
+// LevelRulesConfigurator is implemented by loggers supporting per-package and per-module
+// level rules, besides LoggerConfigurator.
+type LevelRulesConfigurator interface {
+	SetLevelRules(rules *LevelRules)
+	GetLevelRules() *LevelRules
+}
+
 var (
-	_ StdLogger = &suplogger{}
-	_ StdLogger = &Entry{}
+	_ LevelRulesConfigurator = &suplogger{}
+	_ StdLogger              = &suplogger{}
+	_ StdLogger              = &Entry{}
 )
 
 // StdLogger is what your suplog-enabled library should take, that way
@@ -0,0 +1,321 @@
+// Package levelctl provides runtime control of logger levels, over HTTP and signals.
+package levelctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+// ControllerOptions allows to set additional Controller options.
+type ControllerOptions struct {
+	// Cycle lists levels that signals cycle through, from least to most verbose.
+	Cycle []suplog.Level
+	// MaxTTL limits the TTL that could be requested over HTTP, zero means no limit.
+	MaxTTL time.Duration
+}
+
+func checkControllerOptions(opt *ControllerOptions) *ControllerOptions {
+	if opt == nil {
+		opt = &ControllerOptions{}
+	}
+
+	if len(opt.Cycle) == 0 {
+		opt.Cycle = []suplog.Level{
+			suplog.ErrorLevel,
+			suplog.WarnLevel,
+			suplog.InfoLevel,
+			suplog.DebugLevel,
+			suplog.TraceLevel,
+		}
+	}
+
+	return opt
+}
+
+// NewController returns a new level controller for the logger,
+// suplog.DefaultLogger is used if logger is nil. Level rules are changed
+// only if the logger implements suplog.LevelRulesConfigurator.
+func NewController(logger suplog.LoggerConfigurator, opt *ControllerOptions) *Controller {
+	if logger == nil {
+		logger = suplog.DefaultLogger
+	}
+
+	rules, _ := logger.(suplog.LevelRulesConfigurator)
+
+	return &Controller{
+		opt:       checkControllerOptions(opt),
+		logger:    logger,
+		rules:     rules,
+		afterFunc: time.AfterFunc,
+	}
+}
+
+// NewHandler returns an http.Handler that reports the current levels on GET,
+// and changes them on PUT or POST. See Controller.ServeHTTP for details.
+func NewHandler(logger suplog.LoggerConfigurator, opt *ControllerOptions) http.Handler {
+	return NewController(logger, opt)
+}
+
+// Controller changes logger levels at runtime, optionally restoring
+// the previous levels after a TTL.
+type Controller struct {
+	opt    *ControllerOptions
+	logger suplog.LoggerConfigurator
+	// rules is nil if the logger doesn't support level rules
+	rules suplog.LevelRulesConfigurator
+
+	mux       sync.Mutex
+	restore   *savedLevels
+	restoreAt time.Time
+	timer     *time.Timer
+	// generation is incremented by every change, so a timer that fired
+	// while the change was applied doesn't restore the levels.
+	generation uint64
+	afterFunc  func(d time.Duration, f func()) *time.Timer
+}
+
+type savedLevels struct {
+	level suplog.Level
+	rules *suplog.LevelRules
+}
+
+// State describes current levels of the logger.
+type State struct {
+	Level     string     `json:"level"`
+	Rules     string     `json:"rules,omitempty"`
+	RestoreAt *time.Time `json:"restoreAt,omitempty"`
+}
+
+// Change describes a level change request. Nil fields are left unchanged.
+type Change struct {
+	Level *suplog.Level
+	Rules *suplog.LevelRules
+	// ResetRules removes level rules, if Rules is not set.
+	ResetRules bool
+	// TTL restores the levels that were set before the change, once elapsed.
+	// Subsequent changes with TTL restore the levels before the first one.
+	TTL time.Duration
+}
+
+// State returns current levels of the logger.
+func (c *Controller) State() State {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.state()
+}
+
+func (c *Controller) state() State {
+	state := State{
+		Level: c.logger.GetLevel().String(),
+		Rules: c.getRules().String(),
+	}
+
+	if c.timer != nil {
+		restoreAt := c.restoreAt
+		state.RestoreAt = &restoreAt
+	}
+
+	return state
+}
+
+// Apply changes logger levels.
+func (c *Controller) Apply(change Change) State {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+
+	c.generation++
+
+	if change.TTL > 0 {
+		if c.restore == nil {
+			c.restore = &savedLevels{
+				level: c.logger.GetLevel(),
+				rules: c.getRules(),
+			}
+		}
+
+		generation := c.generation
+		c.restoreAt = time.Now().Add(change.TTL)
+		c.timer = c.afterFunc(change.TTL, func() {
+			c.restoreLevels(generation)
+		})
+	} else {
+		// permanent change, nothing to restore
+		c.restore = nil
+	}
+
+	if change.Rules != nil {
+		c.setRules(change.Rules)
+	} else if change.ResetRules {
+		c.setRules(nil)
+	}
+
+	if change.Level != nil {
+		c.logger.SetLevel(*change.Level)
+	}
+
+	return c.state()
+}
+
+func (c *Controller) restoreLevels(generation uint64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	// the timer is stale, if levels have been changed since it was started
+	if c.restore == nil || c.generation != generation {
+		return
+	}
+
+	c.setRules(c.restore.rules)
+	c.logger.SetLevel(c.restore.level)
+	c.restore = nil
+	c.timer = nil
+}
+
+func (c *Controller) getRules() *suplog.LevelRules {
+	if c.rules == nil {
+		return nil
+	}
+
+	return c.rules.GetLevelRules()
+}
+
+func (c *Controller) setRules(rules *suplog.LevelRules) {
+	if c.rules != nil {
+		c.rules.SetLevelRules(rules)
+	}
+}
+
+// Cycle moves the logger level by step positions in the configured cycle,
+// positive steps are more verbose. Cycle wraps around on both ends.
+func (c *Controller) Cycle(step int) State {
+	c.mux.Lock()
+	current := c.logger.GetLevel()
+	c.mux.Unlock()
+
+	idx := -1
+	for i, lvl := range c.opt.Cycle {
+		if lvl == current {
+			idx = i
+			break
+		}
+	}
+
+	n := len(c.opt.Cycle)
+	if idx < 0 {
+		// not in cycle, start from the nearest end
+		idx = 0
+		if step < 0 {
+			idx = n - 1
+		}
+	} else {
+		idx = ((idx+step)%n + n) % n
+	}
+
+	level := c.opt.Cycle[idx]
+
+	return c.Apply(Change{
+		Level: &level,
+	})
+}
+
+// ServeHTTP reports the current levels as JSON on GET. On PUT and POST it
+// changes the levels, accepting either a JSON body or form values with keys
+// "level", "rules" and "ttl", e.g. {"level":"debug","ttl":"10m"}.
+// An empty rules value removes the level rules.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		writeState(w, http.StatusOK, c.State())
+	case http.MethodPut, http.MethodPost:
+		change, err := c.parseChange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeState(w, http.StatusOK, c.Apply(change))
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type changeRequest struct {
+	Level string  `json:"level"`
+	Rules *string `json:"rules"`
+	TTL   string  `json:"ttl"`
+}
+
+func (c *Controller) parseChange(r *http.Request) (change Change, err error) {
+	var req changeRequest
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return change, fmt.Errorf("failed to decode request: %w", err)
+		}
+	} else {
+		if err = r.ParseForm(); err != nil {
+			return change, fmt.Errorf("failed to parse form: %w", err)
+		}
+
+		req.Level = r.Form.Get("level")
+		req.TTL = r.Form.Get("ttl")
+		if _, ok := r.Form["rules"]; ok {
+			rules := r.Form.Get("rules")
+			req.Rules = &rules
+		}
+	}
+
+	if len(req.Level) > 0 {
+		level, err := suplog.ParseLevel(req.Level)
+		if err != nil {
+			return change, err
+		}
+
+		change.Level = &level
+	}
+
+	if req.Rules != nil {
+		if len(strings.TrimSpace(*req.Rules)) == 0 {
+			change.ResetRules = true
+		} else if change.Rules, err = suplog.ParseLevelRules(*req.Rules); err != nil {
+			return change, err
+		}
+	}
+
+	if change.Level == nil && change.Rules == nil && !change.ResetRules {
+		return change, fmt.Errorf("either level or rules must be provided")
+	} else if c.rules == nil && (change.Rules != nil || change.ResetRules) {
+		return change, fmt.Errorf("level rules are not supported by the logger")
+	}
+
+	if len(req.TTL) > 0 {
+		if change.TTL, err = time.ParseDuration(req.TTL); err != nil {
+			return change, fmt.Errorf("invalid ttl: %w", err)
+		} else if change.TTL < 0 {
+			return change, fmt.Errorf("invalid ttl: must be positive")
+		} else if c.opt.MaxTTL > 0 && change.TTL > c.opt.MaxTTL {
+			return change, fmt.Errorf("invalid ttl: must not exceed %s", c.opt.MaxTTL)
+		}
+	}
+
+	return change, nil
+}
+
+func writeState(w http.ResponseWriter, status int, state State) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(state)
+}
@@ -0,0 +1,156 @@
+package levelctl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+func TestHandler(t *testing.T) {
+	do := func(t *testing.T, h http.Handler, r *http.Request) (int, State) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		var state State
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&state))
+		}
+
+		return w.Code, state
+	}
+
+	t.Run("get and set level", func(t *testing.T) {
+		l := suplog.NewLogger(&strings.Builder{}, nil).(suplog.LoggerConfigurator)
+		h := NewHandler(l, nil)
+
+		code, state := do(t, h, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "debug", state.Level)
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"level":"warn"}`))
+		req.Header.Set("Content-Type", "application/json")
+		code, state = do(t, h, req)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "warning", state.Level)
+		require.Nil(t, state.RestoreAt)
+		require.Equal(t, suplog.WarnLevel, l.GetLevel())
+	})
+
+	t.Run("set rules with form", func(t *testing.T) {
+		l := suplog.NewLogger(&strings.Builder{}, nil).(suplog.LoggerConfigurator)
+		h := NewHandler(l, nil)
+
+		form := url.Values{"rules": {"accounts=trace,default=info"}}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		code, state := do(t, h, req)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "info", state.Level)
+		require.Equal(t, "accounts=trace,default=info", state.Rules)
+
+		code, state = do(t, h, httptest.NewRequest(http.MethodPost, "/?rules=", nil))
+		require.Equal(t, http.StatusOK, code)
+		require.Empty(t, state.Rules)
+		require.Nil(t, l.(suplog.LevelRulesConfigurator).GetLevelRules())
+	})
+
+	t.Run("restore after ttl", func(t *testing.T) {
+		l := suplog.NewLogger(&strings.Builder{}, nil).(suplog.LoggerConfigurator)
+		l.SetLevel(suplog.InfoLevel)
+		h := NewHandler(l, nil)
+
+		code, state := do(t, h, httptest.NewRequest(http.MethodPut, "/?level=trace&ttl=50ms", nil))
+		require.Equal(t, http.StatusOK, code)
+		require.NotNil(t, state.RestoreAt)
+		require.Equal(t, suplog.TraceLevel, l.GetLevel())
+
+		// a subsequent change restores the original level as well
+		code, _ = do(t, h, httptest.NewRequest(http.MethodPut, "/?level=debug&ttl=50ms", nil))
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, suplog.DebugLevel, l.GetLevel())
+
+		require.Eventually(t, func() bool {
+			return l.GetLevel() == suplog.InfoLevel
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("stale timer doesn't restore", func(t *testing.T) {
+		l := suplog.NewLogger(&strings.Builder{}, nil).(suplog.LoggerConfigurator)
+		l.SetLevel(suplog.InfoLevel)
+		c := NewController(l, nil)
+
+		// timers never fire, callbacks are called by the test instead,
+		// as if the first timer fired while the second change was applied
+		var callbacks []func()
+		c.afterFunc = func(d time.Duration, f func()) *time.Timer {
+			callbacks = append(callbacks, f)
+			return time.AfterFunc(time.Hour, func() {})
+		}
+
+		trace, debug := suplog.TraceLevel, suplog.DebugLevel
+		c.Apply(Change{Level: &trace, TTL: time.Minute})
+		state := c.Apply(Change{Level: &debug, TTL: time.Minute})
+		require.Len(t, callbacks, 2)
+
+		callbacks[0]()
+		require.Equal(t, suplog.DebugLevel, l.GetLevel())
+		require.Equal(t, state, c.State())
+
+		callbacks[1]()
+		require.Equal(t, suplog.InfoLevel, l.GetLevel())
+		require.Nil(t, c.State().RestoreAt)
+	})
+
+	t.Run("logger without level rules", func(t *testing.T) {
+		// hides level rules methods of the logger
+		l := struct {
+			suplog.LoggerConfigurator
+		}{suplog.NewLogger(&strings.Builder{}, nil).(suplog.LoggerConfigurator)}
+		h := NewHandler(l, nil)
+
+		code, _ := do(t, h, httptest.NewRequest(http.MethodPut, "/?rules=accounts=trace", nil))
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, state := do(t, h, httptest.NewRequest(http.MethodPut, "/?level=warn", nil))
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "warning", state.Level)
+		require.Empty(t, state.Rules)
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		h := NewHandler(suplog.NewLogger(&strings.Builder{}, nil).(suplog.LoggerConfigurator), &ControllerOptions{
+			MaxTTL: time.Minute,
+		})
+
+		code, _ := do(t, h, httptest.NewRequest(http.MethodPut, "/?level=loud", nil))
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = do(t, h, httptest.NewRequest(http.MethodPut, "/", nil))
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = do(t, h, httptest.NewRequest(http.MethodPut, "/?level=debug&ttl=1h", nil))
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = do(t, h, httptest.NewRequest(http.MethodDelete, "/", nil))
+		require.Equal(t, http.StatusMethodNotAllowed, code)
+	})
+}
+
+func TestCycle(t *testing.T) {
+	l := suplog.NewLogger(&strings.Builder{}, nil).(suplog.LoggerConfigurator)
+	l.SetLevel(suplog.InfoLevel)
+	c := NewController(l, nil)
+
+	require.Equal(t, "debug", c.Cycle(1).Level)
+	require.Equal(t, "trace", c.Cycle(1).Level)
+	require.Equal(t, "error", c.Cycle(1).Level)
+	require.Equal(t, "trace", c.Cycle(-1).Level)
+}
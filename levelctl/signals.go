@@ -0,0 +1,38 @@
+//go:build !windows
+
+package levelctl
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchSignals cycles logger levels on signals: SIGUSR1 makes the logger
+// more verbose, SIGUSR2 makes it less verbose. Call stop to unsubscribe.
+func (c *Controller) WatchSignals() (stop func()) {
+	sigC := make(chan os.Signal, 1)
+	doneC := make(chan struct{})
+	signal.Notify(sigC, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for {
+			select {
+			case <-doneC:
+				return
+			case sig := <-sigC:
+				switch sig {
+				case syscall.SIGUSR1:
+					c.Cycle(1)
+				case syscall.SIGUSR2:
+					c.Cycle(-1)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigC)
+		close(doneC)
+	}
+}
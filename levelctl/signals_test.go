@@ -0,0 +1,32 @@
+//go:build !windows
+
+package levelctl
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+func TestWatchSignals(t *testing.T) {
+	l := suplog.NewLogger(&strings.Builder{}, nil).(suplog.LoggerConfigurator)
+	l.SetLevel(suplog.InfoLevel)
+
+	stop := NewController(l, nil).WatchSignals()
+	defer stop()
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	require.Eventually(t, func() bool {
+		return l.GetLevel() == suplog.DebugLevel
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	require.Eventually(t, func() bool {
+		return l.GetLevel() == suplog.InfoLevel
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,6 @@
+package levelctl
+
+// WatchSignals is a no-op on Windows, since there are no SIGUSR1 and SIGUSR2.
+func (c *Controller) WatchSignals() (stop func()) {
+	return func() {}
+}
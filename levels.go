@@ -0,0 +1,299 @@
+package suplog
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+// ModuleKey is the field name that is matched against level rules,
+// before falling back to the caller package name.
+const ModuleKey = "module"
+
+// LevelRules is a table of level overrides keyed by caller package or
+// by the value of "module" field. Package patterns ending with "*" match
+// any package with that prefix, e.g. "github.com/ourorg/chain/*" matches
+// "github.com/ourorg/chain" and all packages below it. When several patterns
+// match, the most specific (longest) one wins, exact match wins over a glob.
+type LevelRules struct {
+	// Default level applies when no rule matches. Logger level is
+	// used as default, if not set.
+	Default *Level
+
+	rules []levelRule
+}
+
+type levelRule struct {
+	pattern string
+	prefix  string
+	glob    bool
+	level   Level
+}
+
+// ParseLevelRules parses a comma-separated rule table, such as
+// "github.com/ourorg/chain/*=trace,accounts=debug,default=info".
+// Keys "default" and "*" set the default level.
+func ParseLevelRules(spec string) (*LevelRules, error) {
+	rules := &LevelRules{}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		idx := strings.LastIndexByte(part, '=')
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid level rule %q: expected pattern=level", part)
+		}
+
+		pattern := strings.TrimSpace(part[:idx])
+		level, err := ParseLevel(strings.TrimSpace(part[idx+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid level rule %q: %w", part, err)
+		}
+
+		rules.Set(pattern, level)
+	}
+
+	return rules, nil
+}
+
+// Set adds a level override for the pattern, replacing an existing one.
+func (r *LevelRules) Set(pattern string, level Level) {
+	if pattern == "default" || pattern == "*" {
+		r.Default = &level
+		return
+	}
+
+	rule := levelRule{
+		pattern: pattern,
+		prefix:  pattern,
+		level:   level,
+	}
+
+	if strings.HasSuffix(pattern, "*") {
+		rule.glob = true
+		rule.prefix = strings.TrimSuffix(strings.TrimSuffix(pattern, "*"), "/")
+	}
+
+	for i := range r.rules {
+		if r.rules[i].pattern == pattern {
+			r.rules[i] = rule
+			return
+		}
+	}
+
+	r.rules = append(r.rules, rule)
+
+	// most specific rules go first, exact matches before globs of same prefix
+	sort.SliceStable(r.rules, func(i, j int) bool {
+		if len(r.rules[i].prefix) != len(r.rules[j].prefix) {
+			return len(r.rules[i].prefix) > len(r.rules[j].prefix)
+		}
+
+		return !r.rules[i].glob && r.rules[j].glob
+	})
+}
+
+// Match returns the level set for the package or module name.
+func (r *LevelRules) Match(name string) (Level, bool) {
+	if r == nil {
+		return 0, false
+	}
+
+	for _, rule := range r.rules {
+		if rule.match(name) {
+			return rule.level, true
+		}
+	}
+
+	return 0, false
+}
+
+// MaxLevel returns the most verbose level among all rules, including default.
+func (r *LevelRules) MaxLevel(fallback Level) Level {
+	if r == nil {
+		return fallback
+	}
+
+	max := fallback
+	if r.Default != nil {
+		max = *r.Default
+	}
+
+	for _, rule := range r.rules {
+		if rule.level > max {
+			max = rule.level
+		}
+	}
+
+	return max
+}
+
+// String formats the rules back into a rule table.
+func (r *LevelRules) String() string {
+	if r == nil {
+		return ""
+	}
+
+	parts := make([]string, 0, len(r.rules)+1)
+	for _, rule := range r.rules {
+		parts = append(parts, rule.pattern+"="+rule.level.String())
+	}
+
+	if r.Default != nil {
+		parts = append(parts, "default="+r.Default.String())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (r *LevelRules) clone() *LevelRules {
+	out := &LevelRules{
+		rules: make([]levelRule, len(r.rules)),
+	}
+	copy(out.rules, r.rules)
+
+	if r.Default != nil {
+		lvl := *r.Default
+		out.Default = &lvl
+	}
+
+	return out
+}
+
+func (r levelRule) match(name string) bool {
+	if !r.glob {
+		return name == r.prefix
+	}
+
+	if len(r.prefix) == 0 || name == r.prefix {
+		return true
+	}
+
+	if strings.HasSuffix(r.pattern, "/*") {
+		return strings.HasPrefix(name, r.prefix+"/")
+	}
+
+	return strings.HasPrefix(name, r.prefix)
+}
+
+// levelRouter keeps level rules of a logger and its copies.
+type levelRouter struct {
+	mux   sync.RWMutex
+	rules *LevelRules
+	base  Level
+	cache map[string]Level
+}
+
+func newLevelRouter() *levelRouter {
+	return &levelRouter{}
+}
+
+func (r *levelRouter) active() bool {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return r.rules != nil
+}
+
+// set replaces the rules, returns the level that logrus should filter by.
+func (r *levelRouter) set(rules *LevelRules, base Level) Level {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if rules != nil {
+		if rules.Default != nil {
+			base = *rules.Default
+		}
+
+		rules = rules.clone()
+	}
+
+	r.rules = rules
+	r.base = base
+	r.cache = make(map[string]Level)
+
+	return rules.MaxLevel(base)
+}
+
+// setBase updates the default level, returns the level that logrus should filter by.
+func (r *levelRouter) setBase(base Level) Level {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.rules == nil {
+		return base
+	}
+
+	r.base = base
+	r.rules = r.rules.clone()
+	r.rules.Default = &base
+	r.cache = make(map[string]Level)
+
+	return r.rules.MaxLevel(base)
+}
+
+func (r *levelRouter) getRules() *LevelRules {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	if r.rules == nil {
+		return nil
+	}
+
+	return r.rules.clone()
+}
+
+func (r *levelRouter) getBase() Level {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return r.base
+}
+
+// levelFor resolves the level using module name first, then the caller package.
+// The caller frame is looked up in the stack, unless provided.
+func (r *levelRouter) levelFor(module string, caller runtime.Frame, stack stackcache.StackCache) Level {
+	r.mux.RLock()
+	rules, base := r.rules, r.base
+	r.mux.RUnlock()
+
+	if len(module) > 0 {
+		if lvl, ok := rules.Match(module); ok {
+			return lvl
+		}
+	}
+
+	if len(caller.Function) == 0 {
+		caller = stack.GetCaller()
+	}
+
+	pkg := stackcache.GetPackageName(caller.Function)
+
+	r.mux.RLock()
+	lvl, ok := r.cache[pkg]
+	r.mux.RUnlock()
+
+	if ok {
+		return lvl
+	}
+
+	lvl, ok = rules.Match(pkg)
+	if !ok {
+		lvl = base
+	}
+
+	r.mux.Lock()
+	if r.rules == rules {
+		r.cache[pkg] = lvl
+	}
+	r.mux.Unlock()
+
+	return lvl
+}
@@ -0,0 +1,109 @@
+package suplog_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/InjectiveLabs/suplog"
+	"github.com/InjectiveLabs/suplog/wrapped-test"
+)
+
+func TestParseLevelRules(t *testing.T) {
+	rules, err := ParseLevelRules("github.com/ourorg/chain/*=trace, github.com/ourorg/chain/p2p=warn,accounts=debug,default=info")
+	require.NoError(t, err)
+	require.NotNil(t, rules.Default)
+	require.Equal(t, InfoLevel, *rules.Default)
+	require.Equal(t, TraceLevel, rules.MaxLevel(InfoLevel))
+
+	lvl, ok := rules.Match("github.com/ourorg/chain")
+	require.True(t, ok)
+	require.Equal(t, TraceLevel, lvl)
+
+	lvl, ok = rules.Match("github.com/ourorg/chain/keeper")
+	require.True(t, ok)
+	require.Equal(t, TraceLevel, lvl)
+
+	lvl, ok = rules.Match("github.com/ourorg/chain/p2p")
+	require.True(t, ok)
+	require.Equal(t, WarnLevel, lvl)
+
+	lvl, ok = rules.Match("accounts")
+	require.True(t, ok)
+	require.Equal(t, DebugLevel, lvl)
+
+	_, ok = rules.Match("github.com/ourorg/chainlink")
+	require.False(t, ok)
+
+	_, err = ParseLevelRules("foo=loud")
+	require.Error(t, err)
+
+	_, err = ParseLevelRules("trace")
+	require.Error(t, err)
+}
+
+func TestLevelRules(t *testing.T) {
+	t.Run("per-package levels", func(t *testing.T) {
+		var recorder strings.Builder
+		l := NewLogger(&recorder, new(TextFormatter))
+		cfg := l.(LoggerConfigurator)
+
+		rules, err := ParseLevelRules("github.com/InjectiveLabs/suplog/wrapped-test=trace,default=warn")
+		require.NoError(t, err)
+		l.(LevelRulesConfigurator).SetLevelRules(rules)
+		require.Equal(t, WarnLevel, cfg.GetLevel())
+
+		l.Info("info from test package")
+		wrapped.NewTestWrapper(l).DebugText("debug from wrapped package")
+
+		out := recorder.String()
+		require.NotContains(t, out, "info from test package")
+		require.Contains(t, out, "debug from wrapped package")
+		require.False(t, cfg.IsLevelEnabled(InfoLevel))
+	})
+
+	t.Run("module field levels", func(t *testing.T) {
+		var recorder strings.Builder
+		l := NewLogger(&recorder, new(TextFormatter))
+		l.(LoggerConfigurator).SetLevel(InfoLevel)
+
+		rules, err := ParseLevelRules("accounts=trace")
+		require.NoError(t, err)
+		l.(LevelRulesConfigurator).SetLevelRules(rules)
+
+		l.WithField("module", "accounts").Tracef("trace from %s", "accounts")
+		l.WithField("module", "orders").Debugf("debug from %s", "orders")
+		l.WithField("module", "orders").Infof("info from %s", "orders")
+
+		out := recorder.String()
+		require.Contains(t, out, "trace from accounts")
+		require.NotContains(t, out, "debug from orders")
+		require.Contains(t, out, "info from orders")
+		require.True(t, l.WithField("module", "accounts").(LoggerConfigurator).IsLevelEnabled(TraceLevel))
+	})
+
+	t.Run("reset rules", func(t *testing.T) {
+		var recorder strings.Builder
+		l := NewLogger(&recorder, new(TextFormatter))
+		cfg := l.(LoggerConfigurator)
+
+		rulesCfg := l.(LevelRulesConfigurator)
+
+		rules, err := ParseLevelRules("accounts=trace,default=error")
+		require.NoError(t, err)
+		rulesCfg.SetLevelRules(rules)
+		require.Equal(t, "accounts=trace,default=error", rulesCfg.GetLevelRules().String())
+
+		rulesCfg.SetLevelRules(nil)
+		require.Nil(t, rulesCfg.GetLevelRules())
+		require.Equal(t, ErrorLevel, cfg.GetLevel())
+
+		l.Warning("hidden warning")
+		l.Error("shown error")
+
+		out := recorder.String()
+		require.NotContains(t, out, "hidden warning")
+		require.Contains(t, out, "shown error")
+	})
+}
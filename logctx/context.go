@@ -12,9 +12,20 @@ type ctxLogKey struct{}
 type loggerCtx struct {
 	logger suplog.Logger
 	level  suplog.Level
+	tail   *tailBuffer
 	mx     sync.Mutex
 }
 
+// current returns the logger, wrapped into tail logger if buffering is enabled.
+// Must be called with the lock held.
+func (l *loggerCtx) current() suplog.Logger {
+	if l.tail != nil {
+		return l.tail.wrap(l.logger)
+	}
+
+	return l.logger
+}
+
 // WithLogger adds the logger to the context, wrapped in our thread-safe struct.
 func WithLogger(ctx context.Context, logger suplog.Logger) context.Context {
 	return context.WithValue(ctx, ctxLogKey{}, &loggerCtx{
@@ -102,7 +113,7 @@ func Logger(ctx context.Context) suplog.Logger {
 	l.mx.Lock()
 	defer l.mx.Unlock()
 
-	return l.logger
+	return l.current()
 }
 
 func Debug(ctx context.Context, msg string) {
@@ -165,7 +176,7 @@ func Log(ctx context.Context, level suplog.Level, msg string) {
 		level = l.level
 	}
 
-	l.logger.Log(level, msg)
+	l.current().Log(level, msg)
 }
 
 // Logf logs a formatted message using the logger from the context at the specified level.
@@ -184,7 +195,7 @@ func Logf(ctx context.Context, level suplog.Level, format string, args ...interf
 		level = l.level
 	}
 
-	l.logger.Logf(level, format, args...)
+	l.current().Logf(level, format, args...)
 }
 
 func fromContext(ctx context.Context) (*loggerCtx, bool) {
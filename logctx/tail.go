@@ -0,0 +1,445 @@
+package logctx
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/InjectiveLabs/suplog"
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+// TailOptions allows to set tail buffering options.
+type TailOptions struct {
+	// Levels are buffered instead of being logged, defaults to Debug and Trace.
+	Levels []suplog.Level
+	// FlushLevel is the least severe level that flushes the buffer, defaults to Error.
+	// It's a pointer, since the zero value is PanicLevel.
+	FlushLevel *suplog.Level
+	// MaxEntries limits the buffer size, defaults to 1000.
+	MaxEntries int
+	// DropNewest keeps the first entries when the buffer is full,
+	// by default the oldest entries are dropped.
+	DropNewest bool
+	// FlushAfterTrigger logs the buffered entries after the entry that
+	// triggered the flush, by default they are logged before it, in order.
+	FlushAfterTrigger bool
+	// KeepBuffering continues to buffer entries after a flush, by default
+	// entries are logged directly once the buffer has been flushed.
+	KeepBuffering bool
+}
+
+func checkTailOptions(opt *TailOptions) *TailOptions {
+	if opt == nil {
+		opt = &TailOptions{}
+	}
+
+	if len(opt.Levels) == 0 {
+		opt.Levels = []suplog.Level{
+			suplog.DebugLevel,
+			suplog.TraceLevel,
+		}
+	}
+
+	if opt.FlushLevel == nil {
+		level := suplog.ErrorLevel
+		opt.FlushLevel = &level
+	}
+
+	if opt.MaxEntries <= 0 {
+		opt.MaxEntries = 1000
+	}
+
+	return opt
+}
+
+// WithTailLogger adds the logger to the context like WithLogger, but entries at
+// buffered levels are kept in memory and logged only when an entry at FlushLevel
+// or above is logged, or Flush is called. Otherwise they are discarded once
+// the context is done, or Discard is called. Entries are logged with the context,
+// unless another one is set with WithContext, which carries the original caller.
+func WithTailLogger(ctx context.Context, logger suplog.Logger, opt *TailOptions) context.Context {
+	tail := newTailBuffer(ctx, checkTailOptions(opt))
+	context.AfterFunc(ctx, tail.discard)
+
+	return context.WithValue(ctx, ctxLogKey{}, &loggerCtx{
+		logger: logger,
+		level:  suplog.TraceLevel,
+		tail:   tail,
+	})
+}
+
+// Flush logs the buffered entries of the tail logger in the context,
+// unless the context is done already.
+func Flush(ctx context.Context) {
+	l, ok := fromContext(ctx)
+	if !ok || l.tail == nil {
+		return
+	}
+
+	if ctx.Err() != nil {
+		l.tail.discard()
+		return
+	}
+
+	l.tail.flush()
+}
+
+// Discard drops the buffered entries of the tail logger in the context.
+func Discard(ctx context.Context) {
+	l, ok := fromContext(ctx)
+	if !ok || l.tail == nil {
+		return
+	}
+
+	l.tail.discard()
+}
+
+// tailBuffer keeps entries, shared by all tail loggers of the context.
+type tailBuffer struct {
+	opt      *TailOptions
+	buffered map[suplog.Level]bool
+	stack    stackcache.StackCache
+	// ctx is the entry context of loggers without one set by WithContext
+	ctx context.Context
+
+	mux     sync.Mutex
+	entries []tailEntry
+	dropped int
+	flushed bool
+}
+
+type tailEntry struct {
+	logger  suplog.Logger
+	ctx     context.Context
+	level   suplog.Level
+	message string
+	time    time.Time
+	caller  runtime.Frame
+}
+
+func newTailBuffer(ctx context.Context, opt *TailOptions) *tailBuffer {
+	t := &tailBuffer{
+		opt:      opt,
+		buffered: make(map[suplog.Level]bool, len(opt.Levels)),
+		stack:    stackcache.New(1, 0, "github.com/InjectiveLabs/suplog/logctx"),
+		ctx:      ctx,
+	}
+
+	for _, lvl := range opt.Levels {
+		t.buffered[lvl] = true
+	}
+
+	return t
+}
+
+// add buffers the entry, returns false if it should be logged directly.
+func (t *tailBuffer) add(logger suplog.Logger, ctx context.Context, level suplog.Level, message func() string) bool {
+	if !t.buffered[level] {
+		return false
+	}
+
+	if cfg, ok := logger.(suplog.LoggerConfigurator); ok && !cfg.IsLevelEnabled(level) {
+		// would be skipped anyway
+		return true
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.flushed && !t.opt.KeepBuffering {
+		return false
+	}
+
+	if len(t.entries) >= t.opt.MaxEntries {
+		t.dropped++
+		if t.opt.DropNewest {
+			return true
+		}
+
+		copy(t.entries, t.entries[1:])
+		t.entries = t.entries[:len(t.entries)-1]
+	}
+
+	t.entries = append(t.entries, tailEntry{
+		logger:  logger,
+		ctx:     ctx,
+		level:   level,
+		message: message(),
+		time:    time.Now(),
+		caller:  t.stack.GetCaller(),
+	})
+
+	return true
+}
+
+// log handles an entry that is not buffered, flushing if needed. The caller
+// is captured here, since the entry is logged from within this package.
+func (t *tailBuffer) log(logger suplog.Logger, ctx context.Context, level suplog.Level, direct func(suplog.Logger)) {
+	logger = logger.WithContext(stackcache.WithCaller(ctx, t.stack.GetCaller()))
+
+	if level > *t.opt.FlushLevel {
+		direct(logger)
+		return
+	}
+
+	// Fatal and Panic never return, so flush goes first
+	if t.opt.FlushAfterTrigger && level > suplog.FatalLevel {
+		direct(logger)
+		t.flush()
+		return
+	}
+
+	t.flush()
+	direct(logger)
+}
+
+func (t *tailBuffer) flush() {
+	t.mux.Lock()
+	entries, dropped := t.entries, t.dropped
+	t.entries = nil
+	t.dropped = 0
+	t.flushed = true
+	t.mux.Unlock()
+
+	for i, e := range entries {
+		logger := e.logger.
+			WithContext(stackcache.WithCaller(e.ctx, e.caller)).
+			WithTime(e.time)
+
+		if i == 0 && dropped > 0 {
+			logger = logger.WithField("tail_dropped", dropped)
+		}
+
+		logger.Log(e.level, e.message)
+	}
+}
+
+func (t *tailBuffer) discard() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.entries = nil
+	t.dropped = 0
+}
+
+func (t *tailBuffer) wrap(logger suplog.Logger) suplog.Logger {
+	return &tailLogger{
+		Logger: logger,
+		tail:   t,
+		ctx:    t.ctx,
+	}
+}
+
+var _ suplog.Logger = (*tailLogger)(nil)
+
+// tailLogger passes buffered levels into the tail buffer.
+type tailLogger struct {
+	suplog.Logger
+	tail *tailBuffer
+	// ctx is the entry context, kept here to add the caller into it
+	ctx context.Context
+	// resolved is set by Defer, DeferError and ErrLevel, entries with these are never
+	// buffered, since deferred values and the error level are resolved when logged
+	resolved bool
+}
+
+func (l *tailLogger) with(logger suplog.Logger) *tailLogger {
+	return &tailLogger{
+		Logger:   logger,
+		tail:     l.tail,
+		ctx:      l.ctx,
+		resolved: l.resolved,
+	}
+}
+
+func (l *tailLogger) buffer(level suplog.Level, message func() string) bool {
+	return !l.resolved && l.tail.add(l.Logger, l.ctx, level, message)
+}
+
+func (l *tailLogger) logf(level suplog.Level, format string, args []interface{}, direct func(suplog.Logger)) {
+	if l.buffer(level, func() string { return fmt.Sprintf(format, args...) }) {
+		return
+	}
+
+	l.tail.log(l.Logger, l.ctx, level, direct)
+}
+
+func (l *tailLogger) log(level suplog.Level, args []interface{}, direct func(suplog.Logger)) {
+	if l.buffer(level, func() string { return fmt.Sprint(args...) }) {
+		return
+	}
+
+	l.tail.log(l.Logger, l.ctx, level, direct)
+}
+
+func (l *tailLogger) logln(level suplog.Level, args []interface{}, direct func(suplog.Logger)) {
+	if l.buffer(level, func() string { return strings.TrimSuffix(fmt.Sprintln(args...), "\n") }) {
+		return
+	}
+
+	l.tail.log(l.Logger, l.ctx, level, direct)
+}
+
+func (l *tailLogger) WithField(key string, value interface{}) suplog.Logger {
+	return l.with(l.Logger.WithField(key, value))
+}
+
+func (l *tailLogger) WithFields(fields suplog.Fields) suplog.Logger {
+	return l.with(l.Logger.WithFields(fields))
+}
+
+func (l *tailLogger) WithError(err error) suplog.Logger {
+	return l.with(l.Logger.WithError(err))
+}
+
+func (l *tailLogger) WithContext(ctx context.Context) suplog.Logger {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	out := l.with(l.Logger.WithContext(ctx))
+	out.ctx = ctx
+
+	return out
+}
+
+func (l *tailLogger) WithTime(t time.Time) suplog.Logger {
+	return l.with(l.Logger.WithTime(t))
+}
+
+func (l *tailLogger) Defer(key string, value interface{}) suplog.Logger {
+	out := l.with(l.Logger.Defer(key, value))
+	out.resolved = true
+
+	return out
+}
+
+func (l *tailLogger) DeferError(err *error) suplog.Logger {
+	out := l.with(l.Logger.DeferError(err))
+	out.resolved = true
+
+	return out
+}
+
+func (l *tailLogger) ErrLevel(level suplog.Level) suplog.Logger {
+	out := l.with(l.Logger.ErrLevel(level))
+	out.resolved = true
+
+	return out
+}
+
+func (l *tailLogger) Success(format string, args ...interface{}) {
+	l.logf(suplog.InfoLevel, format, args, func(lg suplog.Logger) { lg.Success(format, args...) })
+}
+
+func (l *tailLogger) Warning(format string, args ...interface{}) {
+	l.logf(suplog.WarnLevel, format, args, func(lg suplog.Logger) { lg.Warning(format, args...) })
+}
+
+func (l *tailLogger) Error(format string, args ...interface{}) {
+	l.logf(suplog.ErrorLevel, format, args, func(lg suplog.Logger) { lg.Error(format, args...) })
+}
+
+func (l *tailLogger) Debug(format string, args ...interface{}) {
+	l.logf(suplog.DebugLevel, format, args, func(lg suplog.Logger) { lg.Debug(format, args...) })
+}
+
+func (l *tailLogger) Logf(level suplog.Level, format string, args ...interface{}) {
+	l.logf(level, format, args, func(lg suplog.Logger) { lg.Logf(level, format, args...) })
+}
+
+func (l *tailLogger) Tracef(format string, args ...interface{}) {
+	l.logf(suplog.TraceLevel, format, args, func(lg suplog.Logger) { lg.Tracef(format, args...) })
+}
+
+func (l *tailLogger) Debugf(format string, args ...interface{}) {
+	l.logf(suplog.DebugLevel, format, args, func(lg suplog.Logger) { lg.Debugf(format, args...) })
+}
+
+func (l *tailLogger) Infof(format string, args ...interface{}) {
+	l.logf(suplog.InfoLevel, format, args, func(lg suplog.Logger) { lg.Infof(format, args...) })
+}
+
+func (l *tailLogger) Printf(format string, args ...interface{}) {
+	l.logf(suplog.InfoLevel, format, args, func(lg suplog.Logger) { lg.Printf(format, args...) })
+}
+
+func (l *tailLogger) Warningf(format string, args ...interface{}) {
+	l.logf(suplog.WarnLevel, format, args, func(lg suplog.Logger) { lg.Warningf(format, args...) })
+}
+
+func (l *tailLogger) Errorf(format string, args ...interface{}) {
+	l.logf(suplog.ErrorLevel, format, args, func(lg suplog.Logger) { lg.Errorf(format, args...) })
+}
+
+func (l *tailLogger) Fatalf(format string, args ...interface{}) {
+	l.logf(suplog.FatalLevel, format, args, func(lg suplog.Logger) { lg.Fatalf(format, args...) })
+}
+
+func (l *tailLogger) Panicf(format string, args ...interface{}) {
+	l.logf(suplog.PanicLevel, format, args, func(lg suplog.Logger) { lg.Panicf(format, args...) })
+}
+
+func (l *tailLogger) Log(level suplog.Level, args ...interface{}) {
+	l.log(level, args, func(lg suplog.Logger) { lg.Log(level, args...) })
+}
+
+func (l *tailLogger) Trace(args ...interface{}) {
+	l.log(suplog.TraceLevel, args, func(lg suplog.Logger) { lg.Trace(args...) })
+}
+
+func (l *tailLogger) Info(args ...interface{}) {
+	l.log(suplog.InfoLevel, args, func(lg suplog.Logger) { lg.Info(args...) })
+}
+
+func (l *tailLogger) Print(args ...interface{}) {
+	l.log(suplog.InfoLevel, args, func(lg suplog.Logger) { lg.Print(args...) })
+}
+
+func (l *tailLogger) Fatal(args ...interface{}) {
+	l.log(suplog.FatalLevel, args, func(lg suplog.Logger) { lg.Fatal(args...) })
+}
+
+func (l *tailLogger) Panic(args ...interface{}) {
+	l.log(suplog.PanicLevel, args, func(lg suplog.Logger) { lg.Panic(args...) })
+}
+
+func (l *tailLogger) Logln(level suplog.Level, args ...interface{}) {
+	l.logln(level, args, func(lg suplog.Logger) { lg.Logln(level, args...) })
+}
+
+func (l *tailLogger) Traceln(args ...interface{}) {
+	l.logln(suplog.TraceLevel, args, func(lg suplog.Logger) { lg.Traceln(args...) })
+}
+
+func (l *tailLogger) Debugln(args ...interface{}) {
+	l.logln(suplog.DebugLevel, args, func(lg suplog.Logger) { lg.Debugln(args...) })
+}
+
+func (l *tailLogger) Infoln(args ...interface{}) {
+	l.logln(suplog.InfoLevel, args, func(lg suplog.Logger) { lg.Infoln(args...) })
+}
+
+func (l *tailLogger) Println(args ...interface{}) {
+	l.logln(suplog.InfoLevel, args, func(lg suplog.Logger) { lg.Println(args...) })
+}
+
+func (l *tailLogger) Warningln(args ...interface{}) {
+	l.logln(suplog.WarnLevel, args, func(lg suplog.Logger) { lg.Warningln(args...) })
+}
+
+func (l *tailLogger) Errorln(args ...interface{}) {
+	l.logln(suplog.ErrorLevel, args, func(lg suplog.Logger) { lg.Errorln(args...) })
+}
+
+func (l *tailLogger) Fatalln(args ...interface{}) {
+	l.logln(suplog.FatalLevel, args, func(lg suplog.Logger) { lg.Fatalln(args...) })
+}
+
+func (l *tailLogger) Panicln(args ...interface{}) {
+	l.logln(suplog.PanicLevel, args, func(lg suplog.Logger) { lg.Panicln(args...) })
+}
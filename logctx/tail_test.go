@@ -0,0 +1,168 @@
+package logctx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	log "github.com/InjectiveLabs/suplog"
+	debugHook "github.com/InjectiveLabs/suplog/hooks/debug"
+)
+
+// fieldsLogger records field keys, like a logger implementation other than suplog.
+type fieldsLogger struct {
+	log.Logger
+	keys *[]string
+}
+
+func (l *fieldsLogger) WithField(key string, value interface{}) log.Logger {
+	*l.keys = append(*l.keys, key)
+	return &fieldsLogger{Logger: l.Logger.WithField(key, value), keys: l.keys}
+}
+
+func (l *fieldsLogger) WithContext(ctx context.Context) log.Logger {
+	return &fieldsLogger{Logger: l.Logger.WithContext(ctx), keys: l.keys}
+}
+
+func (l *fieldsLogger) WithTime(t time.Time) log.Logger {
+	return &fieldsLogger{Logger: l.Logger.WithTime(t), keys: l.keys}
+}
+
+func TestTailLogger(t *testing.T) {
+	newLogger := func(recorder *strings.Builder) log.Logger {
+		l := log.NewLogger(recorder, new(log.TextFormatter), debugHook.NewHook(log.DefaultLogger, nil))
+		l.(log.LoggerConfigurator).SetLevel(log.TraceLevel)
+		return l
+	}
+
+	t.Run("discards debug entries of successful requests", func(t *testing.T) {
+		var recorder strings.Builder
+		ctx, cancel := context.WithCancel(context.Background())
+		ctx = WithTailLogger(ctx, newLogger(&recorder), nil)
+
+		Debug(ctx, "debug details")
+		Logger(ctx).WithField("step", 2).Tracef("trace details %d", 2)
+		Info(ctx, "request done")
+		cancel()
+		Flush(ctx)
+
+		out := recorder.String()
+		require.NotContains(t, out, "details")
+		require.Contains(t, out, "request done")
+	})
+
+	t.Run("flushes debug entries on error", func(t *testing.T) {
+		var recorder strings.Builder
+		ctx := WithTailLogger(context.Background(), newLogger(&recorder), nil)
+
+		Debug(ctx, "first details")
+		WithField(ctx, "user", "alice")
+		Logger(ctx).WithField("step", 2).Tracef("second details %d", 2)
+		require.Empty(t, recorder.String())
+
+		Logger(ctx).WithError(errors.New("fail")).Error("request failed")
+
+		out := recorder.String()
+		first := strings.Index(out, "first details")
+		second := strings.Index(out, "second details 2")
+		failed := strings.Index(out, "request failed")
+		require.True(t, first >= 0 && first < second && second < failed, out)
+		require.Contains(t, out, "step=2")
+		require.Contains(t, out, "user=alice")
+		require.Contains(t, out, "tail_test.go")
+		require.NotContains(t, out, "::caller::")
+
+		// passes through after flush
+		Debug(ctx, "third details")
+		require.Contains(t, recorder.String(), "third details")
+	})
+
+	t.Run("flush after trigger and size limit", func(t *testing.T) {
+		var recorder strings.Builder
+		ctx := WithTailLogger(context.Background(), newLogger(&recorder), &TailOptions{
+			MaxEntries:        2,
+			FlushAfterTrigger: true,
+			KeepBuffering:     true,
+		})
+
+		for i := 0; i < 4; i++ {
+			Debugf(ctx, "details %d", i)
+		}
+		Error(ctx, "request failed")
+
+		out := recorder.String()
+		require.NotContains(t, out, "details 1")
+		require.Contains(t, out, "tail_dropped=2")
+		require.True(t, strings.Index(out, "request failed") < strings.Index(out, "details 2"), out)
+		require.True(t, strings.Index(out, "details 2") < strings.Index(out, "details 3"), out)
+
+		// keeps buffering after flush
+		Debug(ctx, "more details")
+		require.NotContains(t, recorder.String(), "more details")
+
+		Flush(ctx)
+		require.Contains(t, recorder.String(), "more details")
+	})
+
+	t.Run("passes caller in context", func(t *testing.T) {
+		var (
+			recorder strings.Builder
+			keys     []string
+		)
+		logger := &fieldsLogger{Logger: newLogger(&recorder), keys: &keys}
+		ctx := WithTailLogger(context.Background(), logger, nil)
+
+		Debug(ctx, "debug details")
+		Logger(ctx).WithField("step", 2).Error("request failed")
+
+		out := recorder.String()
+		require.Contains(t, out, "debug details")
+		require.Contains(t, out, "tail_test.go")
+		require.Equal(t, []string{"step"}, keys)
+	})
+
+	t.Run("flushes on panic level", func(t *testing.T) {
+		var recorder strings.Builder
+		flushLevel := log.PanicLevel
+		ctx := WithTailLogger(context.Background(), newLogger(&recorder), &TailOptions{
+			FlushLevel: &flushLevel,
+		})
+
+		Debug(ctx, "debug details")
+		Error(ctx, "request failed")
+		require.NotContains(t, recorder.String(), "debug details")
+
+		require.Panics(t, func() {
+			Logger(ctx).Panic("request panicked")
+		})
+
+		out := recorder.String()
+		require.Contains(t, out, "debug details")
+		require.True(t, strings.Index(out, "debug details") < strings.Index(out, "request panicked"), out)
+	})
+
+	t.Run("logs entries with error level and deferred fields", func(t *testing.T) {
+		var recorder strings.Builder
+		ctx, cancel := context.WithCancel(context.Background())
+		ctx = WithTailLogger(ctx, newLogger(&recorder), nil)
+
+		Logger(ctx).ErrLevel(log.ErrorLevel).WithError(errors.New("boom")).Debug("handled")
+
+		var err error
+		Logger(ctx).DeferError(&err).Debug("deferred")
+		err = errors.New("later")
+
+		cancel()
+		Flush(ctx)
+
+		out := recorder.String()
+		require.Contains(t, out, "level=error msg=handled")
+		require.Contains(t, out, "error=boom")
+		require.Contains(t, out, "msg=deferred")
+		require.NotContains(t, out, "later")
+	})
+}
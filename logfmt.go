@@ -0,0 +1,256 @@
+package suplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LogfmtFormatter formats entries as strict logfmt lines, e.g. for Loki and Promtail.
+// Keys are ordered as time, level, msg, then FieldOrder, then the rest sorted.
+// Nested maps are flattened into dotted keys, errors are rendered with Error().
+// Keys are never repeated, fields clashing with default or other keys get "fields." prefix.
+type LogfmtFormatter struct {
+	// TimestampFormat sets the format used for timestamps, defaults to time.RFC3339.
+	TimestampFormat string
+	// DisableTimestamp omits the time key.
+	DisableTimestamp bool
+	// FieldOrder lists fields rendered right after msg, in this order.
+	FieldOrder []string
+	// FieldMap allows to rename default keys, e.g. FieldMap{FieldKeyMsg: "message"}.
+	FieldMap FieldMap
+}
+
+// Format renders a single log entry.
+func (f *LogfmtFormatter) Format(e *Entry) ([]byte, error) {
+	data := make(Fields, len(e.Data))
+	for k, v := range e.Data {
+		switch k {
+		case f.key(FieldKeyTime), f.key(FieldKeyLevel), f.key(FieldKeyMsg),
+			f.key(FieldKeyFunc), f.key(FieldKeyFile):
+			// clashes with default keys, same as logrus does
+			k = "fields." + k
+			for _, taken := e.Data[k]; taken; _, taken = e.Data[k] {
+				k = "fields." + k
+			}
+
+			data[k] = v
+		default:
+			data[k] = v
+		}
+	}
+
+	b := e.Buffer
+	if b == nil {
+		b = new(bytes.Buffer)
+	}
+
+	enc := &logfmtEncoder{
+		b:    b,
+		seen: make(map[string]bool, len(data)+5),
+	}
+
+	if !f.DisableTimestamp {
+		timestampFormat := f.TimestampFormat
+		if len(timestampFormat) == 0 {
+			timestampFormat = time.RFC3339
+		}
+
+		enc.append(f.key(FieldKeyTime), e.Time.Format(timestampFormat))
+	}
+
+	enc.append(f.key(FieldKeyLevel), e.Level.String())
+	enc.append(f.key(FieldKeyMsg), e.Message)
+
+	if e.HasCaller() {
+		enc.append(f.key(FieldKeyFunc), e.Caller.Function)
+		enc.append(f.key(FieldKeyFile), fmt.Sprintf("%s:%d", e.Caller.File, e.Caller.Line))
+	}
+
+	for _, k := range f.FieldOrder {
+		if v, ok := data[k]; ok {
+			enc.appendValue(k, v)
+			delete(data, k)
+		}
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		enc.appendValue(k, data[k])
+	}
+
+	b.WriteByte('\n')
+
+	return b.Bytes(), nil
+}
+
+func (f *LogfmtFormatter) key(k string) string {
+	for mk, v := range f.FieldMap {
+		if string(mk) == k {
+			return v
+		}
+	}
+
+	return k
+}
+
+// logfmtEncoder writes pairs, keeping keys unique.
+type logfmtEncoder struct {
+	b    *bytes.Buffer
+	seen map[string]bool
+}
+
+// append writes the pair, the key gets "fields." prefix if it has been written already,
+// e.g. a nested map flattened into the key of another field.
+func (enc *logfmtEncoder) append(key, value string) {
+	key = logfmtKey(key)
+	for enc.seen[key] {
+		key = "fields." + key
+	}
+	enc.seen[key] = true
+
+	appendLogfmt(enc.b, key, value)
+}
+
+// appendValue renders the value, flattening nested maps into dotted keys.
+// Nil and empty maps are rendered as empty values.
+func (enc *logfmtEncoder) appendValue(key string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		enc.append(key, "")
+	case string:
+		enc.append(key, v)
+	case error:
+		enc.append(key, v.Error())
+	case time.Time:
+		enc.append(key, v.Format(time.RFC3339Nano))
+	case time.Duration:
+		enc.append(key, v.String())
+	case fmt.Stringer:
+		enc.append(key, v.String())
+	case []byte:
+		enc.append(key, string(v))
+	case bool:
+		enc.append(key, strconv.FormatBool(v))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		enc.append(key, fmt.Sprint(v))
+	default:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String {
+			if rv.Len() == 0 {
+				enc.append(key, "")
+				return
+			}
+
+			keys := make([]string, 0, rv.Len())
+			for _, mk := range rv.MapKeys() {
+				keys = append(keys, mk.String())
+			}
+			sort.Strings(keys)
+
+			for _, mk := range keys {
+				mv := rv.MapIndex(reflect.ValueOf(mk).Convert(rv.Type().Key()))
+				enc.appendValue(key+"."+mk, mv.Interface())
+			}
+
+			return
+		}
+
+		// slices and structs are rendered as JSON when possible
+		if out, err := json.Marshal(value); err == nil {
+			enc.append(key, string(out))
+			return
+		}
+
+		enc.append(key, fmt.Sprintf("%+v", value))
+	}
+}
+
+func appendLogfmt(b *bytes.Buffer, key, value string) {
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+
+	b.WriteString(key)
+	b.WriteByte('=')
+
+	if needsLogfmtQuoting(value) {
+		writeLogfmtQuoted(b, value)
+	} else {
+		b.WriteString(value)
+	}
+}
+
+// logfmtKey replaces characters not allowed in keys with underscores.
+func logfmtKey(key string) string {
+	if len(key) == 0 {
+		return "_"
+	}
+
+	var b strings.Builder
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			b.WriteByte('_')
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func needsLogfmtQuoting(s string) bool {
+	if len(s) == 0 {
+		return true
+	}
+
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeLogfmtQuoted(b *bytes.Buffer, s string) {
+	b.WriteByte('"')
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == utf8.RuneError && size == 1:
+			b.WriteString(`�`)
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(b, `\u%04x`, r)
+		default:
+			b.WriteString(s[i : i+size])
+		}
+
+		i += size
+	}
+
+	b.WriteByte('"')
+}
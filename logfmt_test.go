@@ -0,0 +1,103 @@
+package suplog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogfmtFormatter(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	format := func(f *LogfmtFormatter, msg string, fields Fields) string {
+		out, err := f.Format(&Entry{
+			Time:    ts,
+			Level:   WarnLevel,
+			Message: msg,
+			Data:    fields,
+		})
+		require.NoError(t, err)
+
+		return string(out)
+	}
+
+	t.Run("orders keys", func(t *testing.T) {
+		out := format(new(LogfmtFormatter), "hello", Fields{
+			"b": 2,
+			"a": "x",
+		})
+
+		require.Equal(t, "time=2024-01-02T03:04:05Z level=warning msg=hello a=x b=2\n", out)
+	})
+
+	t.Run("configured order and keys", func(t *testing.T) {
+		out := format(&LogfmtFormatter{
+			DisableTimestamp: true,
+			FieldOrder:       []string{"z", "missing"},
+			FieldMap:         FieldMap{FieldKeyMsg: "message"},
+		}, "hello", Fields{
+			"a": 1,
+			"z": 2,
+		})
+
+		require.Equal(t, "level=warning message=hello z=2 a=1\n", out)
+	})
+
+	t.Run("quotes and escapes", func(t *testing.T) {
+		out := format(&LogfmtFormatter{DisableTimestamp: true}, "line one\nline \"two\"", Fields{
+			"empty":   "",
+			"eq":      "a=b",
+			"path":    `c:\tmp`,
+			"bad key": "\x01",
+		})
+
+		require.Equal(t, `level=warning msg="line one\nline \"two\"" bad_key="\u0001" empty="" eq="a=b" path="c:\\tmp"`+"\n", out)
+	})
+
+	t.Run("errors and nested maps", func(t *testing.T) {
+		out := format(&LogfmtFormatter{DisableTimestamp: true}, "failed", Fields{
+			"error": errors.New("connection refused"),
+			"req": map[string]interface{}{
+				"method": "GET",
+				"headers": map[string]string{
+					"accept": "*/*",
+				},
+			},
+			"ids":   []int{1, 2},
+			"level": "clash",
+		})
+
+		require.Equal(t, `level=warning msg=failed error="connection refused" fields.level=clash ids=[1,2] req.headers.accept=*/* req.method=GET`+"\n", out)
+	})
+
+	t.Run("keys are not repeated", func(t *testing.T) {
+		out := format(&LogfmtFormatter{DisableTimestamp: true}, "hello", Fields{
+			"msg":        "clash",
+			"fields.msg": "taken",
+			"req":        map[string]interface{}{"id": 1},
+			"req.id":     2,
+		})
+
+		require.Equal(t, `level=warning msg=hello fields.fields.msg=clash fields.msg=taken req.id=1 fields.req.id=2`+"\n", out)
+	})
+
+	t.Run("nil and empty maps", func(t *testing.T) {
+		var headers map[string]string
+		out := format(&LogfmtFormatter{DisableTimestamp: true}, "hello", Fields{
+			"headers": headers,
+			"query":   map[string]interface{}{},
+		})
+
+		require.Equal(t, `level=warning msg=hello headers="" query=""`+"\n", out)
+	})
+
+	t.Run("with logger", func(t *testing.T) {
+		var recorder strings.Builder
+		NewLogger(&recorder, new(LogfmtFormatter)).WithField("user", "alice bob").Info("hello")
+
+		require.Contains(t, recorder.String(), ` level=info msg=hello user="alice bob"`)
+	})
+}
@@ -0,0 +1,254 @@
+// Package grpcmw provides gRPC server interceptors that attach
+// a request-scoped logger to the call context, see logctx package.
+package grpcmw
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/InjectiveLabs/suplog"
+	"github.com/InjectiveLabs/suplog/logctx"
+	"github.com/InjectiveLabs/suplog/middleware/requestid"
+)
+
+// Options allows to set additional interceptor options.
+type Options struct {
+	// Logger is the base logger for calls, defaults to suplog.DefaultLogger.
+	Logger suplog.Logger
+	// AccessLevel is the level of access log lines, defaults to Info.
+	// Calls that end with server-side error codes are logged at Error level.
+	// It's a pointer, since the zero value is PanicLevel.
+	AccessLevel *suplog.Level
+	// DisableAccessLog disables access log lines.
+	DisableAccessLog bool
+	// RequestIDKey is propagated from the incoming metadata, or generated
+	// and sent in the header. Defaults to "x-request-id". Incoming IDs
+	// that are not valid, see requestid.Valid, are replaced.
+	RequestIDKey string
+	// NewRequestID generates request IDs, defaults to requestid.New.
+	NewRequestID func() string
+	// RePanic panics again after the panic has been logged,
+	// by default the interceptor returns codes.Internal error.
+	RePanic bool
+	// Tail enables tail buffering of call logger, see logctx.WithTailLogger.
+	Tail *logctx.TailOptions
+}
+
+// DefaultRequestIDKey is the metadata key used to propagate request IDs.
+const DefaultRequestIDKey = "x-request-id"
+
+func checkOptions(opt *Options) *Options {
+	if opt == nil {
+		opt = &Options{}
+	}
+
+	if opt.Logger == nil {
+		opt.Logger = suplog.DefaultLogger
+	}
+
+	if opt.AccessLevel == nil {
+		level := suplog.InfoLevel
+		opt.AccessLevel = &level
+	}
+
+	if len(opt.RequestIDKey) == 0 {
+		opt.RequestIDKey = DefaultRequestIDKey
+	}
+
+	if opt.NewRequestID == nil {
+		opt.NewRequestID = requestid.New
+	}
+
+	return opt
+}
+
+// UnaryServerInterceptor returns an interceptor that attaches a call-scoped logger with
+// request_id and method fields, logs an access line with status and duration fields,
+// and recovers panics into an Error entry.
+func UnaryServerInterceptor(opt *Options) grpc.UnaryServerInterceptor {
+	opt = checkOptions(opt)
+
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (resp interface{}, err error) {
+		start := time.Now()
+		ctx = opt.newContext(ctx, info.FullMethod)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(opt.RequestIDKey, requestIDFrom(ctx)))
+
+		defer func() {
+			if v := recover(); v != nil {
+				err = opt.recovered(ctx, v)
+			}
+
+			opt.logAccess(ctx, info.FullMethod, start, err, nil)
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that attaches a call-scoped logger with
+// request_id and method fields, logs an access line with status, duration, sent and
+// received fields, and recovers panics into an Error entry.
+func StreamServerInterceptor(opt *Options) grpc.StreamServerInterceptor {
+	opt = checkOptions(opt)
+
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		start := time.Now()
+
+		stream := &serverStream{
+			ServerStream: ss,
+			ctx:          opt.newContext(ss.Context(), info.FullMethod),
+		}
+		_ = stream.SetHeader(metadata.Pairs(opt.RequestIDKey, requestIDFrom(stream.ctx)))
+
+		defer func() {
+			if v := recover(); v != nil {
+				err = opt.recovered(stream.ctx, v)
+			}
+
+			opt.logAccess(stream.ctx, info.FullMethod, start, err, suplog.Fields{
+				"sent":     atomic.LoadInt64(&stream.sent),
+				"received": atomic.LoadInt64(&stream.received),
+			})
+		}()
+
+		return handler(srv, stream)
+	}
+}
+
+type requestIDCtxKey struct{}
+
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+func (opt *Options) newContext(ctx context.Context, method string) context.Context {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(opt.RequestIDKey); len(v) > 0 {
+			requestID = v[0]
+		}
+	}
+
+	if !requestid.Valid(requestID) {
+		requestID = opt.NewRequestID()
+	}
+
+	ctx = context.WithValue(ctx, requestIDCtxKey{}, requestID)
+
+	logger := opt.Logger.WithFields(suplog.Fields{
+		"request_id": requestID,
+		"method":     method,
+	})
+
+	if opt.Tail != nil {
+		return logctx.WithTailLogger(ctx, logger, opt.Tail)
+	}
+
+	return logctx.WithLogger(ctx, logger)
+}
+
+func (opt *Options) recovered(ctx context.Context, v interface{}) error {
+	err, ok := v.(error)
+	if !ok {
+		err = fmt.Errorf("panic: %v", v)
+	}
+
+	logctx.Logger(ctx).WithFields(suplog.Fields{
+		"panic": fmt.Sprint(v),
+		"stack": string(debug.Stack()),
+	}).WithError(err).Errorf("panic recovered: %v", v)
+
+	if opt.RePanic {
+		panic(v)
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func (opt *Options) logAccess(ctx context.Context, method string, start time.Time, err error, fields suplog.Fields) {
+	if opt.DisableAccessLog {
+		return
+	}
+
+	code := status.Code(err)
+
+	level := *opt.AccessLevel
+	if isServerError(code) {
+		level = suplog.ErrorLevel
+	}
+
+	logger := logctx.Logger(ctx).WithFields(suplog.Fields{
+		"status":   code.String(),
+		"duration": time.Since(start).String(),
+	})
+
+	if len(fields) > 0 {
+		logger = logger.WithFields(fields)
+	}
+
+	if err != nil {
+		logger = logger.WithError(err)
+	}
+
+	logger.Logf(level, "%s %s", method, code)
+}
+
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented,
+		codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
+
+// serverStream overrides the context and counts messages.
+type serverStream struct {
+	grpc.ServerStream
+
+	ctx      context.Context
+	sent     int64
+	received int64
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.sent, 1)
+	}
+
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.received, 1)
+	}
+
+	return err
+}
@@ -0,0 +1,159 @@
+package grpcmw
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	log "github.com/InjectiveLabs/suplog"
+)
+
+// syncBuilder is safe to write from server goroutines.
+type syncBuilder struct {
+	mux sync.Mutex
+	b   strings.Builder
+}
+
+func (s *syncBuilder) Write(p []byte) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.b.Write(p)
+}
+
+func (s *syncBuilder) String() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.b.String()
+}
+
+func TestInterceptors(t *testing.T) {
+	recorder := new(syncBuilder)
+	opt := &Options{
+		Logger: log.NewLogger(recorder, new(log.TextFormatter)),
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(opt)),
+		grpc.StreamInterceptor(StreamServerInterceptor(opt)),
+		grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+			panic("boom")
+		}),
+	)
+
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthSrv)
+
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	t.Run("unary call", func(t *testing.T) {
+		var header metadata.MD
+		ctx := metadata.AppendToOutgoingContext(context.Background(), DefaultRequestIDKey, "req-1")
+
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Equal(t, []string{"req-1"}, header.Get(DefaultRequestIDKey))
+
+		out := recorder.String()
+		require.Contains(t, out, "level=info")
+		require.Contains(t, out, "request_id=req-1")
+		require.Contains(t, out, "method=/grpc.health.v1.Health/Check")
+		require.Contains(t, out, "status=OK")
+	})
+
+	t.Run("replaces invalid request ID", func(t *testing.T) {
+		var header metadata.MD
+		ctx := metadata.AppendToOutgoingContext(context.Background(), DefaultRequestIDKey, strings.Repeat("x", 200))
+
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Len(t, header.Get(DefaultRequestIDKey), 1)
+		require.Len(t, header.Get(DefaultRequestIDKey)[0], 26)
+	})
+
+	t.Run("stream call", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		header, err := stream.Header()
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+		require.Len(t, header.Get(DefaultRequestIDKey), 1)
+
+		cancel()
+
+		require.Eventually(t, func() bool {
+			return strings.Contains(recorder.String(), "method=/grpc.health.v1.Health/Watch")
+		}, time.Second, 10*time.Millisecond)
+
+		out := recorder.String()
+		require.Contains(t, out, "request_id="+header.Get(DefaultRequestIDKey)[0])
+		require.Contains(t, out, "sent=1")
+		require.Contains(t, out, "received=1")
+		require.Contains(t, out, "status=Canceled")
+	})
+
+	t.Run("stream panic", func(t *testing.T) {
+		err := conn.Invoke(context.Background(), "/unknown.Service/Method", &healthpb.HealthCheckRequest{}, new(healthpb.HealthCheckResponse))
+		require.Equal(t, codes.Internal, status.Code(err))
+
+		require.Eventually(t, func() bool {
+			return strings.Contains(recorder.String(), "status=Internal")
+		}, time.Second, 10*time.Millisecond)
+
+		out := recorder.String()
+		require.Contains(t, out, "panic recovered: boom")
+		require.Contains(t, out, "level=error")
+	})
+}
+
+func TestUnaryServerInterceptorPanic(t *testing.T) {
+	var recorder strings.Builder
+	interceptor := UnaryServerInterceptor(&Options{
+		Logger: log.NewLogger(&recorder, new(log.TextFormatter)),
+	})
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{
+		FullMethod: "/test.Service/Method",
+	}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("boom")
+	})
+
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Contains(t, recorder.String(), "panic recovered: boom")
+	require.Contains(t, recorder.String(), "status=Internal")
+}
@@ -0,0 +1,219 @@
+// Package httpmw provides net/http server middleware that attaches
+// a request-scoped logger to the request context, see logctx package.
+package httpmw
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/InjectiveLabs/suplog"
+	"github.com/InjectiveLabs/suplog/logctx"
+	"github.com/InjectiveLabs/suplog/middleware/requestid"
+)
+
+// Options allows to set additional middleware options.
+type Options struct {
+	// Logger is the base logger for requests, defaults to suplog.DefaultLogger.
+	Logger suplog.Logger
+	// AccessLevel is the level of access log lines, defaults to Info.
+	// Requests that end with 5xx status are logged at Error level.
+	// It's a pointer, since the zero value is PanicLevel.
+	AccessLevel *suplog.Level
+	// DisableAccessLog disables access log lines.
+	DisableAccessLog bool
+	// RequestIDHeader is propagated from the request, or generated
+	// and set on the response. Defaults to "X-Request-ID". Incoming IDs
+	// that are not valid, see requestid.Valid, are replaced.
+	RequestIDHeader string
+	// NewRequestID generates request IDs, defaults to requestid.New.
+	NewRequestID func() string
+	// RePanic panics again after the panic has been logged,
+	// by default the middleware responds with 500 status.
+	RePanic bool
+	// Tail enables tail buffering of request logger, see logctx.WithTailLogger.
+	Tail *logctx.TailOptions
+}
+
+// DefaultRequestIDHeader is used to propagate request IDs.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+func checkOptions(opt *Options) *Options {
+	if opt == nil {
+		opt = &Options{}
+	}
+
+	if opt.Logger == nil {
+		opt.Logger = suplog.DefaultLogger
+	}
+
+	if opt.AccessLevel == nil {
+		level := suplog.InfoLevel
+		opt.AccessLevel = &level
+	}
+
+	if len(opt.RequestIDHeader) == 0 {
+		opt.RequestIDHeader = DefaultRequestIDHeader
+	}
+
+	if opt.NewRequestID == nil {
+		opt.NewRequestID = requestid.New
+	}
+
+	return opt
+}
+
+// Middleware returns a middleware that attaches a request-scoped logger with
+// request_id, method and path fields, logs an access line with status, duration
+// and bytes fields, and recovers panics into an Error entry.
+func Middleware(opt *Options) func(http.Handler) http.Handler {
+	opt = checkOptions(opt)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(opt.RequestIDHeader)
+			if !requestid.Valid(requestID) {
+				requestID = opt.NewRequestID()
+			}
+			w.Header().Set(opt.RequestIDHeader, requestID)
+
+			fields := suplog.Fields{
+				"request_id": requestID,
+				"method":     r.Method,
+				"path":       r.URL.Path,
+			}
+
+			// always a new logger, so fields would not leak between requests
+			var ctx context.Context
+			if opt.Tail != nil {
+				ctx = logctx.WithTailLogger(r.Context(), opt.Logger.WithFields(fields), opt.Tail)
+			} else {
+				ctx = logctx.WithLogger(r.Context(), opt.Logger.WithFields(fields))
+			}
+
+			rw := &responseWriter{
+				ResponseWriter: w,
+			}
+
+			defer func() {
+				status := rw.Status()
+
+				if v := recover(); v != nil {
+					if v == http.ErrAbortHandler {
+						// sentinel for aborted responses, not worth logging
+						panic(v)
+					}
+
+					logctx.Logger(ctx).WithFields(suplog.Fields{
+						"panic": fmt.Sprint(v),
+						"stack": string(debug.Stack()),
+					}).WithError(panicError(v)).Errorf("panic recovered: %v", v)
+
+					if opt.RePanic {
+						panic(v)
+					}
+
+					if !rw.wroteHeader {
+						http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					}
+
+					status = http.StatusInternalServerError
+				}
+
+				if opt.DisableAccessLog {
+					return
+				}
+
+				level := *opt.AccessLevel
+				if status >= http.StatusInternalServerError {
+					level = suplog.ErrorLevel
+				}
+
+				logctx.Logger(ctx).WithFields(suplog.Fields{
+					"status":   status,
+					"duration": time.Since(start).String(),
+					"bytes":    rw.bytes,
+				}).Logf(level, "%s %s %d", r.Method, r.URL.Path, status)
+			}()
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+func panicError(v interface{}) error {
+	if err, ok := v.(error); ok {
+		return err
+	}
+
+	return fmt.Errorf("panic: %v", v)
+}
+
+// responseWriter captures status and amount of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+// Status returns the response status, 200 if handler has written nothing.
+func (w *responseWriter) Status() int {
+	if !w.wroteHeader {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// Unwrap allows http.ResponseController to access the original writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+
+	return h.Hijack()
+}
@@ -0,0 +1,130 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	log "github.com/InjectiveLabs/suplog"
+	"github.com/InjectiveLabs/suplog/logctx"
+)
+
+func TestMiddleware(t *testing.T) {
+	newHandler := func(recorder *strings.Builder, h http.HandlerFunc) http.Handler {
+		return Middleware(&Options{
+			Logger: log.NewLogger(recorder, new(log.TextFormatter)),
+		})(h)
+	}
+
+	t.Run("propagates request ID", func(t *testing.T) {
+		var recorder strings.Builder
+		h := newHandler(&recorder, func(w http.ResponseWriter, r *http.Request) {
+			logctx.Info(r.Context(), "handling")
+			_, _ = w.Write([]byte("hello"))
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		req.Header.Set(DefaultRequestIDHeader, "req-1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, "req-1", rec.Header().Get(DefaultRequestIDHeader))
+
+		lines := strings.Split(strings.TrimSpace(recorder.String()), "\n")
+		require.Len(t, lines, 2)
+		require.Contains(t, lines[0], "handling")
+		require.Contains(t, lines[0], "request_id=req-1")
+		require.Contains(t, lines[0], "path=/hello")
+		require.Contains(t, lines[1], "level=info")
+		require.Contains(t, lines[1], "status=200")
+		require.Contains(t, lines[1], "bytes=5")
+		require.Contains(t, lines[1], "method=GET")
+		require.Contains(t, lines[1], "duration=")
+	})
+
+	t.Run("generates request ID", func(t *testing.T) {
+		var recorder strings.Builder
+		h := newHandler(&recorder, func(w http.ResponseWriter, r *http.Request) {})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		requestID := rec.Header().Get(DefaultRequestIDHeader)
+		require.Len(t, requestID, 26)
+		require.Contains(t, recorder.String(), "request_id="+requestID)
+	})
+
+	t.Run("replaces invalid request ID", func(t *testing.T) {
+		var recorder strings.Builder
+		h := newHandler(&recorder, func(w http.ResponseWriter, r *http.Request) {})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(DefaultRequestIDHeader, "req-1 level=error")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		requestID := rec.Header().Get(DefaultRequestIDHeader)
+		require.Len(t, requestID, 26)
+		require.Contains(t, recorder.String(), "request_id="+requestID)
+		require.NotContains(t, recorder.String(), "req-1")
+	})
+
+	t.Run("access level", func(t *testing.T) {
+		var recorder strings.Builder
+		level := log.DebugLevel
+		h := Middleware(&Options{
+			Logger:      log.NewLogger(&recorder, new(log.TextFormatter)),
+			AccessLevel: &level,
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Contains(t, recorder.String(), "level=debug")
+	})
+
+	t.Run("logs 5xx at error level", func(t *testing.T) {
+		var recorder strings.Builder
+		h := newHandler(&recorder, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		require.Contains(t, recorder.String(), "level=error")
+		require.Contains(t, recorder.String(), "status=502")
+	})
+
+	t.Run("recovers panic", func(t *testing.T) {
+		var recorder strings.Builder
+		h := newHandler(&recorder, func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		out := recorder.String()
+		require.Contains(t, out, "panic recovered: boom")
+		require.Contains(t, out, "panic=boom")
+		require.Contains(t, out, "status=500")
+	})
+
+	t.Run("re-panics", func(t *testing.T) {
+		var recorder strings.Builder
+		h := Middleware(&Options{
+			Logger:  log.NewLogger(&recorder, new(log.TextFormatter)),
+			RePanic: true,
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+
+		require.PanicsWithValue(t, "boom", func() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+		require.Contains(t, recorder.String(), "panic recovered: boom")
+	})
+}
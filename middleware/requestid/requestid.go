@@ -0,0 +1,35 @@
+// Package requestid generates and validates request IDs propagated by middleware packages.
+package requestid
+
+import (
+	"crypto/rand"
+
+	"github.com/oklog/ulid"
+)
+
+// MaxLength limits the length of incoming request IDs.
+const MaxLength = 128
+
+// New returns a new ULID as a request ID.
+func New() string {
+	return ulid.MustNew(ulid.Now(), rand.Reader).String()
+}
+
+// Valid reports whether the incoming request ID could be logged and sent back as is.
+// It must not be longer than MaxLength, and consist of letters, digits and "-_.:+/=" only.
+func Valid(id string) bool {
+	if len(id) == 0 || len(id) > MaxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
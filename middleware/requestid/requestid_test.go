@@ -0,0 +1,21 @@
+package requestid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValid(t *testing.T) {
+	require.True(t, Valid(New()))
+	require.True(t, Valid("req-1"))
+	require.True(t, Valid("3f2a8b9c-1d2e-4f5a-8b9c-0d1e2f3a4b5c"))
+	require.True(t, Valid(strings.Repeat("a", MaxLength)))
+
+	require.False(t, Valid(""))
+	require.False(t, Valid(strings.Repeat("a", MaxLength+1)))
+	require.False(t, Valid("req 1"))
+	require.False(t, Valid("req-1\nlevel=error"))
+	require.False(t, Valid(`req"1`))
+}
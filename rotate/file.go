@@ -0,0 +1,455 @@
+// Package rotate provides a rotating log file, that could be passed to suplog.NewLogger.
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options allows to set additional File options.
+type Options struct {
+	// MaxSize rotates the file before it grows larger than MaxSize bytes, zero disables.
+	MaxSize int64
+	// Interval rotates the file at each interval boundary, e.g. every hour, zero disables.
+	Interval time.Duration
+	// MaxBackups limits the amount of kept backups, zero keeps all of them.
+	MaxBackups int
+	// MaxAge removes backups older than MaxAge, zero keeps all of them.
+	MaxAge time.Duration
+	// Compress gzips backups in background.
+	Compress bool
+	// LocalTime uses local time in backup names and interval boundaries, UTC is used by default.
+	LocalTime bool
+	// FileMode of new files, defaults to 0644.
+	FileMode os.FileMode
+}
+
+func checkOptions(opt *Options) *Options {
+	if opt == nil {
+		opt = &Options{}
+	}
+
+	if opt.FileMode == 0 {
+		opt.FileMode = 0o644
+	}
+
+	return opt
+}
+
+// backupTimeFormat is used in backup names, e.g. app-2024-01-02T03-04-05.000.log,
+// backups rotated within the same millisecond get a sequence number, e.g. app-2024-01-02T03-04-05.000.1.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+const compressSuffix = ".gz"
+
+// ErrClosed is returned when writing into a closed file.
+var ErrClosed = errors.New("rotate: file is closed")
+
+// File is an io.WriteCloser that rotates by size and time. Backups are named after
+// the file with the rotation time, e.g. app.log is moved to app-2024-01-02T03-04-05.000.log
+type File struct {
+	opt  *Options
+	path string
+	now  func() time.Time
+
+	mux          sync.Mutex
+	file         *os.File
+	size         int64
+	nextRotation time.Time
+	closed       bool
+
+	// cleanupC wakes the background worker that compresses and removes backups.
+	cleanupC chan struct{}
+	doneC    chan struct{}
+}
+
+// NewFile opens or creates the file, appending to it. Parent directories are created.
+func NewFile(path string, opt *Options) (*File, error) {
+	f := &File{
+		opt:      checkOptions(opt),
+		path:     path,
+		now:      time.Now,
+		cleanupC: make(chan struct{}, 1),
+		doneC:    make(chan struct{}),
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	go f.runCleanup()
+
+	// backups could be left from the previous run
+	f.scheduleCleanup()
+
+	return f, nil
+}
+
+// Write writes into the file, rotating it first if needed.
+func (f *File) Write(p []byte) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.closed {
+		return 0, ErrClosed
+	}
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.needsRotation(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+func (f *File) needsRotation(writeLen int64) bool {
+	if f.opt.MaxSize > 0 && f.size > 0 && f.size+writeLen > f.opt.MaxSize {
+		return true
+	}
+
+	return f.opt.Interval > 0 && !f.now().Before(f.nextRotation)
+}
+
+// Rotate moves the current file into a backup and opens a new one.
+func (f *File) Rotate() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+
+	return f.rotate()
+}
+
+func (f *File) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+
+		f.file = nil
+	}
+
+	if err := os.Rename(f.path, f.backupName(f.now())); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotate: failed to move the file: %w", err)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.scheduleCleanup()
+
+	return nil
+}
+
+// Reopen closes and opens the file again, e.g. after it has been moved by logrotate.
+func (f *File) Reopen() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+
+	if f.file != nil {
+		_ = f.file.Close()
+		f.file = nil
+	}
+
+	return f.open()
+}
+
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("rotate: failed to create directory: %w", err)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, f.opt.FileMode)
+	if err != nil {
+		return fmt.Errorf("rotate: failed to open file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	if f.opt.Interval > 0 {
+		f.nextRotation = f.nextBoundary(f.now())
+	}
+
+	return nil
+}
+
+// nextBoundary returns the interval boundary after t, aligned in the location of backup names,
+// so daily rotation with LocalTime happens at local midnight.
+func (f *File) nextBoundary(t time.Time) time.Time {
+	if !f.opt.LocalTime {
+		return t.Truncate(f.opt.Interval).Add(f.opt.Interval)
+	}
+
+	t = t.In(time.Local)
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	next := t.Add(shift).Truncate(f.opt.Interval).Add(f.opt.Interval).Add(-shift)
+
+	// the offset may change before the boundary, e.g. on DST transitions
+	if _, nextOffset := next.Zone(); nextOffset != offset {
+		next = next.Add(shift - time.Duration(nextOffset)*time.Second)
+	}
+
+	return next
+}
+
+// Close closes the file, and waits until background compression is done.
+func (f *File) Close() error {
+	f.mux.Lock()
+	if f.closed {
+		f.mux.Unlock()
+		return ErrClosed
+	}
+
+	f.closed = true
+
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+
+	close(f.cleanupC)
+	f.mux.Unlock()
+
+	<-f.doneC
+
+	return err
+}
+
+// backupName returns the name of a backup, which doesn't exist yet, so no backup is overwritten.
+func (f *File) backupName(t time.Time) string {
+	if !f.opt.LocalTime {
+		t = t.UTC()
+	}
+
+	dir, name := filepath.Split(f.path)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext) + "-" + t.Format(backupTimeFormat)
+
+	path := filepath.Join(dir, base+ext)
+	for seq := 1; backupExists(path); seq++ {
+		path = filepath.Join(dir, base+"."+strconv.Itoa(seq)+ext)
+	}
+
+	return path
+}
+
+// backupExists checks the backup, and its compressed version too.
+func backupExists(path string) bool {
+	for _, p := range []string{path, path + compressSuffix} {
+		if _, err := os.Lstat(p); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *File) scheduleCleanup() {
+	select {
+	case f.cleanupC <- struct{}{}:
+	default:
+		// already scheduled
+	}
+}
+
+func (f *File) runCleanup() {
+	defer close(f.doneC)
+
+	for range f.cleanupC {
+		if err := f.cleanup(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to clean up log backups, %v\n", err)
+		}
+	}
+}
+
+type backup struct {
+	path string
+	time time.Time
+	seq  int
+}
+
+// backups lists backups of the file, newest first.
+func (f *File) backups() ([]backup, error) {
+	dir, name := filepath.Split(f.path)
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := time.UTC
+	if f.opt.LocalTime {
+		loc = time.Local
+	}
+
+	var backups []backup
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+
+		ts := strings.TrimPrefix(entry.Name(), prefix)
+		ts = strings.TrimSuffix(ts, compressSuffix)
+		if !strings.HasSuffix(ts, ext) {
+			continue
+		}
+
+		ts = strings.TrimSuffix(ts, ext)
+
+		var seq int
+		if len(ts) > len(backupTimeFormat) {
+			suffix := ts[len(backupTimeFormat):]
+			if !strings.HasPrefix(suffix, ".") {
+				continue
+			}
+
+			n, err := strconv.Atoi(suffix[1:])
+			if err != nil || n < 1 {
+				continue
+			}
+
+			ts, seq = ts[:len(backupTimeFormat)], n
+		}
+
+		t, err := time.ParseInLocation(backupTimeFormat, ts, loc)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, backup{
+			path: filepath.Join(dir, entry.Name()),
+			time: t,
+			seq:  seq,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].time.Equal(backups[j].time) {
+			return backups[i].seq > backups[j].seq
+		}
+
+		return backups[i].time.After(backups[j].time)
+	})
+
+	return backups, nil
+}
+
+// cleanup removes the backups over the limits, then compresses the rest.
+func (f *File) cleanup() error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	keep := backups[:0]
+
+	for i, b := range backups {
+		if (f.opt.MaxBackups > 0 && i >= f.opt.MaxBackups) ||
+			(f.opt.MaxAge > 0 && f.now().Sub(b.time) > f.opt.MaxAge) {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+
+			continue
+		}
+
+		keep = append(keep, b)
+	}
+
+	if f.opt.Compress {
+		for _, b := range keep {
+			if strings.HasSuffix(b.path, compressSuffix) {
+				continue
+			}
+
+			if err := compressFile(b.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// compressFile gzips the file, removing the original once done.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmpPath, path+compressSuffix)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("rotate: failed to compress %s: %w", path, err)
+	}
+
+	return os.Remove(path)
+}
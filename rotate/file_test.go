@@ -0,0 +1,256 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+// fakeClock returns a time that moves only when advanced.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestFile(t *testing.T, path string, opt *Options, clock *fakeClock) *File {
+	f, err := NewFile(path, opt)
+	require.NoError(t, err)
+
+	f.mux.Lock()
+	f.now = clock.now
+	if f.opt.Interval > 0 {
+		f.nextRotation = f.nextBoundary(clock.t)
+	}
+	f.mux.Unlock()
+
+	return f
+}
+
+func TestFile(t *testing.T) {
+	t.Run("rotates by size", func(t *testing.T) {
+		dir := t.TempDir()
+		clock := &fakeClock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+		f := newTestFile(t, filepath.Join(dir, "app.log"), &Options{
+			MaxSize:    10,
+			MaxBackups: 2,
+		}, clock)
+
+		for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+			_, err := f.Write([]byte(line))
+			require.NoError(t, err)
+			clock.t = clock.t.Add(time.Second)
+		}
+		require.NoError(t, f.Close())
+
+		require.Equal(t, []string{
+			"app-2024-01-02T03-04-07.000.log",
+			"app-2024-01-02T03-04-08.000.log",
+			"app.log",
+		}, listDir(t, dir))
+		require.Equal(t, "second\n", readFile(t, filepath.Join(dir, "app-2024-01-02T03-04-07.000.log")))
+		require.Equal(t, "fourth\n", readFile(t, filepath.Join(dir, "app.log")))
+	})
+
+	t.Run("keeps backups rotated at once", func(t *testing.T) {
+		dir := t.TempDir()
+		clock := &fakeClock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+		f := newTestFile(t, filepath.Join(dir, "app.log"), &Options{
+			MaxSize:    10,
+			MaxBackups: 2,
+		}, clock)
+
+		// the clock doesn't move, so all rotations happen within the same millisecond
+		for _, line := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+			_, err := f.Write([]byte(line))
+			require.NoError(t, err)
+		}
+		require.NoError(t, f.Close())
+
+		require.Equal(t, []string{
+			"app-2024-01-02T03-04-05.000.1.log",
+			"app-2024-01-02T03-04-05.000.2.log",
+			"app.log",
+		}, listDir(t, dir))
+		require.Equal(t, "second line\n", readFile(t, filepath.Join(dir, "app-2024-01-02T03-04-05.000.1.log")))
+		require.Equal(t, "third line\n", readFile(t, filepath.Join(dir, "app-2024-01-02T03-04-05.000.2.log")))
+		require.Equal(t, "fourth line\n", readFile(t, filepath.Join(dir, "app.log")))
+	})
+
+	t.Run("rotates by interval", func(t *testing.T) {
+		dir := t.TempDir()
+		clock := &fakeClock{t: time.Date(2024, 1, 2, 3, 59, 0, 0, time.UTC)}
+		f := newTestFile(t, filepath.Join(dir, "app.log"), &Options{
+			Interval: time.Hour,
+		}, clock)
+
+		_, err := f.Write([]byte("before\n"))
+		require.NoError(t, err)
+
+		clock.t = clock.t.Add(time.Minute)
+		_, err = f.Write([]byte("after\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.Equal(t, []string{"app-2024-01-02T04-00-00.000.log", "app.log"}, listDir(t, dir))
+		require.Equal(t, "before\n", readFile(t, filepath.Join(dir, "app-2024-01-02T04-00-00.000.log")))
+		require.Equal(t, "after\n", readFile(t, filepath.Join(dir, "app.log")))
+	})
+
+	t.Run("rotates by interval in local time", func(t *testing.T) {
+		local := time.Local
+		time.Local = time.FixedZone("IST", 5*3600+1800)
+		defer func() { time.Local = local }()
+
+		dir := t.TempDir()
+		clock := &fakeClock{t: time.Date(2024, 1, 2, 23, 59, 0, 0, time.Local)}
+		f := newTestFile(t, filepath.Join(dir, "app.log"), &Options{
+			Interval:  24 * time.Hour,
+			LocalTime: true,
+		}, clock)
+
+		_, err := f.Write([]byte("before\n"))
+		require.NoError(t, err)
+
+		clock.t = clock.t.Add(time.Minute)
+		_, err = f.Write([]byte("after\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.Equal(t, []string{"app-2024-01-03T00-00-00.000.log", "app.log"}, listDir(t, dir))
+		require.Equal(t, "before\n", readFile(t, filepath.Join(dir, "app-2024-01-03T00-00-00.000.log")))
+	})
+
+	t.Run("interval boundary across DST transition", func(t *testing.T) {
+		loc, err := time.LoadLocation("America/New_York")
+		if err != nil {
+			t.Skip("no time zone data:", err)
+		}
+
+		local := time.Local
+		time.Local = loc
+		defer func() { time.Local = local }()
+
+		f := &File{opt: &Options{Interval: 24 * time.Hour, LocalTime: true}}
+		next := f.nextBoundary(time.Date(2024, 3, 10, 1, 0, 0, 0, loc))
+		require.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, loc), next)
+	})
+
+	t.Run("removes old backups", func(t *testing.T) {
+		dir := t.TempDir()
+		old := filepath.Join(dir, "app-2024-01-01T00-00-00.000.log")
+		fresh := filepath.Join(dir, "app-2024-01-02T00-00-00.000.log")
+		unrelated := filepath.Join(dir, "other-2024-01-01T00-00-00.000.log")
+		for _, path := range []string{old, fresh, unrelated} {
+			require.NoError(t, os.WriteFile(path, []byte("backup\n"), 0o644))
+		}
+
+		clock := &fakeClock{t: time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)}
+		f := newTestFile(t, filepath.Join(dir, "app.log"), &Options{
+			MaxAge: 24 * time.Hour,
+		}, clock)
+		require.NoError(t, f.Rotate())
+		require.NoError(t, f.Close())
+
+		require.Equal(t, []string{
+			"app-2024-01-02T00-00-00.000.log",
+			"app-2024-01-02T12-00-00.000.log",
+			"app.log",
+			"other-2024-01-01T00-00-00.000.log",
+		}, listDir(t, dir))
+	})
+
+	t.Run("compresses backups", func(t *testing.T) {
+		dir := t.TempDir()
+		clock := &fakeClock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+		f := newTestFile(t, filepath.Join(dir, "app.log"), &Options{
+			Compress:   true,
+			MaxBackups: 1,
+		}, clock)
+
+		_, err := f.Write([]byte("compressed\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Rotate())
+		require.NoError(t, f.Close())
+
+		require.Equal(t, []string{"app-2024-01-02T03-04-05.000.log.gz", "app.log"}, listDir(t, dir))
+
+		gz, err := os.Open(filepath.Join(dir, "app-2024-01-02T03-04-05.000.log.gz"))
+		require.NoError(t, err)
+		defer gz.Close()
+
+		zr, err := gzip.NewReader(gz)
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Equal(t, "compressed\n", string(data))
+	})
+
+	t.Run("reopens moved file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		f, err := NewFile(path, nil)
+		require.NoError(t, err)
+
+		_, err = f.Write([]byte("before\n"))
+		require.NoError(t, err)
+
+		require.NoError(t, os.Rename(path, path+".1"))
+		require.NoError(t, f.Reopen())
+
+		_, err = f.Write([]byte("after\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.Equal(t, "before\n", readFile(t, path+".1"))
+		require.Equal(t, "after\n", readFile(t, path))
+
+		_, err = f.Write([]byte("closed\n"))
+		require.ErrorIs(t, err, ErrClosed)
+	})
+
+	t.Run("plugs into logger", func(t *testing.T) {
+		dir := t.TempDir()
+		f, err := NewFile(filepath.Join(dir, "logs", "app.log"), nil)
+		require.NoError(t, err)
+
+		l := suplog.NewLogger(f, new(suplog.TextFormatter))
+		l.Info("into the file")
+		require.NoError(t, l.(interface{ Close() error }).Close())
+
+		require.True(t, strings.Contains(readFile(t, filepath.Join(dir, "logs", "app.log")), "into the file"))
+		require.ErrorIs(t, f.Close(), ErrClosed)
+	})
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchSignals reopens the file on SIGHUP, as expected by logrotate.
+// Call stop to unsubscribe.
+func (f *File) WatchSignals() (stop func()) {
+	sigC := make(chan os.Signal, 1)
+	doneC := make(chan struct{})
+	signal.Notify(sigC, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-doneC:
+				return
+			case <-sigC:
+				if err := f.Reopen(); err != nil && err != ErrClosed {
+					fmt.Fprintf(os.Stderr, "Failed to reopen log file, %v\n", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigC)
+		close(doneC)
+	}
+}
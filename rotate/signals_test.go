@@ -0,0 +1,31 @@
+//go:build !windows
+
+package rotate
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchSignals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := NewFile(path, nil)
+	require.NoError(t, err)
+	defer f.Close()
+
+	stop := f.WatchSignals()
+	defer stop()
+
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
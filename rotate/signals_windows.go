@@ -0,0 +1,6 @@
+package rotate
+
+// WatchSignals is a no-op on Windows, since there is no SIGHUP.
+func (f *File) WatchSignals() (stop func()) {
+	return func() {}
+}
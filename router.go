@@ -0,0 +1,239 @@
+package suplog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sink is an output of a Router, receiving entries of the selected levels.
+type Sink struct {
+	// Name is used in error messages and stats, defaults to the sink index.
+	Name string
+	// Levels selects entries written to the sink, nil means all levels.
+	Levels []Level
+	// Formatter of the sink, defaults to JSONFormatter.
+	Formatter Formatter
+	// Writer of the sink, closed by Router.Close if it implements io.WriteCloser.
+	Writer io.Writer
+}
+
+// SinkStats reports counters of a sink.
+type SinkStats struct {
+	Name string
+	// Written is the number of entries written so far.
+	Written uint64
+	// Errors is the number of entries failed to be formatted or written.
+	Errors uint64
+}
+
+// LevelRange returns all levels between from and to, inclusive,
+// e.g. LevelRange(PanicLevel, ErrorLevel) or LevelRange(InfoLevel, TraceLevel).
+func LevelRange(from, to Level) []Level {
+	if from > to {
+		from, to = to, from
+	}
+
+	levels := make([]Level, 0, to-from+1)
+	for level := from; level <= to; level++ {
+		levels = append(levels, level)
+	}
+
+	return levels
+}
+
+// ErrRouterClosed is returned when closing a router twice.
+var ErrRouterClosed = errors.New("router is closed")
+
+// Router writes entries into multiple sinks, each with its own levels, formatter and writer.
+// It implements Formatter, so it can see entries after all hooks have been fired,
+// and io.WriteCloser, so closing the logger closes the sinks. A sink failing to format
+// or write an entry doesn't prevent other sinks from receiving it.
+//
+//	router := suplog.NewRouter(
+//		suplog.Sink{Levels: suplog.LevelRange(suplog.PanicLevel, suplog.ErrorLevel), Writer: os.Stderr},
+//		suplog.Sink{Levels: suplog.LevelRange(suplog.InfoLevel, suplog.TraceLevel), Writer: os.Stdout},
+//	)
+//	log := suplog.NewLogger(router, router)
+type Router struct {
+	mux    sync.Mutex
+	sinks  []*sink
+	closed bool
+}
+
+type sink struct {
+	Sink
+
+	levels [TraceLevel + 1]bool
+	buf    bytes.Buffer
+
+	// shadow logger is passed to formatters, so they could detect the sink writer, e.g. TTY.
+	shadow *logrus.Logger
+
+	written uint64
+	errors  uint64
+}
+
+// NewRouter constructs a new Router writing into the given sinks.
+func NewRouter(sinks ...Sink) *Router {
+	r := &Router{
+		sinks: make([]*sink, 0, len(sinks)),
+	}
+
+	for i, s := range sinks {
+		if len(s.Name) == 0 {
+			s.Name = fmt.Sprintf("sink%d", i)
+		}
+
+		if s.Formatter == nil {
+			s.Formatter = new(JSONFormatter)
+		}
+
+		if s.Writer == nil {
+			s.Writer = io.Discard
+		}
+
+		levels := s.Levels
+		if levels == nil {
+			levels = logrus.AllLevels
+		}
+
+		rs := &sink{
+			Sink: s,
+			shadow: &logrus.Logger{
+				Out:       s.Writer,
+				Formatter: s.Formatter,
+				Hooks:     make(LevelHooks),
+			},
+		}
+
+		for _, level := range levels {
+			if int(level) < len(rs.levels) {
+				rs.levels[level] = true
+			}
+		}
+
+		r.sinks = append(r.sinks, rs)
+	}
+
+	return r
+}
+
+// NewRoutedLogger constructs a new suplogger writing into the given sinks.
+func NewRoutedLogger(sinks []Sink, hooks ...Hook) Logger {
+	router := NewRouter(sinks...)
+	return NewLogger(router, router, hooks...)
+}
+
+// Format writes the entry into every sink enabled for its level, the result is always empty.
+func (r *Router) Format(e *logrus.Entry) ([]byte, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.closed {
+		return nil, nil
+	}
+
+	var errs []error
+	for _, s := range r.sinks {
+		if int(e.Level) >= len(s.levels) || !s.levels[e.Level] {
+			continue
+		}
+
+		if err := s.write(e); err != nil {
+			s.errors++
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.Name, err))
+			continue
+		}
+
+		s.written++
+	}
+
+	// reported by logrus as "Failed to obtain reader", after all sinks got the entry
+	return nil, errors.Join(errs...)
+}
+
+func (s *sink) write(e *logrus.Entry) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+
+	entry := *e
+	entry.Logger = s.shadow
+	s.buf.Reset()
+	entry.Buffer = &s.buf
+
+	serialized, err := s.Formatter.Format(&entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Writer.Write(serialized)
+	return err
+}
+
+// Write discards p, since entries are written into sinks by Format.
+func (r *Router) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Close closes writers of the sinks, which implement io.WriteCloser.
+// Writers shared between sinks are closed once.
+func (r *Router) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.closed {
+		return ErrRouterClosed
+	}
+
+	r.closed = true
+
+	var errs []error
+	closed := make(map[io.Writer]struct{}, len(r.sinks))
+	for _, s := range r.sinks {
+		closer, ok := s.Writer.(io.WriteCloser)
+		if !ok || isStdStream(s.Writer) {
+			continue
+		}
+
+		if _, ok := closed[s.Writer]; ok {
+			continue
+		}
+
+		closed[s.Writer] = struct{}{}
+		if err := closer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Stats returns counters of the sinks, in the order they were passed to NewRouter.
+func (r *Router) Stats() []SinkStats {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	stats := make([]SinkStats, 0, len(r.sinks))
+	for _, s := range r.sinks {
+		stats = append(stats, SinkStats{
+			Name:    s.Name,
+			Written: s.written,
+			Errors:  s.errors,
+		})
+	}
+
+	return stats
+}
+
+func isStdStream(wr io.Writer) bool {
+	return wr == os.Stdout || wr == os.Stderr
+}
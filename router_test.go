@@ -0,0 +1,119 @@
+package suplog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk is full")
+}
+
+type panickingFormatter struct{}
+
+func (panickingFormatter) Format(*Entry) ([]byte, error) {
+	panic("boom")
+}
+
+type closeCounter struct {
+	strings.Builder
+	closed int
+}
+
+func (w *closeCounter) Close() error {
+	w.closed++
+	return nil
+}
+
+func TestRouter(t *testing.T) {
+	t.Run("routes by levels", func(t *testing.T) {
+		var stdout, stderr, alerts strings.Builder
+		l := NewRoutedLogger([]Sink{{
+			Levels:    LevelRange(InfoLevel, TraceLevel),
+			Formatter: &TextFormatter{DisableTimestamp: true},
+			Writer:    &stdout,
+		}, {
+			Levels:    LevelRange(PanicLevel, ErrorLevel),
+			Formatter: &TextFormatter{DisableTimestamp: true},
+			Writer:    &stderr,
+		}, {
+			Levels:    LevelRange(ErrorLevel, PanicLevel),
+			Formatter: new(JSONFormatter),
+			Writer:    &alerts,
+		}})
+
+		l.Info("started")
+		l.Debug("details")
+		l.Warning("ignored")
+		l.WithField("module", "accounts").Error("failed")
+
+		require.Equal(t, "level=info msg=started\nlevel=debug msg=details\n", stdout.String())
+		require.Equal(t, "level=error msg=failed module=accounts\n", stderr.String())
+		require.Contains(t, alerts.String(), `"msg":"failed"`)
+		require.NotContains(t, alerts.String(), "started")
+	})
+
+	t.Run("failing sink doesn't affect others", func(t *testing.T) {
+		var out strings.Builder
+		router := NewRouter(Sink{
+			Name:   "broken",
+			Writer: failingWriter{},
+		}, Sink{
+			Name:      "panicking",
+			Formatter: panickingFormatter{},
+		}, Sink{
+			Name:   "stdout",
+			Writer: &out,
+		})
+
+		l := NewLogger(router, router)
+		l.Info("first")
+		l.Info("second")
+
+		require.Equal(t, 2, strings.Count(out.String(), "\n"))
+		require.Equal(t, []SinkStats{
+			{Name: "broken", Errors: 2},
+			{Name: "panicking", Errors: 2},
+			{Name: "stdout", Written: 2},
+		}, router.Stats())
+	})
+
+	t.Run("closes writers once", func(t *testing.T) {
+		wr := new(closeCounter)
+		l := NewRoutedLogger([]Sink{
+			{Writer: wr},
+			{Levels: LevelRange(PanicLevel, ErrorLevel), Writer: wr},
+		})
+
+		l.Error("twice")
+		require.Equal(t, 2, strings.Count(wr.String(), "twice"))
+
+		require.NoError(t, l.(interface{ Close() error }).Close())
+		require.Equal(t, 1, wr.closed)
+
+		l.Error("after close")
+		require.NotContains(t, wr.String(), "after close")
+	})
+
+	t.Run("async output", func(t *testing.T) {
+		var errOut, out strings.Builder
+		router := NewRouter(
+			Sink{Levels: LevelRange(PanicLevel, ErrorLevel), Writer: &errOut},
+			Sink{Writer: &out},
+		)
+
+		l := NewLogger(router, router, AsyncOutput(nil)).(AsyncLogger)
+		l.Error("async error")
+		l.Info("async info")
+		require.NoError(t, l.Close())
+
+		require.Contains(t, errOut.String(), "async error")
+		require.NotContains(t, errOut.String(), "async info")
+		require.Equal(t, 2, strings.Count(out.String(), "\n"))
+	})
+}
@@ -0,0 +1,510 @@
+package suplog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SamplerOptions allows to set additional Sampler options.
+type SamplerOptions struct {
+	// Interval is the sampling window, counters are reset after each window.
+	// Defaults to 1 second.
+	Interval time.Duration
+	// First is the number of messages with the same key passed in each window.
+	// Defaults to 10.
+	First int
+	// Thereafter allows every Mth message after the first N, zero means
+	// that all messages after the first N are suppressed.
+	Thereafter int
+	// MaxKeys limits the amount of tracked keys, messages with new keys
+	// are passed without sampling once the limit is reached. Defaults to 4096.
+	MaxKeys int
+}
+
+func checkSamplerOptions(opt *SamplerOptions) *SamplerOptions {
+	if opt == nil {
+		opt = &SamplerOptions{}
+	}
+
+	if opt.Interval <= 0 {
+		opt.Interval = time.Second
+	}
+
+	if opt.First <= 0 {
+		opt.First = 10
+	}
+
+	if opt.Thereafter < 0 {
+		opt.Thereafter = 0
+	}
+
+	if opt.MaxKeys <= 0 {
+		opt.MaxKeys = 4096
+	}
+
+	return opt
+}
+
+// Sampler limits the rate of messages keyed by level and format string,
+// or by an explicit key. After each window, a summary entry with the count
+// of suppressed messages is logged for each key that had any.
+type Sampler struct {
+	opt *SamplerOptions
+	now func() time.Time
+
+	// counters are *sampleCounter by key, updated without locks, since
+	// the sampler is shared by loggers of many goroutines.
+	counters sync.Map
+	keys     int64
+
+	closeOnce sync.Once
+	stopC     chan struct{}
+	doneC     chan struct{}
+}
+
+type sampleCounter struct {
+	// windowStart and lastSeen are Unix nanoseconds
+	windowStart int64
+	lastSeen    int64
+	count       int64
+	suppressed  int64
+
+	// target of the last suppressed message is used to log the summary
+	target atomic.Pointer[sampleTarget]
+}
+
+type sampleTarget struct {
+	logger Logger
+	level  Level
+}
+
+// NewSampler constructs a new Sampler, it can be shared by many loggers.
+// Summaries are logged in background every interval, until the sampler is closed.
+func NewSampler(opt *SamplerOptions) *Sampler {
+	s := &Sampler{
+		opt:   checkSamplerOptions(opt),
+		now:   time.Now,
+		stopC: make(chan struct{}),
+		doneC: make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
+
+// run logs summaries of expired windows, so these are reported after a burst stops too.
+func (s *Sampler) run() {
+	defer close(s.doneC)
+
+	ticker := time.NewTicker(s.opt.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stopC:
+			return
+		}
+
+		logSummaries(s.expire(s.now()))
+	}
+}
+
+// Close stops logging summaries in background, and logs the pending ones, see Flush.
+func (s *Sampler) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stopC)
+		<-s.doneC
+	})
+
+	s.Flush()
+
+	return nil
+}
+
+// Allow checks whether a message with the key should be logged. Logger and level
+// are used to log the summary of suppressed messages later.
+func (s *Sampler) Allow(key string, logger Logger, level Level) bool {
+	now := s.now().UnixNano()
+
+	v, ok := s.counters.Load(key)
+	if !ok {
+		if atomic.LoadInt64(&s.keys) >= int64(s.opt.MaxKeys) {
+			return true
+		}
+
+		if v, ok = s.counters.LoadOrStore(key, &sampleCounter{windowStart: now}); !ok {
+			atomic.AddInt64(&s.keys, 1)
+		}
+	}
+
+	c := v.(*sampleCounter)
+	atomic.StoreInt64(&c.lastSeen, now)
+
+	if summary, ok := c.reset(key, now, s.opt.Interval, false); ok {
+		logSummaries([]sampleSummary{summary})
+	}
+
+	n := int(atomic.AddInt64(&c.count, 1))
+
+	allowed := n <= s.opt.First
+	if !allowed && s.opt.Thereafter > 0 {
+		allowed = (n-s.opt.First)%s.opt.Thereafter == 0
+	}
+
+	if !allowed {
+		// target is set first, so the summary of suppressed messages always has one
+		c.target.Store(&sampleTarget{logger: logger, level: level})
+		atomic.AddInt64(&c.suppressed, 1)
+	}
+
+	return allowed
+}
+
+// Flush logs summaries for all keys with suppressed messages and resets
+// the counters, e.g. before shutdown.
+func (s *Sampler) Flush() {
+	now := s.now().UnixNano()
+
+	var summaries []sampleSummary
+	s.counters.Range(func(k, v interface{}) bool {
+		if summary, ok := v.(*sampleCounter).reset(k.(string), now, s.opt.Interval, true); ok {
+			summaries = append(summaries, summary)
+		}
+
+		s.delete(k, v)
+		return true
+	})
+
+	logSummaries(summaries)
+}
+
+// expire collects summaries of expired windows, dropping the keys that were not seen for the whole interval.
+func (s *Sampler) expire(now time.Time) (summaries []sampleSummary) {
+	nowNano := now.UnixNano()
+
+	s.counters.Range(func(k, v interface{}) bool {
+		c := v.(*sampleCounter)
+		if summary, ok := c.reset(k.(string), nowNano, s.opt.Interval, false); ok {
+			summaries = append(summaries, summary)
+		}
+
+		if nowNano-atomic.LoadInt64(&c.lastSeen) >= int64(s.opt.Interval) {
+			s.delete(k, v)
+		}
+
+		return true
+	})
+
+	return summaries
+}
+
+func (s *Sampler) delete(k, v interface{}) {
+	if s.counters.CompareAndDelete(k, v) {
+		atomic.AddInt64(&s.keys, -1)
+	}
+}
+
+type sampleSummary struct {
+	key        string
+	logger     Logger
+	level      Level
+	suppressed int
+	interval   time.Duration
+}
+
+// reset starts a new window once the current one is over, or right away if forced. The window
+// start is swapped with CAS, so only one of concurrent callers collects the summary.
+func (c *sampleCounter) reset(key string, now int64, interval time.Duration, force bool) (sampleSummary, bool) {
+	start := atomic.LoadInt64(&c.windowStart)
+	if !force && now-start < int64(interval) {
+		return sampleSummary{}, false
+	}
+
+	if !atomic.CompareAndSwapInt64(&c.windowStart, start, now) {
+		return sampleSummary{}, false
+	}
+
+	atomic.StoreInt64(&c.count, 0)
+	suppressed := atomic.SwapInt64(&c.suppressed, 0)
+
+	target := c.target.Load()
+	if suppressed == 0 || target == nil {
+		return sampleSummary{}, false
+	}
+
+	return sampleSummary{
+		key:        key,
+		logger:     target.logger,
+		level:      target.level,
+		suppressed: int(suppressed),
+		interval:   interval,
+	}, true
+}
+
+func logSummaries(summaries []sampleSummary) {
+	for _, s := range summaries {
+		summaryLogger(s.logger).WithFields(Fields{
+			"sampled_key": s.key,
+			"suppressed":  s.suppressed,
+		}).Logf(s.level, "suppressed %d messages like %q in %s", s.suppressed, s.key, s.interval)
+	}
+}
+
+// summaryLogger drops fields of the last suppressed message, except for the module field,
+// since these don't describe the suppressed messages as a whole.
+func summaryLogger(logger Logger) Logger {
+	for {
+		sl, ok := logger.(*sampledLogger)
+		if !ok {
+			break
+		}
+
+		logger = sl.Logger
+	}
+
+	l, ok := logger.(*suplogger)
+	if !ok {
+		return logger
+	}
+
+	l.initOnce()
+
+	out := l.copy()
+	out.entry = l.logger.WithContext(context.Background())
+	if module, ok := l.entry.Data[ModuleKey]; ok {
+		out.entry = out.entry.WithField(ModuleKey, module)
+	}
+
+	return out
+}
+
+// OnSample returns a logger if the sampler allows a message with the explicit key,
+// otherwise returns NoOp logger. Only the first logger in the variadic argument is used,
+// if provided. The summary of suppressed messages is logged at Info level.
+func OnSample(sampler *Sampler, key string, logger ...Logger) ConditionLogger {
+	l := getLogger(logger...)
+	if sampler.Allow(key, l, InfoLevel) {
+		return l
+	}
+
+	return NoOp
+}
+
+// Sampled wraps the logger, so each message is passed through the sampler,
+// keyed by its level and format string (or the first argument for non-formatted
+// methods). Fatal and Panic messages are never sampled.
+func Sampled(logger Logger, sampler *Sampler) ConditionLogger {
+	if logger == nil {
+		logger = DefaultLogger
+	}
+
+	return &sampledLogger{
+		Logger:  logger,
+		sampler: sampler,
+	}
+}
+
+var _ ConditionLogger = (*sampledLogger)(nil)
+
+type sampledLogger struct {
+	Logger
+	sampler *Sampler
+}
+
+func (l *sampledLogger) allow(level Level, format string) bool {
+	if level <= FatalLevel {
+		return true
+	}
+
+	return l.sampler.Allow(level.String()+": "+format, l.Logger, level)
+}
+
+func (l *sampledLogger) allowArgs(level Level, args []interface{}) bool {
+	if len(args) > 0 {
+		if msg, ok := args[0].(string); ok {
+			return l.allow(level, msg)
+		}
+	}
+
+	return l.allow(level, fmt.Sprint(args...))
+}
+
+func (l *sampledLogger) wrap(logger Logger) Logger {
+	return &sampledLogger{
+		Logger:  logger,
+		sampler: l.sampler,
+	}
+}
+
+func (l *sampledLogger) Do(fn func(Logger)) {
+	fn(l)
+}
+
+func (l *sampledLogger) WithField(key string, value interface{}) Logger {
+	return l.wrap(l.Logger.WithField(key, value))
+}
+
+func (l *sampledLogger) WithFields(fields Fields) Logger {
+	return l.wrap(l.Logger.WithFields(fields))
+}
+
+func (l *sampledLogger) WithError(err error) Logger {
+	return l.wrap(l.Logger.WithError(err))
+}
+
+func (l *sampledLogger) WithContext(ctx context.Context) Logger {
+	return l.wrap(l.Logger.WithContext(ctx))
+}
+
+func (l *sampledLogger) WithTime(t time.Time) Logger {
+	return l.wrap(l.Logger.WithTime(t))
+}
+
+func (l *sampledLogger) Defer(key string, value interface{}) Logger {
+	return l.wrap(l.Logger.Defer(key, value))
+}
+
+func (l *sampledLogger) DeferError(err *error) Logger {
+	return l.wrap(l.Logger.DeferError(err))
+}
+
+func (l *sampledLogger) ErrLevel(level Level) Logger {
+	return l.wrap(l.Logger.ErrLevel(level))
+}
+
+func (l *sampledLogger) Success(format string, args ...interface{}) {
+	if l.allow(InfoLevel, format) {
+		l.Logger.Success(format, args...)
+	}
+}
+
+func (l *sampledLogger) Warning(format string, args ...interface{}) {
+	if l.allow(WarnLevel, format) {
+		l.Logger.Warning(format, args...)
+	}
+}
+
+func (l *sampledLogger) Error(format string, args ...interface{}) {
+	if l.allow(ErrorLevel, format) {
+		l.Logger.Error(format, args...)
+	}
+}
+
+func (l *sampledLogger) Debug(format string, args ...interface{}) {
+	if l.allow(DebugLevel, format) {
+		l.Logger.Debug(format, args...)
+	}
+}
+
+func (l *sampledLogger) Logf(level Level, format string, args ...interface{}) {
+	if l.allow(level, format) {
+		l.Logger.Logf(level, format, args...)
+	}
+}
+
+func (l *sampledLogger) Tracef(format string, args ...interface{}) {
+	if l.allow(TraceLevel, format) {
+		l.Logger.Tracef(format, args...)
+	}
+}
+
+func (l *sampledLogger) Debugf(format string, args ...interface{}) {
+	if l.allow(DebugLevel, format) {
+		l.Logger.Debugf(format, args...)
+	}
+}
+
+func (l *sampledLogger) Infof(format string, args ...interface{}) {
+	if l.allow(InfoLevel, format) {
+		l.Logger.Infof(format, args...)
+	}
+}
+
+func (l *sampledLogger) Printf(format string, args ...interface{}) {
+	if l.allow(InfoLevel, format) {
+		l.Logger.Printf(format, args...)
+	}
+}
+
+func (l *sampledLogger) Warningf(format string, args ...interface{}) {
+	if l.allow(WarnLevel, format) {
+		l.Logger.Warningf(format, args...)
+	}
+}
+
+func (l *sampledLogger) Errorf(format string, args ...interface{}) {
+	if l.allow(ErrorLevel, format) {
+		l.Logger.Errorf(format, args...)
+	}
+}
+
+func (l *sampledLogger) Log(level Level, args ...interface{}) {
+	if l.allowArgs(level, args) {
+		l.Logger.Log(level, args...)
+	}
+}
+
+func (l *sampledLogger) Trace(args ...interface{}) {
+	if l.allowArgs(TraceLevel, args) {
+		l.Logger.Trace(args...)
+	}
+}
+
+func (l *sampledLogger) Info(args ...interface{}) {
+	if l.allowArgs(InfoLevel, args) {
+		l.Logger.Info(args...)
+	}
+}
+
+func (l *sampledLogger) Print(args ...interface{}) {
+	if l.allowArgs(InfoLevel, args) {
+		l.Logger.Print(args...)
+	}
+}
+
+func (l *sampledLogger) Logln(level Level, args ...interface{}) {
+	if l.allowArgs(level, args) {
+		l.Logger.Logln(level, args...)
+	}
+}
+
+func (l *sampledLogger) Traceln(args ...interface{}) {
+	if l.allowArgs(TraceLevel, args) {
+		l.Logger.Traceln(args...)
+	}
+}
+
+func (l *sampledLogger) Debugln(args ...interface{}) {
+	if l.allowArgs(DebugLevel, args) {
+		l.Logger.Debugln(args...)
+	}
+}
+
+func (l *sampledLogger) Infoln(args ...interface{}) {
+	if l.allowArgs(InfoLevel, args) {
+		l.Logger.Infoln(args...)
+	}
+}
+
+func (l *sampledLogger) Println(args ...interface{}) {
+	if l.allowArgs(InfoLevel, args) {
+		l.Logger.Println(args...)
+	}
+}
+
+func (l *sampledLogger) Warningln(args ...interface{}) {
+	if l.allowArgs(WarnLevel, args) {
+		l.Logger.Warningln(args...)
+	}
+}
+
+func (l *sampledLogger) Errorln(args ...interface{}) {
+	if l.allowArgs(ErrorLevel, args) {
+		l.Logger.Errorln(args...)
+	}
+}
@@ -0,0 +1,242 @@
+package suplog
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampled(t *testing.T) {
+	t.Run("first N then every Mth", func(t *testing.T) {
+		var recorder strings.Builder
+		l := NewLogger(&recorder, new(TextFormatter))
+
+		sampler := NewSampler(&SamplerOptions{
+			Interval:   time.Hour,
+			First:      2,
+			Thereafter: 3,
+		})
+		sl := Sampled(l, sampler)
+
+		for i := 1; i <= 10; i++ {
+			sl.WithField("i", i).Debugf("processing block %d", i)
+		}
+
+		out := recorder.String()
+		require.Contains(t, out, "processing block 1\"")
+		require.Contains(t, out, "processing block 2\"")
+		require.NotContains(t, out, "processing block 3\"")
+		require.Contains(t, out, "processing block 5\"")
+		require.Contains(t, out, "processing block 8\"")
+		require.NotContains(t, out, "processing block 10\"")
+
+		// other format strings and levels are sampled separately
+		sl.Warningf("processing block %d", 11)
+		require.Contains(t, recorder.String(), "processing block 11\"")
+
+		sampler.Flush()
+		out = recorder.String()
+		require.Contains(t, out, "suppressed=6")
+		require.Contains(t, out, `sampled_key="debug: processing block %d"`)
+	})
+
+	t.Run("summary after interval", func(t *testing.T) {
+		var recorder strings.Builder
+		l := NewLogger(&recorder, new(TextFormatter))
+
+		now := time.Now()
+		sampler := NewSampler(&SamplerOptions{
+			Interval: time.Minute,
+			First:    1,
+		})
+		sampler.now = func() time.Time { return now }
+
+		sl := Sampled(l, sampler)
+		for i := 0; i < 5; i++ {
+			sl.Info("tick")
+		}
+		require.Equal(t, 1, strings.Count(recorder.String(), "msg=tick"))
+
+		now = now.Add(time.Minute)
+		sl.Info("tick")
+
+		out := recorder.String()
+		require.Equal(t, 2, strings.Count(out, "msg=tick"))
+		require.Contains(t, out, "suppressed=4")
+	})
+
+	t.Run("summary after burst stops", func(t *testing.T) {
+		wr := newBlockingWriter()
+		close(wr.releaseC)
+		l := NewLogger(wr, new(TextFormatter))
+
+		sampler := NewSampler(&SamplerOptions{
+			Interval: 20 * time.Millisecond,
+			First:    1,
+		})
+		defer sampler.Close()
+
+		sl := Sampled(l, sampler)
+		for i := 0; i < 5; i++ {
+			sl.Info("burst")
+		}
+
+		// no more messages, the summary is logged by the ticker
+		require.Eventually(t, func() bool {
+			return strings.Contains(wr.String(), "suppressed=4")
+		}, time.Second, 5*time.Millisecond)
+		require.Equal(t, 1, strings.Count(wr.String(), "msg=burst"))
+
+		require.NoError(t, sampler.Close())
+		require.Equal(t, 1, strings.Count(wr.String(), "suppressed="))
+	})
+
+	t.Run("concurrent messages", func(t *testing.T) {
+		wr := newBlockingWriter()
+		close(wr.releaseC)
+		l := NewLogger(wr, new(TextFormatter))
+		sampler := NewSampler(&SamplerOptions{
+			Interval: time.Hour,
+			First:    10,
+		})
+
+		var (
+			wg      sync.WaitGroup
+			allowed int64
+		)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 1000; j++ {
+					if sampler.Allow("hot", l, InfoLevel) {
+						atomic.AddInt64(&allowed, 1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.EqualValues(t, 10, allowed)
+
+		sampler.Flush()
+		require.Contains(t, wr.String(), "suppressed=7990")
+	})
+
+	t.Run("window is reset once", func(t *testing.T) {
+		c := &sampleCounter{suppressed: 5}
+		c.target.Store(&sampleTarget{logger: NoOp, level: InfoLevel})
+
+		var (
+			wg        sync.WaitGroup
+			summaries int64
+		)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if _, ok := c.reset("key", int64(time.Hour), time.Minute, false); ok {
+					atomic.AddInt64(&summaries, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.EqualValues(t, 1, summaries)
+	})
+
+	t.Run("keys expire by last seen time", func(t *testing.T) {
+		now := time.Now()
+		sampler := NewSampler(&SamplerOptions{
+			Interval: time.Minute,
+			First:    1,
+		})
+		defer sampler.Close()
+		sampler.now = func() time.Time { return now }
+
+		sampler.Allow("idle", NoOp, InfoLevel)
+		now = now.Add(59 * time.Second)
+		sampler.Allow("hot", NoOp, InfoLevel)
+
+		// a window of both keys is over, but only the idle one was not seen for the whole interval
+		now = now.Add(time.Second)
+		sampler.expire(now)
+
+		_, idle := sampler.counters.Load("idle")
+		_, hot := sampler.counters.Load("hot")
+		require.False(t, idle)
+		require.True(t, hot)
+		require.EqualValues(t, 1, atomic.LoadInt64(&sampler.keys))
+	})
+
+	t.Run("summary without message fields", func(t *testing.T) {
+		var recorder strings.Builder
+		l := NewLogger(&recorder, new(TextFormatter)).WithField(ModuleKey, "indexer")
+		sampler := NewSampler(&SamplerOptions{
+			Interval: time.Hour,
+			First:    1,
+		})
+
+		sl := Sampled(l, sampler)
+		for i := 0; i < 3; i++ {
+			sl.WithField("height", i).Info("indexed")
+		}
+
+		recorder.Reset()
+		sampler.Flush()
+
+		out := recorder.String()
+		require.Contains(t, out, "suppressed=2")
+		require.Contains(t, out, "module=indexer")
+		require.NotContains(t, out, "height=")
+	})
+
+	t.Run("never samples fatal and panic", func(t *testing.T) {
+		var recorder strings.Builder
+		l := NewLogger(&recorder, new(TextFormatter))
+		sl := Sampled(l, NewSampler(&SamplerOptions{First: 1}))
+
+		for i := 0; i < 3; i++ {
+			require.Panics(t, func() {
+				sl.Panicf("bailing")
+			})
+		}
+
+		require.Equal(t, 3, strings.Count(recorder.String(), "bailing"))
+	})
+}
+
+func TestOnSample(t *testing.T) {
+	var recorder strings.Builder
+	l := NewLogger(&recorder, new(TextFormatter))
+	sampler := NewSampler(&SamplerOptions{
+		Interval: time.Hour,
+		First:    1,
+	})
+
+	for i := 0; i < 3; i++ {
+		OnSample(sampler, "indexer", l).Infof("indexed %d", i)
+	}
+
+	// composes with other triggers
+	sl := Sampled(l, sampler)
+	for i := 0; i < 3; i++ {
+		OnErr(errors.New("fail"), sl).Warning("failed")
+	}
+
+	sampler.Flush()
+
+	out := recorder.String()
+	require.Contains(t, out, "indexed 0")
+	require.NotContains(t, out, "indexed 1")
+	require.Contains(t, out, `sampled_key=indexer`)
+	require.Equal(t, 1, strings.Count(out, "msg=failed"))
+	require.Contains(t, out, "suppressed=2")
+}
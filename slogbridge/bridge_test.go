@@ -0,0 +1,160 @@
+package slogbridge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+func TestHandler(t *testing.T) {
+	t.Run("attrs and groups as fields", func(t *testing.T) {
+		var recorder strings.Builder
+		l := suplog.NewLogger(&recorder, new(suplog.TextFormatter))
+
+		logger := slog.New(NewHandler(l, nil))
+		logger.With("module", "accounts").
+			WithGroup("req").
+			With("id", 42).
+			Info("request done", slog.Group("resp", slog.Int("status", 200)))
+
+		out := recorder.String()
+		require.Contains(t, out, "level=info")
+		require.Contains(t, out, `msg="request done"`)
+		require.Contains(t, out, "module=accounts")
+		require.Contains(t, out, "req.id=42")
+		require.Contains(t, out, "req.resp.status=200")
+	})
+
+	t.Run("respects logger level", func(t *testing.T) {
+		var recorder strings.Builder
+		l := suplog.NewLogger(&recorder, new(suplog.TextFormatter))
+		l.(suplog.LoggerConfigurator).SetLevel(suplog.InfoLevel)
+
+		logger := slog.New(NewHandler(l, nil))
+		require.False(t, logger.Enabled(context.Background(), slog.LevelDebug))
+
+		logger.Debug("hidden")
+		logger.Warn("shown")
+
+		out := recorder.String()
+		require.NotContains(t, out, "hidden")
+		require.Contains(t, out, "level=warning")
+	})
+
+	t.Run("deferred fields and error level", func(t *testing.T) {
+		var recorder strings.Builder
+		l := suplog.NewLogger(&recorder, new(suplog.TextFormatter))
+		logger := slog.New(NewHandler(l, nil))
+
+		func() {
+			var (
+				err   error
+				count int
+			)
+
+			defer logger.Debug("deferred values",
+				Defer("count", &count),
+				DeferError(&err),
+				ErrLevel(suplog.ErrorLevel),
+			)
+
+			count = 3
+			err = errors.New("fail")
+		}()
+
+		out := recorder.String()
+		require.Contains(t, out, "count=3")
+		require.Contains(t, out, "error=fail")
+		require.Contains(t, out, "level=error")
+	})
+
+	t.Run("error attr", func(t *testing.T) {
+		var recorder strings.Builder
+		l := suplog.NewLogger(&recorder, new(suplog.TextFormatter))
+		logger := slog.New(NewHandler(l.ErrLevel(suplog.WarnLevel), nil))
+
+		logger.InfoContext(context.Background(), "failed", "error", errors.New("fail"))
+
+		out := recorder.String()
+		require.Contains(t, out, "error=fail")
+		require.Contains(t, out, "level=warning")
+	})
+}
+
+func TestLogger(t *testing.T) {
+	newSlog := func(buf *bytes.Buffer, opts *slog.HandlerOptions) *slog.Logger {
+		return slog.New(slog.NewTextHandler(buf, opts))
+	}
+
+	t.Run("fields as attrs", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := NewLogger(newSlog(&buf, nil), nil)
+
+		l.WithField("module", "accounts").
+			WithError(errors.New("fail")).
+			Warningf("check %s failed", "balance")
+
+		out := buf.String()
+		require.Contains(t, out, "level=WARN")
+		require.Contains(t, out, `msg="check balance failed"`)
+		require.Contains(t, out, "module=accounts")
+		require.Contains(t, out, "error=fail")
+	})
+
+	t.Run("level filtering by slog", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := NewLogger(newSlog(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}), nil)
+
+		l.Debug("hidden")
+		l.Trace("hidden")
+		l.Info("shown")
+
+		out := buf.String()
+		require.NotContains(t, out, "hidden")
+		require.Contains(t, out, "shown")
+	})
+
+	t.Run("deferred fields and error level", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := NewLogger(newSlog(&buf, nil), nil)
+
+		func() {
+			var (
+				err  error
+				name string
+			)
+
+			defer l.Defer("name", &name).
+				DeferError(&err).
+				ErrLevel(suplog.ErrorLevel).
+				WithContext(context.Background()).
+				Debugf("deferred values")
+
+			name = "Alice"
+			err = errors.New("fail")
+		}()
+
+		out := buf.String()
+		require.Contains(t, out, "name=Alice")
+		require.Contains(t, out, "error=fail")
+		require.Contains(t, out, "level=ERROR")
+		require.NotContains(t, out, "deferred::")
+	})
+
+	t.Run("source position", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := NewLogger(newSlog(&buf, &slog.HandlerOptions{AddSource: true}), &LoggerOptions{
+			AddSource: true,
+		})
+
+		l.Info("with source")
+		require.Contains(t, buf.String(), "bridge_test.go")
+	})
+}
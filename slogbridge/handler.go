@@ -0,0 +1,244 @@
+// Package slogbridge connects suplog with the standard log/slog package,
+// in both directions: slog.Handler backed by suplog.Logger, and suplog.Logger
+// backed by *slog.Logger.
+package slogbridge
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/InjectiveLabs/suplog"
+)
+
+// HandlerOptions allows to set additional Handler options.
+type HandlerOptions struct {
+	// Level reports the minimum slog level to be handled. Logger level is
+	// also respected, if the logger implements suplog.LoggerConfigurator.
+	Level slog.Leveler
+}
+
+func checkHandlerOptions(opt *HandlerOptions) *HandlerOptions {
+	if opt == nil {
+		opt = &HandlerOptions{}
+	}
+
+	if opt.Level == nil {
+		opt.Level = slog.LevelDebug - 4
+	}
+
+	return opt
+}
+
+// NewHandler returns an slog.Handler that writes all records into the provided
+// suplog.Logger. Attributes are added as fields, groups are flattened into
+// dot-separated field names.
+func NewHandler(logger suplog.Logger, opt *HandlerOptions) slog.Handler {
+	if logger == nil {
+		logger = suplog.DefaultLogger
+	}
+
+	return &handler{
+		opt:    checkHandlerOptions(opt),
+		root:   logger,
+		logger: logger,
+	}
+}
+
+type handler struct {
+	opt *HandlerOptions
+
+	// root is the logger provided by user, used for level checks.
+	root   suplog.Logger
+	logger suplog.Logger
+	prefix string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	if level < h.opt.Level.Level() {
+		return false
+	}
+
+	if cfg, ok := h.root.(suplog.LoggerConfigurator); ok {
+		return cfg.IsLevelEnabled(FromSlogLevel(level))
+	}
+
+	return true
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	logger := h.logger
+	if ctx != nil && ctx != context.Background() {
+		logger = logger.WithContext(ctx)
+	}
+
+	if !r.Time.IsZero() {
+		logger = logger.WithTime(r.Time)
+	}
+
+	if r.NumAttrs() > 0 {
+		attrs := make([]slog.Attr, 0, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = append(attrs, a)
+			return true
+		})
+
+		logger = withAttrs(logger, h.prefix, attrs)
+	}
+
+	logger.Log(FromSlogLevel(r.Level), r.Message)
+
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	out := *h
+	out.logger = withAttrs(h.logger, h.prefix, attrs)
+
+	return &out
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if len(name) == 0 {
+		return h
+	}
+
+	out := *h
+	out.prefix = h.prefix + name + "."
+
+	return &out
+}
+
+// withAttrs applies attributes to the logger, resolving values and
+// bridge-specific attributes such as Defer, DeferError and ErrLevel.
+func withAttrs(logger suplog.Logger, prefix string, attrs []slog.Attr) suplog.Logger {
+	fields := make(suplog.Fields, len(attrs))
+	logger = collectAttrs(logger, fields, prefix, attrs)
+
+	if len(fields) > 0 {
+		logger = logger.WithFields(fields)
+	}
+
+	return logger
+}
+
+func collectAttrs(logger suplog.Logger, fields suplog.Fields, prefix string, attrs []slog.Attr) suplog.Logger {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+
+		if a.Value.Kind() == slog.KindGroup {
+			groupAttrs := a.Value.Group()
+			if len(groupAttrs) == 0 {
+				continue
+			}
+
+			groupPrefix := prefix
+			if len(a.Key) > 0 {
+				groupPrefix = prefix + a.Key + "."
+			}
+
+			logger = collectAttrs(logger, fields, groupPrefix, groupAttrs)
+			continue
+		}
+
+		if len(a.Key) == 0 && a.Value.Any() == nil {
+			// ignore empty attrs, as slog handlers should
+			continue
+		}
+
+		switch v := a.Value.Any().(type) {
+		case deferredValue:
+			logger = logger.Defer(prefix+a.Key, v.value)
+			continue
+		case deferredError:
+			logger = logger.DeferError(v.err)
+			continue
+		case errLevel:
+			logger = logger.ErrLevel(v.level)
+			continue
+		case error:
+			if a.Key == logrus.ErrorKey && len(prefix) == 0 {
+				logger = logger.WithError(v)
+				continue
+			}
+		}
+
+		fields[prefix+a.Key] = a.Value.Any()
+	}
+
+	return logger
+}
+
+type deferredValue struct {
+	value interface{}
+}
+
+type deferredError struct {
+	err *error
+}
+
+type errLevel struct {
+	level suplog.Level
+}
+
+// Defer returns an attribute that is handled as suplog.Logger's Defer, value
+// must be a pointer to a scalar type and will be evaluated at the time of logging.
+func Defer(key string, value interface{}) slog.Attr {
+	return slog.Any(key, deferredValue{value: value})
+}
+
+// DeferError returns an attribute that is handled as suplog.Logger's DeferError,
+// the error is evaluated at the time of logging.
+func DeferError(err *error) slog.Attr {
+	return slog.Any(logrus.ErrorKey, deferredError{err: err})
+}
+
+// ErrLevel returns an attribute that is handled as suplog.Logger's ErrLevel,
+// overwriting the log level if the error field is non-nil.
+func ErrLevel(level suplog.Level) slog.Attr {
+	return slog.Any("errLevel", errLevel{level: level})
+}
+
+// FromSlogLevel maps slog levels into suplog levels. Levels above
+// slog.LevelError are mapped to suplog.ErrorLevel, never to Fatal or Panic.
+func FromSlogLevel(level slog.Level) suplog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return suplog.TraceLevel
+	case level < slog.LevelInfo:
+		return suplog.DebugLevel
+	case level < slog.LevelWarn:
+		return suplog.InfoLevel
+	case level < slog.LevelError:
+		return suplog.WarnLevel
+	default:
+		return suplog.ErrorLevel
+	}
+}
+
+// ToSlogLevel maps suplog levels into slog levels. Trace, Fatal and Panic
+// levels have no slog equivalent and are mapped 4 steps below Debug and
+// 4 and 8 steps above Error, respectively.
+func ToSlogLevel(level suplog.Level) slog.Level {
+	switch level {
+	case suplog.PanicLevel:
+		return slog.LevelError + 8
+	case suplog.FatalLevel:
+		return slog.LevelError + 4
+	case suplog.ErrorLevel:
+		return slog.LevelError
+	case suplog.WarnLevel:
+		return slog.LevelWarn
+	case suplog.InfoLevel:
+		return slog.LevelInfo
+	case suplog.DebugLevel:
+		return slog.LevelDebug
+	default:
+		return slog.LevelDebug - 4
+	}
+}
@@ -0,0 +1,119 @@
+package slogbridge
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/InjectiveLabs/suplog"
+	"github.com/InjectiveLabs/suplog/stackcache"
+)
+
+// LoggerOptions allows to set additional Logger options.
+type LoggerOptions struct {
+	// AddSource enables caller lookup for each entry, so slog handlers
+	// with AddSource option could report the source position.
+	AddSource bool
+	// StackTraceOffset allows to wrap logger into greater stack depth and still
+	// get reports on accurate positions.
+	StackTraceOffset int
+	// Hooks are added to the logger after the built-in ones, but before
+	// entries are passed into slog.
+	Hooks []suplog.Hook
+}
+
+func checkLoggerOptions(opt *LoggerOptions) *LoggerOptions {
+	if opt == nil {
+		opt = &LoggerOptions{}
+	}
+
+	return opt
+}
+
+const defaultStackSearchOffset = 6
+
+// NewLogger returns a full suplog.Logger that writes all entries into the
+// provided *slog.Logger. The entries are processed by the same built-in hooks
+// as any other suplogger, so Defer, DeferError and ErrLevel work as usual.
+// Level filtering is left to the slog handler.
+func NewLogger(logger *slog.Logger, opt *LoggerOptions) suplog.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	opt = checkLoggerOptions(opt)
+
+	hook := &slogHook{
+		handler: logger.Handler(),
+	}
+
+	if opt.AddSource {
+		hook.stack = stackcache.New(defaultStackSearchOffset, opt.StackTraceOffset, "github.com/InjectiveLabs/suplog")
+	}
+
+	hooks := append(opt.Hooks[:len(opt.Hooks):len(opt.Hooks)], hook)
+	out := suplog.NewLogger(io.Discard, discardFormatter{}, hooks...)
+	out.(suplog.LoggerConfigurator).SetLevel(suplog.TraceLevel)
+
+	return out
+}
+
+// slogHook passes the final entry into slog.Handler, it must be added last.
+type slogHook struct {
+	handler slog.Handler
+	stack   stackcache.StackCache
+}
+
+func (h *slogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *slogHook) Fire(e *logrus.Entry) error {
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	level := ToSlogLevel(e.Level)
+	if !h.handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	var pc uintptr
+	if h.stack != nil {
+		caller, ok := stackcache.CallerFromContext(e.Context)
+		if !ok {
+			caller = h.stack.GetCaller()
+		}
+
+		// frame PC points at the call instruction, while slog
+		// expects a return address, as reported by runtime.Callers.
+		if caller.PC != 0 {
+			pc = caller.PC + 1
+		}
+	}
+
+	r := slog.NewRecord(e.Time, level, e.Message, pc)
+
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		r.AddAttrs(slog.Any(k, e.Data[k]))
+	}
+
+	return h.handler.Handle(ctx, r)
+}
+
+// discardFormatter skips formatting, since output is discarded anyway.
+type discardFormatter struct{}
+
+func (discardFormatter) Format(*logrus.Entry) ([]byte, error) {
+	return nil, nil
+}
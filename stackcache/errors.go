@@ -0,0 +1,53 @@
+package stackcache
+
+import (
+	"errors"
+	"runtime"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+type pkgErrorsStackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// ErrorFrames returns the stack recorded by pkg/errors, found in the error
+// or any error it wraps. The innermost stack is used, since it is the closest
+// to the origin of the error.
+func ErrorFrames(err error) ([]runtime.Frame, bool) {
+	var stackTrace pkgerrors.StackTrace
+
+	for ; err != nil; err = errors.Unwrap(err) {
+		if tracer, ok := err.(pkgErrorsStackTracer); ok {
+			stackTrace = tracer.StackTrace()
+		}
+	}
+
+	return PkgErrorsFrames(stackTrace), len(stackTrace) > 0
+}
+
+// PkgErrorsFrames converts pkg/errors stack trace into runtime frames.
+func PkgErrorsFrames(stackTrace pkgerrors.StackTrace) []runtime.Frame {
+	if len(stackTrace) == 0 {
+		return nil
+	}
+
+	pcs := make([]uintptr, len(stackTrace))
+	for i, f := range stackTrace {
+		pcs[i] = uintptr(f)
+	}
+
+	frames := runtime.CallersFrames(pcs)
+	result := make([]runtime.Frame, 0, len(pcs))
+
+	for {
+		f, more := frames.Next()
+		result = append(result, f)
+
+		if !more {
+			break
+		}
+	}
+
+	return result
+}
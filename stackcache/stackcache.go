@@ -1,6 +1,8 @@
 package stackcache
 
 import (
+	"context"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -144,3 +146,60 @@ func GetPackageName(path string) string {
 
 	return path
 }
+
+// CallerFieldKey is the entry field that holds runtime.Frame of the original
+// caller, for entries that are logged later than produced, e.g. replayed from
+// a buffer. Loggers move it into the entry context, see WithCaller.
+const CallerFieldKey = "::caller::"
+
+type callerCtxKey struct{}
+
+// WithCaller returns a context that overrides the caller frame,
+// hooks should prefer it over the one found in the stack.
+func WithCaller(ctx context.Context, frame runtime.Frame) context.Context {
+	return context.WithValue(ctx, callerCtxKey{}, frame)
+}
+
+// CallerFromContext returns the caller frame set by WithCaller.
+func CallerFromContext(ctx context.Context) (runtime.Frame, bool) {
+	if ctx == nil {
+		return runtime.Frame{}, false
+	}
+
+	frame, ok := ctx.Value(callerCtxKey{}).(runtime.Frame)
+	return frame, ok
+}
+
+type stackCtxKey struct{}
+
+// WithStack returns a context carrying the stack of the log call, for entries
+// formatted later on another goroutine, e.g. by async output.
+func WithStack(ctx context.Context, frames []runtime.Frame) context.Context {
+	return context.WithValue(ctx, stackCtxKey{}, frames)
+}
+
+// StackFromContext returns the stack set by WithStack.
+func StackFromContext(ctx context.Context) ([]runtime.Frame, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	frames, ok := ctx.Value(stackCtxKey{}).([]runtime.Frame)
+	return frames, ok
+}
+
+// LimitPath keeps the last n segments of the file path, e.g. for n = 3
+// /Users/xlab/Documents/dev/go/src/github.com/InjectiveLabs/suplog/default_test.go
+// becomes InjectiveLabs/suplog/default_test.go. Non-positive n keeps the path as is.
+func LimitPath(path string, n int) string {
+	if n <= 0 {
+		return path
+	}
+
+	pathParts := strings.Split(path, string(filepath.Separator))
+	if len(pathParts) > n {
+		pathParts = pathParts[len(pathParts)-n:]
+	}
+
+	return filepath.Join(pathParts...)
+}
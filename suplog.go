@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"os"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -11,13 +12,28 @@ import (
 	blobHook "github.com/InjectiveLabs/suplog/hooks/blob"
 	bugsnagHook "github.com/InjectiveLabs/suplog/hooks/bugsnag"
 	debugHook "github.com/InjectiveLabs/suplog/hooks/debug"
+	redactHook "github.com/InjectiveLabs/suplog/hooks/redact"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/InjectiveLabs/suplog/stackcache"
 )
 
-// NewLogger constructs a new suplogger.
+// LoggerOption is passed to NewLogger along with hooks to configure the logger, e.g. AsyncOutput.
+// It's never fired as a hook.
+type LoggerOption struct {
+	apply func(l *suplogger)
+}
+
+func (o *LoggerOption) Levels() []logrus.Level {
+	return nil
+}
+
+func (o *LoggerOption) Fire(*logrus.Entry) error {
+	return nil
+}
+
+// NewLogger constructs a new suplogger. Options, e.g. AsyncOutput, are passed along with hooks.
 func NewLogger(wr io.Writer, formatter Formatter, hooks ...Hook) Logger {
 	if formatter == nil {
 		formatter = new(JSONFormatter)
@@ -34,6 +50,7 @@ func NewLogger(wr io.Writer, formatter Formatter, hooks ...Hook) Logger {
 
 		writer:           wr,
 		mux:              new(sync.Mutex),
+		levels:           newLevelRouter(),
 		stackTraceOffset: 0,
 		initDone:         true,
 	}
@@ -41,12 +58,24 @@ func NewLogger(wr io.Writer, formatter Formatter, hooks ...Hook) Logger {
 	log.reloadStackTraceCache()
 	log.entry = log.logger.WithContext(context.Background())
 
+	log.logger.AddHook(&callerHook{})
 	log.logger.AddHook(&deferredHook{})
 	log.logger.AddHook(&errLevelHook{}) // needs to be after deferredHook
+
+	var options []*LoggerOption
 	for _, h := range hooks {
+		if opt, ok := h.(*LoggerOption); ok {
+			options = append(options, opt)
+			continue
+		}
+
 		log.AddHook(h)
 	}
 
+	for _, opt := range options {
+		opt.apply(log)
+	}
+
 	return log
 }
 
@@ -58,6 +87,10 @@ type suplogger struct {
 	writer           io.Writer
 	stack            stackcache.StackCache
 	stackTraceOffset int
+	levels           *levelRouter
+	async            *asyncOutput
+	// ownHooks are default hooks, flushed and closed along with the logger.
+	ownHooks []Hook
 
 	init     sync.Once
 	initDone bool
@@ -85,10 +118,13 @@ func (l *suplogger) initOnce() {
 
 		l.entry = l.logger.WithContext(context.Background())
 		l.reloadStackTraceCache()
-		l.addDefaultHooks()
-		l.mux = new(sync.Mutex)
+		l.logger.AddHook(&callerHook{}) // needs to be before default hooks
+		// deferred values need to be resolved before default hooks, so these are redacted and shipped
 		l.logger.AddHook(&deferredHook{})
 		l.logger.AddHook(&errLevelHook{}) // needs to be after deferredHook
+		l.addDefaultHooks()
+		l.mux = new(sync.Mutex)
+		l.levels = newLevelRouter()
 		l.initDone = true
 	})
 }
@@ -111,8 +147,24 @@ func (l *suplogger) addDefaultHooks() {
 
 	l.logger.AddHook(debugHook.NewHook(hookLogger, nil))
 
+	if isTrue(os.Getenv("LOG_REDACT_ENABLED")) {
+		// needs to be before hooks shipping entries elsewhere
+		l.logger.AddHook(redactHook.NewHook(hookLogger, nil))
+	}
+
+	// registered hooks add fields, so these need to be before hooks shipping entries elsewhere
+	defaultHooksMux.Lock()
+	for _, newHook := range defaultHooks {
+		if hook := newHook(hookLogger); hook != nil {
+			l.logger.AddHook(hook)
+		}
+	}
+	defaultHooksMux.Unlock()
+
 	if isTrue(os.Getenv("LOG_BLOB_ENABLED")) {
-		l.logger.AddHook(blobHook.NewHook(hookLogger, nil))
+		hook := blobHook.NewHook(hookLogger, nil)
+		l.logger.AddHook(hook)
+		l.ownHooks = append(l.ownHooks, hook)
 	}
 
 	if isTrue(os.Getenv("LOG_BUGSNAG_ENABLED")) {
@@ -120,6 +172,22 @@ func (l *suplogger) addDefaultHooks() {
 	}
 }
 
+var (
+	defaultHooksMux sync.Mutex
+	defaultHooks    []func(hookLogger Logger) Hook
+)
+
+// RegisterDefaultHook registers a hook of default suploggers, e.g. DefaultLogger, so hook packages
+// with heavy dependencies are only linked when imported. The hook is added after the redact hook
+// and before blob and bugsnag hooks, on the first use of the logger, so register it from init.
+// The constructor may return nil to skip the hook.
+func RegisterDefaultHook(newHook func(hookLogger Logger) Hook) {
+	defaultHooksMux.Lock()
+	defer defaultHooksMux.Unlock()
+
+	defaultHooks = append(defaultHooks, newHook)
+}
+
 // Adds a field to the log entry, note that it doesn't log until you call
 // Debug, Print, Info, Warn, Error, Fatal or Panic. It only creates a log entry.
 // If you want multiple fields, use `WithFields`.
@@ -157,9 +225,20 @@ func (l *suplogger) WithError(err error) Logger {
 	return outCopy
 }
 
-// Add a context to the log entry.
+// Add a context to the log entry. Error level set by ErrLevel is
+// carried over into the new context, unless it sets its own.
 func (l *suplogger) WithContext(ctx context.Context) Logger {
 	l.initOnce()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if lvl, ok := l.entry.Context.Value(errLvlCtxKey{}).(Level); ok {
+		if _, ok := ctx.Value(errLvlCtxKey{}).(Level); !ok {
+			ctx = context.WithValue(ctx, errLvlCtxKey{}, lvl)
+		}
+	}
+
 	outCopy := l.copy()
 	outCopy.entry = l.entry.WithContext(ctx)
 
@@ -197,179 +276,204 @@ func (l *suplogger) ErrLevel(level Level) Logger {
 
 func (l *suplogger) Logf(level Level, format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(level, format, args...)
+	l.logf(level, format, args...)
 }
 
 func (l *suplogger) Tracef(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(TraceLevel, format, args...)
+	l.logf(TraceLevel, format, args...)
 }
 
 func (l *suplogger) Debugf(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(DebugLevel, format, args...)
+	l.logf(DebugLevel, format, args...)
 }
 
 func (l *suplogger) Infof(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(InfoLevel, format, args...)
+	l.logf(InfoLevel, format, args...)
 }
 
 func (l *suplogger) Printf(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Printf(format, args...)
+	l.logf(InfoLevel, format, args...)
 }
 
 func (l *suplogger) Warningf(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(WarnLevel, format, args...)
+	l.logf(WarnLevel, format, args...)
 }
 
 func (l *suplogger) Errorf(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(ErrorLevel, format, args...)
+	l.logf(ErrorLevel, format, args...)
 }
 
 func (l *suplogger) Fatalf(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(FatalLevel, format, args...)
+	l.logf(FatalLevel, format, args...)
 	l.logger.Exit(1)
 }
 
 func (l *suplogger) Panicf(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(PanicLevel, format, args...)
+	l.logf(PanicLevel, format, args...)
 }
 
 func (l *suplogger) Log(level Level, args ...interface{}) {
 	l.initOnce()
-	l.entry.Log(level, args...)
+	l.log(level, args...)
 }
 
 func (l *suplogger) Trace(args ...interface{}) {
 	l.initOnce()
-	l.entry.Log(TraceLevel, args...)
+	l.log(TraceLevel, args...)
 }
 
 func (l *suplogger) Info(args ...interface{}) {
 	l.initOnce()
-	l.entry.Log(InfoLevel, args...)
+	l.log(InfoLevel, args...)
 }
 
 func (l *suplogger) Print(args ...interface{}) {
 	l.initOnce()
-	l.entry.Print(args...)
+	l.log(InfoLevel, args...)
 }
 
 func (l *suplogger) Fatal(args ...interface{}) {
 	l.initOnce()
-	l.entry.Log(FatalLevel, args...)
+	l.log(FatalLevel, args...)
 	l.logger.Exit(1)
 }
 
 func (l *suplogger) Panic(args ...interface{}) {
 	l.initOnce()
-	l.entry.Log(PanicLevel, args...)
+	l.log(PanicLevel, args...)
 }
 
 func (l *suplogger) Logln(level Level, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logln(level, args...)
+	l.logln(level, args...)
 }
 
 func (l *suplogger) Traceln(args ...interface{}) {
 	l.initOnce()
-	l.entry.Logln(TraceLevel, args...)
+	l.logln(TraceLevel, args...)
 }
 
 func (l *suplogger) Debugln(args ...interface{}) {
 	l.initOnce()
-	l.entry.Logln(DebugLevel, args...)
+	l.logln(DebugLevel, args...)
 }
 
 func (l *suplogger) Infoln(args ...interface{}) {
 	l.initOnce()
-	l.entry.Logln(InfoLevel, args...)
+	l.logln(InfoLevel, args...)
 }
 
 func (l *suplogger) Println(args ...interface{}) {
 	l.initOnce()
-	l.entry.Println(args...)
+	l.logln(InfoLevel, args...)
 }
 
 func (l *suplogger) Warningln(args ...interface{}) {
 	l.initOnce()
-	l.entry.Logln(WarnLevel, args...)
+	l.logln(WarnLevel, args...)
 }
 
 func (l *suplogger) Errorln(args ...interface{}) {
 	l.initOnce()
-	l.entry.Logln(ErrorLevel, args...)
+	l.logln(ErrorLevel, args...)
 }
 
 func (l *suplogger) Fatalln(args ...interface{}) {
 	l.initOnce()
-	l.entry.Logln(FatalLevel, args...)
+	l.logln(FatalLevel, args...)
 	l.logger.Exit(1)
 }
 
 func (l *suplogger) Debug(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(DebugLevel, format, args...)
+	l.logf(DebugLevel, format, args...)
 }
 
 func (l *suplogger) Notification(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(InfoLevel, format, args...)
+	l.logf(InfoLevel, format, args...)
 }
 
 func (l *suplogger) Success(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(InfoLevel, format, args...)
+	l.logf(InfoLevel, format, args...)
 }
 
 func (l *suplogger) Warning(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(WarnLevel, format, args...)
+	l.logf(WarnLevel, format, args...)
 }
 
 func (l *suplogger) Error(format string, args ...interface{}) {
 	l.initOnce()
-	l.entry.Logf(ErrorLevel, format, args...)
+	l.logf(ErrorLevel, format, args...)
 }
 
 func (l *suplogger) Panicln(args ...interface{}) {
 	l.initOnce()
-	l.entry.Logln(PanicLevel, args...)
+	l.logln(PanicLevel, args...)
 }
 
-// SetLevel sets the logger level.
+// SetLevel sets the logger level. If level rules are set,
+// it becomes the default level for the rules.
 func (l *suplogger) SetLevel(level Level) {
 	l.initOnce()
-	l.logger.SetLevel(level)
+	l.logger.SetLevel(l.levels.setBase(level))
 }
 
 // GetLevel returns the logger level.
 func (l *suplogger) GetLevel() Level {
 	l.initOnce()
+	if l.levels.active() {
+		return l.levels.getBase()
+	}
+
 	return l.logger.GetLevel()
 }
 
+// SetLevelRules sets level overrides keyed by caller package or "module" field,
+// nil rules disable the overrides.
+func (l *suplogger) SetLevelRules(rules *LevelRules) {
+	l.initOnce()
+	base := l.GetLevel()
+	l.logger.SetLevel(l.levels.set(rules, base))
+}
+
+// GetLevelRules returns a copy of current level rules, or nil.
+func (l *suplogger) GetLevelRules() *LevelRules {
+	l.initOnce()
+	return l.levels.getRules()
+}
+
 // AddHook adds a hook to the logger hooks.
 func (l *suplogger) AddHook(hook Hook) {
 	l.initOnce()
 	l.logger.AddHook(hook)
 }
 
-// IsLevelEnabled checks if the log level of the logger is greater than the level param
+// IsLevelEnabled checks if the log level of the logger is greater than the level param,
+// honouring level rules for the caller package or module.
 func (l *suplogger) IsLevelEnabled(level Level) bool {
 	l.initOnce()
-	return l.logger.IsLevelEnabled(level)
+	return l.logger.IsLevelEnabled(level) && l.levelEnabled(level)
 }
 
 // SetFormatter sets the logger formatter.
 func (l *suplogger) SetFormatter(formatter Formatter) {
 	l.initOnce()
+	if l.async != nil {
+		l.async.setFormatter(formatter)
+		return
+	}
+
 	l.logger.SetFormatter(formatter)
 }
 
@@ -383,6 +487,11 @@ func (l *suplogger) SetStackTraceOffset(offset int) {
 // SetOutput sets the logger suplog.
 func (l *suplogger) SetOutput(output io.Writer) {
 	l.initOnce()
+	if l.async != nil {
+		l.async.setOutput(output)
+		return
+	}
+
 	l.logger.SetOutput(output)
 }
 
@@ -393,8 +502,10 @@ func (l *suplogger) ReplaceHooks(hooks LevelHooks) LevelHooks {
 }
 
 // Close effectively closes output, closing the underlying writer
-// if it implements io.WriteCloser.
+// if it implements io.WriteCloser. Async output is drained first.
 func (l *suplogger) Close() (err error) {
+	l.initOnce()
+
 	// bail out if already closed
 	l.mux.Lock()
 	defer l.mux.Unlock()
@@ -405,6 +516,16 @@ func (l *suplogger) Close() (err error) {
 
 	l.closed = true
 
+	if l.async != nil {
+		_ = l.async.Close()
+	}
+
+	for _, hook := range l.ownHooks {
+		if closer, ok := hook.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+
 	// try to close only WriteClosers
 	if outCloser, ok := l.writer.(io.WriteCloser); ok {
 		return outCloser.Close()
@@ -413,6 +534,38 @@ func (l *suplogger) Close() (err error) {
 	return
 }
 
+// Flush blocks until all queued entries are written, and default hooks
+// are done with background work, e.g. blob uploads.
+func (l *suplogger) Flush(ctx context.Context) error {
+	l.initOnce()
+	if l.async != nil {
+		if err := l.async.Flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, hook := range l.ownHooks {
+		if flusher, ok := hook.(interface{ Flush(context.Context) error }); ok {
+			if err := flusher.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AsyncStats returns counters of async output, or zero stats
+// for loggers without async output.
+func (l *suplogger) AsyncStats() AsyncStats {
+	l.initOnce()
+	if l.async == nil {
+		return AsyncStats{}
+	}
+
+	return l.async.stats()
+}
+
 // CallerName returns caller function name.
 func (l *suplogger) CallerName() string {
 	l.initOnce()
@@ -432,6 +585,73 @@ func isTrue(v string) bool {
 	return false
 }
 
+// levelEnabled checks level rules, if any. The caller package is resolved
+// as the first frame outside of suplog package.
+func (l *suplogger) levelEnabled(level Level) bool {
+	if !l.levels.active() {
+		return true
+	}
+
+	module, _ := l.entry.Data[ModuleKey].(string)
+	caller, ok := l.entry.Data[stackcache.CallerFieldKey].(runtime.Frame)
+	if !ok {
+		caller, _ = stackcache.CallerFromContext(l.entry.Context)
+	}
+
+	return level <= l.levels.levelFor(module, caller, l.stack)
+}
+
+func (l *suplogger) logf(level Level, format string, args ...interface{}) {
+	if !l.levelEnabled(level) {
+		return
+	}
+
+	l.outEntry().Logf(level, format, args...)
+}
+
+func (l *suplogger) log(level Level, args ...interface{}) {
+	if !l.levelEnabled(level) {
+		return
+	}
+
+	l.outEntry().Log(level, args...)
+}
+
+func (l *suplogger) logln(level Level, args ...interface{}) {
+	if !l.levelEnabled(level) {
+		return
+	}
+
+	l.outEntry().Logln(level, args...)
+}
+
+// outEntry returns the entry to log. With async output, formatters run on the background
+// goroutine, so the stack of errors without one is captured here.
+func (l *suplogger) outEntry() *logrus.Entry {
+	if l.async == nil || !l.needsStack() {
+		return l.entry
+	}
+
+	return l.entry.WithContext(stackcache.WithStack(l.entry.Context, l.stack.GetStackFrames()))
+}
+
+// needsStack reports whether formatters would look for the stack of the log call.
+func (l *suplogger) needsStack() bool {
+	if _, ok := l.entry.Data[stackcache.CallerFieldKey]; ok {
+		return false
+	} else if _, ok := stackcache.CallerFromContext(l.entry.Context); ok {
+		return false
+	}
+
+	if err, ok := l.entry.Data[logrus.ErrorKey].(error); ok {
+		_, hasFrames := stackcache.ErrorFrames(err)
+		return !hasFrames
+	}
+
+	_, ok := l.entry.Data[deferredFieldKey+logrus.ErrorKey]
+	return ok
+}
+
 // copy allows to construct an suplogger copy with new entry.
 func (l *suplogger) copy() *suplogger {
 	return &suplogger{
@@ -439,6 +659,9 @@ func (l *suplogger) copy() *suplogger {
 		logger:   l.logger,
 		stack:    l.stack,
 		mux:      l.mux,
+		levels:   l.levels,
+		async:    l.async,
+		ownHooks: l.ownHooks,
 		initDone: l.initDone,
 		closed:   l.closed,
 	}